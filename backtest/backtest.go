@@ -0,0 +1,228 @@
+// 事件驱动回测, 用历史 K 线或录制的深度和成交推送驱动策略
+//
+// 策略只通过 Broker 下单和查询, 同一个 Strategy 可以由回测或 PaperBroker 驱动
+// 下单和撤单在 Config.Latency 之后才到达模拟交易所, 期间订单仍可能成交
+// 到达时可以立即成交的部分作为 taker 成交: 有深度时吃深度, 否则按事件价格成交
+// 剩余部分挂单, 排在同价位已有数量之后, 同价位的成交先消耗前面的数量, 价格穿过挂单价格时全部成交
+// 余额按交易规则计算手续费, 买单手续费扣交易币种, 卖单手续费扣报价币种
+package backtest
+
+import (
+	"io"
+	"time"
+
+	coinex "github.com/icwl/go-exchange-api/coinex/v2"
+	gate "github.com/icwl/go-exchange-api/gate/v4"
+	"github.com/icwl/go-exchange-api/paper"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrInvalidOrder        = errors.New("backtest: invalid order")
+	ErrInsufficientBalance = errors.New("backtest: insufficient balance")
+	ErrOrderNotFound       = errors.New("backtest: order not found")
+	ErrOrderFinished       = errors.New("backtest: order finished")
+	ErrOutOfOrder          = errors.New("backtest: event out of order")
+)
+
+// 交易规则, 由交易所的市场信息生成
+type Rules struct {
+	Market string
+	Base   string
+	Quote  string
+	// 手续费率
+	MakerFeeRate decimal.Decimal
+	TakerFeeRate decimal.Decimal
+	// 价格和数量精度
+	PricePrecision  int32
+	AmountPrecision int32
+	// 最小交易数量, 为 0 时不限制
+	MinAmount decimal.Decimal
+	// 最小交易金额, 为 0 时不限制
+	MinNotional decimal.Decimal
+}
+
+// CoinEx 现货市场规则
+func CoinExRules(m *coinex.SpotMarket) Rules {
+	return Rules{
+		Market:          m.Market,
+		Base:            m.BaseCcy,
+		Quote:           m.QuoteCcy,
+		MakerFeeRate:    m.MakerFeeRate,
+		TakerFeeRate:    m.TakerFeeRate,
+		PricePrecision:  m.QuoteCcyPrecision,
+		AmountPrecision: m.BaseCcyPrecision,
+		MinAmount:       m.MinAmount,
+	}
+}
+
+// Gate 现货交易对规则, 手续费率同时作为 maker 和 taker 费率
+func GateRules(p *gate.CurrencyPair) Rules {
+	rate := p.Fee.Div(decimal.NewFromInt(100))
+	return Rules{
+		Market:          p.ID,
+		Base:            p.Base,
+		Quote:           p.Quote,
+		MakerFeeRate:    rate,
+		TakerFeeRate:    rate,
+		PricePrecision:  p.Precision,
+		AmountPrecision: p.AmountPrecision,
+		MinAmount:       p.MinBaseAmount,
+		MinNotional:     p.MinQuoteAmount,
+	}
+}
+
+// 检查价格和数量精度以及最小交易量, 市价单按 price 估算交易金额
+func (r Rules) check(o *Order, price decimal.Decimal) error {
+	if !o.Amount.IsPositive() || !o.Amount.Equal(o.Amount.Truncate(r.AmountPrecision)) {
+		return errors.Wrap(ErrInvalidOrder, "amount")
+	}
+	if o.Amount.LessThan(r.MinAmount) {
+		return errors.Wrap(ErrInvalidOrder, "amount too small")
+	}
+	if o.Type == paper.TypeLimit {
+		if !o.Price.IsPositive() || !o.Price.Equal(o.Price.Truncate(r.PricePrecision)) {
+			return errors.Wrap(ErrInvalidOrder, "price")
+		}
+		price = o.Price
+	}
+	if price.IsPositive() && o.Amount.Mul(price).LessThan(r.MinNotional) {
+		return errors.Wrap(ErrInvalidOrder, "notional too small")
+	}
+	return nil
+}
+
+// 行情事件, 按 Time 升序到达
+type Event interface {
+	Time() time.Time
+}
+
+// K 线, 在收盘时间到达
+type Bar struct {
+	Start  time.Time
+	End    time.Time
+	Open   decimal.Decimal
+	High   decimal.Decimal
+	Low    decimal.Decimal
+	Close  decimal.Decimal
+	Volume decimal.Decimal
+}
+
+func (b *Bar) Time() time.Time { return b.End }
+
+// 成交
+type Trade struct {
+	At time.Time
+	// taker 方向, 为空时不区分方向
+	Side   string
+	Price  decimal.Decimal
+	Amount decimal.Decimal
+}
+
+func (t *Trade) Time() time.Time { return t.At }
+
+// 深度快照
+type Book struct {
+	At time.Time
+	// [[卖方价格, 卖方数量],...] 价格升序
+	Asks [][2]decimal.Decimal
+	// [[买方价格, 买方数量],...] 价格降序
+	Bids [][2]decimal.Decimal
+}
+
+func (b *Book) Time() time.Time { return b.At }
+
+type Order struct {
+	ID   int64
+	Side string
+	// paper.TypeLimit 或 paper.TypeMarket
+	Type string
+	// 只做 maker, 到达时会立即成交则撤销
+	PostOnly bool
+	// 委托价格, 市价单为 0
+	Price  decimal.Decimal
+	Amount decimal.Decimal
+	// 已成交数量和金额
+	Filled      decimal.Decimal
+	FilledValue decimal.Decimal
+	Fee         decimal.Decimal
+	FeeCcy      string
+	// 同 paper 的订单状态
+	Status    string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// 一次成交
+type Fill struct {
+	OrderID int64
+	Time    time.Time
+	Side    string
+	Price   decimal.Decimal
+	Amount  decimal.Decimal
+	Fee     decimal.Decimal
+	FeeCcy  string
+	Maker   bool
+}
+
+// 策略下单接口, 回测时由模拟交易所实现, 在实时行情上模拟运行时由 PaperBroker 实现
+// 没有直接包装 HTTPClient 的实现, 实盘下单需要自行实现该接口
+type Broker interface {
+	// 当前时间, 回测时为当前事件时间
+	Now() time.Time
+	// 下单, 返回的订单状态为 open, 到达交易所后才会成交
+	Place(o Order) (*Order, error)
+	// 撤单, 到达交易所前订单仍可能成交
+	Cancel(id int64) error
+	Order(id int64) (*Order, error)
+	// 未完成订单
+	OpenOrders() []*Order
+	Balance(ccy string) paper.Balance
+}
+
+type Strategy interface {
+	// 每个行情事件调用一次, 在该事件触发的订单更新之后
+	OnEvent(b Broker, e Event)
+	// 订单成交或结束
+	OnOrder(b Broker, o *Order)
+}
+
+// 行情事件来源, 结束时返回 io.EOF
+type Source interface {
+	Next() (Event, error)
+}
+
+type Config struct {
+	Rules Rules
+	// 初始可用余额
+	Balances map[string]decimal.Decimal
+	// 下单和撤单到达交易所的延迟
+	Latency time.Duration
+	// 按 K 线回测时价格触及挂单价格即成交, 默认需要穿过挂单价格
+	FillOnTouch bool
+}
+
+// 运行回测直到 Source 结束
+func Run(cfg Config, src Source, s Strategy) (*Result, error) {
+	x := newExchange(cfg)
+	for {
+		e, err := src.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if e.Time().Before(x.now) {
+			return nil, errors.Wrap(ErrOutOfOrder, e.Time().String())
+		}
+		x.now = e.Time()
+		for _, o := range x.apply(e) {
+			s.OnOrder(x, o)
+		}
+		s.OnEvent(x, e)
+		x.mark()
+	}
+	return x.result(), nil
+}
@@ -0,0 +1,231 @@
+package backtest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/icwl/go-exchange-api/paper"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+func d(s string) decimal.Decimal {
+	return decimal.RequireFromString(s)
+}
+
+func levels(pairs ...string) [][2]decimal.Decimal {
+	list := make([][2]decimal.Decimal, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		list = append(list, [2]decimal.Decimal{d(pairs[i]), d(pairs[i+1])})
+	}
+	return list
+}
+
+var rules = Rules{
+	Market:          "BTCUSDT",
+	Base:            "BTC",
+	Quote:           "USDT",
+	MakerFeeRate:    d("0.001"),
+	TakerFeeRate:    d("0.002"),
+	PricePrecision:  2,
+	AmountPrecision: 4,
+	MinAmount:       d("0.0001"),
+}
+
+type script struct {
+	onEvent func(b Broker, e Event)
+	onOrder func(b Broker, o *Order)
+}
+
+func (s *script) OnEvent(b Broker, e Event) {
+	if s.onEvent != nil {
+		s.onEvent(b, e)
+	}
+}
+
+func (s *script) OnOrder(b Broker, o *Order) {
+	if s.onOrder != nil {
+		s.onOrder(b, o)
+	}
+}
+
+func bar(minute int64, open, high, low, close string) *Bar {
+	start := time.Unix(minute*60, 0)
+	return &Bar{Start: start, End: start.Add(time.Minute), Open: d(open), High: d(high), Low: d(low), Close: d(close)}
+}
+
+func TestRun_Bars(t *testing.T) {
+	src := Events(
+		bar(0, "100", "101", "99", "100"),
+		bar(1, "100", "102", "98", "101"),
+		bar(2, "101", "103", "100", "102"),
+		bar(3, "102", "106", "101", "105"),
+	)
+	s := &script{}
+	s.onEvent = func(b Broker, e Event) {
+		if !e.Time().Equal(time.Unix(60, 0)) {
+			return
+		}
+		if _, err := b.Place(Order{Side: paper.SideBuy, Price: d("99.001"), Amount: d("10")}); !errors.Is(err, ErrInvalidOrder) {
+			t.Fatalf("got %v, want invalid order", err)
+		}
+		if _, err := b.Place(Order{Side: paper.SideBuy, Price: d("99"), Amount: d("200")}); !errors.Is(err, ErrInsufficientBalance) {
+			t.Fatalf("got %v, want insufficient balance", err)
+		}
+		if _, err := b.Place(Order{Side: paper.SideBuy, Price: d("99"), Amount: d("10")}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 买单成交后挂止盈卖单
+	s.onOrder = func(b Broker, o *Order) {
+		if o.Side == paper.SideBuy && o.Status == paper.StatusFilled {
+			if _, err := b.Place(Order{Side: paper.SideSell, Price: d("104"), Amount: o.Filled.Sub(o.Fee)}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	res, err := Run(Config{
+		Rules:    rules,
+		Balances: map[string]decimal.Decimal{"USDT": d("10000")},
+		Latency:  time.Second,
+	}, src, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res.Fills) != 2 || !res.Fills[0].Price.Equal(d("99")) || !res.Fills[0].Maker || !res.Fills[1].Amount.Equal(d("9.99")) {
+		t.Fatalf("unexpected fills: %+v", res.Fills)
+	}
+	want := []string{"10000", "10018.99", "10028.98", "10047.92104"}
+	if len(res.Equity) != len(want) {
+		t.Fatalf("unexpected equity: %+v", res.Equity)
+	}
+	for i, w := range want {
+		if !res.Equity[i].Equity.Equal(d(w)) {
+			t.Fatalf("equity[%d] = %s, want %s", i, res.Equity[i].Equity, w)
+		}
+	}
+	st := res.Stats
+	if !st.RealizedPnL.Round(8).Equal(d("47.92104")) || !st.Fees.Equal(d("2.02896")) || st.WinRate != 1 || st.Fills != 2 {
+		t.Fatalf("unexpected stats: %+v", st)
+	}
+	if st.Return < 0.004792 || st.Return > 0.004793 || st.MaxDrawdown != 0 {
+		t.Fatalf("unexpected stats: %+v", st)
+	}
+}
+
+func TestRun_Queue(t *testing.T) {
+	at := func(ms int64) time.Time { return time.UnixMilli(ms) }
+	src := Events(
+		&Book{At: at(0), Asks: levels("101", "1"), Bids: levels("100", "2")},
+		&Book{At: at(200), Asks: levels("101", "1"), Bids: levels("100", "2")},
+		// 先消耗排在前面的 2 个
+		&Trade{At: at(300), Side: paper.SideSell, Price: d("100"), Amount: d("1.5")},
+		// 前面的订单撤销 1 个
+		&Book{At: at(400), Asks: levels("101", "1"), Bids: levels("100", "1")},
+		&Trade{At: at(500), Side: paper.SideSell, Price: d("100"), Amount: d("0.8")},
+		// 撤单到达前价格穿过, 全部成交
+		&Trade{At: at(550), Side: paper.SideSell, Price: d("99.5"), Amount: d("0.1")},
+		&Book{At: at(700), Asks: levels("101", "1"), Bids: levels("100.5", "0.2", "100", "1")},
+	)
+	var id int64
+	s := &script{}
+	s.onEvent = func(b Broker, e Event) {
+		switch e.Time() {
+		case at(0):
+			o, err := b.Place(Order{Side: paper.SideBuy, Type: paper.TypeLimit, Price: d("100"), Amount: d("1")})
+			if err != nil {
+				t.Fatal(err)
+			}
+			id = o.ID
+		case at(500):
+			if o, _ := b.Order(id); !o.Filled.Equal(d("0.3")) {
+				t.Fatalf("unexpected order: %+v", o)
+			}
+			if err := b.Cancel(id); err != nil {
+				t.Fatal(err)
+			}
+		case at(550):
+			if _, err := b.Place(Order{Side: paper.SideSell, Type: paper.TypeMarket, Amount: d("0.5")}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	res, err := Run(Config{
+		Rules:    rules,
+		Balances: map[string]decimal.Decimal{"USDT": d("1000")},
+		Latency:  100 * time.Millisecond,
+	}, src, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	amounts := []string{"0.3", "0.7", "0.2", "0.3"}
+	if len(res.Fills) != len(amounts) {
+		t.Fatalf("unexpected fills: %+v", res.Fills)
+	}
+	for i, a := range amounts {
+		if !res.Fills[i].Amount.Equal(d(a)) {
+			t.Fatalf("fill[%d] = %+v, want amount %s", i, res.Fills[i], a)
+		}
+	}
+	if res.Orders[0].Status != paper.StatusFilled || res.Fills[2].Maker || !res.Fills[2].Price.Equal(d("100.5")) {
+		t.Fatalf("unexpected result: %+v %+v", res.Orders[0], res.Fills[2])
+	}
+	if !res.Equity[len(res.Equity)-1].Equity.Equal(d("949.9998").Add(d("0.499").Mul(d("100.75")))) {
+		t.Fatalf("unexpected equity: %+v", res.Equity[len(res.Equity)-1])
+	}
+}
+
+func TestMerge(t *testing.T) {
+	bids := merge(levels("100", "1", "99", "2"), levels("101", "0.5", "99", "0", "98", "3", "100", "4"), true)
+	want := levels("101", "0.5", "100", "4", "98", "3")
+	if len(bids) != len(want) {
+		t.Fatalf("got %v, want %v", bids, want)
+	}
+	for i := range want {
+		if !bids[i][0].Equal(want[i][0]) || !bids[i][1].Equal(want[i][1]) {
+			t.Fatalf("got %v, want %v", bids, want)
+		}
+	}
+}
+
+func TestPaperBroker(t *testing.T) {
+	engine := paper.NewEngine()
+	engine.AddMarket(paper.Market{Name: "BTCUSDT", Base: "BTC", Quote: "USDT", TakerFeeRate: d("0.002"), AmountPrecision: 4})
+	engine.SetBalance("USDT", d("10000"))
+	engine.UpdateBook("BTCUSDT", levels("30000", "0.1"), levels("29990", "1"))
+
+	var b Broker = NewPaperBroker(engine, "BTCUSDT")
+	if _, err := b.Place(Order{Side: paper.SideBuy, Price: d("29990"), Amount: d("0.1"), PostOnly: true}); err != nil {
+		t.Fatal(err)
+	}
+	o, err := b.Place(Order{Side: paper.SideBuy, Price: d("30000"), Amount: d("0.2")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Status != paper.StatusPartFilled || !o.Filled.Equal(d("0.1")) {
+		t.Fatalf("unexpected order: %+v", o)
+	}
+	if open := b.OpenOrders(); len(open) != 2 || open[1].ID != o.ID || !open[0].PostOnly {
+		t.Fatalf("unexpected open orders: %+v", open)
+	}
+	if _, err := b.Place(Order{Side: paper.SideBuy, Price: d("30000"), Amount: d("1")}); !errors.Is(err, ErrInsufficientBalance) {
+		t.Fatalf("got %v, want insufficient balance", err)
+	}
+
+	if err := b.Cancel(o.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Cancel(o.ID); !errors.Is(err, ErrOrderFinished) {
+		t.Fatalf("got %v, want finished", err)
+	}
+	if _, err := b.Order(100); !errors.Is(err, ErrOrderNotFound) {
+		t.Fatalf("got %v, want not found", err)
+	}
+	if btc := b.Balance("BTC"); !btc.Available.Equal(d("0.0998")) {
+		t.Fatalf("unexpected balance: %+v", btc)
+	}
+}
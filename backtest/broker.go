@@ -0,0 +1,116 @@
+package backtest
+
+import (
+	"time"
+
+	"github.com/icwl/go-exchange-api/paper"
+	"github.com/pkg/errors"
+)
+
+// 用 paper.Engine 实现 Broker, 在实时行情上模拟运行同一个 Strategy
+// gate 和 coinex 的 PaperClient 通过 Engine() 提供撮合引擎, 深度由 PaperClient 的推送回调更新
+// 下单立即按当前深度撮合, 没有 Config.Latency 的延迟
+type PaperBroker struct {
+	engine *paper.Engine
+	market string
+}
+
+var _ Broker = (*PaperBroker)(nil)
+
+// 需要先通过 AddMarket 或 PaperClient 加载市场
+func NewPaperBroker(engine *paper.Engine, market string) *PaperBroker {
+	return &PaperBroker{
+		engine: engine,
+		market: market,
+	}
+}
+
+func (b *PaperBroker) Now() time.Time {
+	return time.Now()
+}
+
+func (b *PaperBroker) Place(o Order) (*Order, error) {
+	tif := paper.TimeInForceGTC
+	if o.PostOnly {
+		tif = paper.TimeInForcePostOnly
+	}
+	if o.Type == "" {
+		o.Type = paper.TypeLimit
+	}
+	ord, err := b.engine.Place(paper.Order{
+		Market:      b.market,
+		Side:        o.Side,
+		Type:        o.Type,
+		TimeInForce: tif,
+		Price:       o.Price,
+		Amount:      o.Amount,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, paper.ErrInsufficientBalance):
+			return nil, errors.WithStack(ErrInsufficientBalance)
+		case errors.Is(err, paper.ErrInvalidOrder):
+			return nil, errors.Wrap(ErrInvalidOrder, err.Error())
+		}
+		return nil, errors.WithStack(err)
+	}
+	return paperOrder(ord), nil
+}
+
+func (b *PaperBroker) Cancel(id int64) error {
+	_, err := b.engine.Cancel(b.market, id)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, paper.ErrOrderNotFound):
+		return errors.WithStack(ErrOrderNotFound)
+	case errors.Is(err, paper.ErrOrderFinished):
+		return errors.WithStack(ErrOrderFinished)
+	}
+	return errors.WithStack(err)
+}
+
+func (b *PaperBroker) Order(id int64) (*Order, error) {
+	o, err := b.engine.Order(b.market, id)
+	if err != nil {
+		return nil, errors.WithStack(ErrOrderNotFound)
+	}
+	return paperOrder(o), nil
+}
+
+// 按创建时间升序, 与回测一致
+func (b *PaperBroker) OpenOrders() []*Order {
+	orders := b.engine.Orders(b.market, "", false)
+	list := make([]*Order, 0, len(orders))
+	for i := len(orders) - 1; i >= 0; i-- {
+		list = append(list, paperOrder(orders[i]))
+	}
+	return list
+}
+
+func (b *PaperBroker) Balance(ccy string) paper.Balance {
+	for _, balance := range b.engine.Balances() {
+		if balance.Ccy == ccy {
+			return balance
+		}
+	}
+	return paper.Balance{Ccy: ccy}
+}
+
+func paperOrder(o *paper.Order) *Order {
+	return &Order{
+		ID:          o.ID,
+		Side:        o.Side,
+		Type:        o.Type,
+		PostOnly:    o.TimeInForce == paper.TimeInForcePostOnly,
+		Price:       o.Price,
+		Amount:      o.Amount,
+		Filled:      o.Filled,
+		FilledValue: o.FilledValue,
+		Fee:         o.Fee,
+		FeeCcy:      o.FeeCcy,
+		Status:      o.Status,
+		CreatedAt:   time.UnixMilli(o.CreatedAt),
+		UpdatedAt:   time.UnixMilli(o.UpdatedAt),
+	}
+}
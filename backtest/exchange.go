@@ -0,0 +1,502 @@
+package backtest
+
+import (
+	"time"
+
+	"github.com/icwl/go-exchange-api/paper"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+type order struct {
+	Order
+	// 到达交易所的时间
+	arrive time.Time
+	// 撤单到达交易所的时间, 未撤单时为零值
+	cancelAt time.Time
+	active   bool
+	// 同价位排在前面的数量
+	queue decimal.Decimal
+	// 冻结余额, 买单为报价币种, 卖单为交易币种
+	frozen decimal.Decimal
+}
+
+func (o *order) left() decimal.Decimal {
+	return o.Amount.Sub(o.Filled)
+}
+
+func (o *order) finished() bool {
+	switch o.Status {
+	case paper.StatusFilled, paper.StatusCanceled, paper.StatusPartCanceled:
+		return true
+	}
+	return false
+}
+
+func (o *order) snapshot() *Order {
+	cp := o.Order
+	return &cp
+}
+
+// 单一市场的模拟交易所
+type exchange struct {
+	cfg      Config
+	now      time.Time
+	orderID  int64
+	balances map[string]*paper.Balance
+	orders   map[int64]*order
+	// 按下单顺序排列的未完成订单
+	open []*order
+	// 当前深度, 被模拟 taker 吃掉的数量在下一次更新前已扣除
+	asks    [][2]decimal.Decimal
+	bids    [][2]decimal.Decimal
+	hasBook bool
+	// 最新价格, 用于计算权益
+	last decimal.Decimal
+	// 当前事件中状态变化的订单
+	changed []*order
+
+	fills  []Fill
+	equity []EquityPoint
+	ledger ledger
+}
+
+func newExchange(cfg Config) *exchange {
+	x := &exchange{
+		cfg:      cfg,
+		balances: make(map[string]*paper.Balance),
+		orders:   make(map[int64]*order),
+	}
+	for ccy, amount := range cfg.Balances {
+		x.balance(ccy).Available = amount
+	}
+	return x
+}
+
+func (x *exchange) balance(ccy string) *paper.Balance {
+	b, ok := x.balances[ccy]
+	if !ok {
+		b = &paper.Balance{Ccy: ccy}
+		x.balances[ccy] = b
+	}
+	return b
+}
+
+func (x *exchange) Now() time.Time {
+	return x.now
+}
+
+func (x *exchange) Balance(ccy string) paper.Balance {
+	return *x.balance(ccy)
+}
+
+func (x *exchange) Place(o Order) (*Order, error) {
+	r := x.cfg.Rules
+	if o.Side != paper.SideBuy && o.Side != paper.SideSell {
+		return nil, errors.Wrap(ErrInvalidOrder, "side")
+	}
+	switch o.Type {
+	case "":
+		o.Type = paper.TypeLimit
+	case paper.TypeLimit:
+	case paper.TypeMarket:
+		if o.PostOnly {
+			return nil, errors.Wrap(ErrInvalidOrder, "post only market order")
+		}
+		o.Price = decimal.Zero
+	default:
+		return nil, errors.Wrap(ErrInvalidOrder, "type")
+	}
+	if err := r.check(&o, x.last); err != nil {
+		return nil, err
+	}
+
+	// 限价买单冻结报价币种, 卖单冻结交易币种, 市价买单成交时按可用余额截断
+	var b *paper.Balance
+	var need decimal.Decimal
+	if o.Side == paper.SideBuy {
+		b = x.balance(r.Quote)
+		need = o.Price.Mul(o.Amount)
+	} else {
+		b = x.balance(r.Base)
+		need = o.Amount
+	}
+	if b.Available.LessThan(need) {
+		return nil, errors.WithStack(ErrInsufficientBalance)
+	}
+	b.Available = b.Available.Sub(need)
+	b.Frozen = b.Frozen.Add(need)
+
+	x.orderID++
+	ord := &order{
+		Order: Order{
+			ID:        x.orderID,
+			Side:      o.Side,
+			Type:      o.Type,
+			PostOnly:  o.PostOnly,
+			Price:     o.Price,
+			Amount:    o.Amount,
+			Status:    paper.StatusOpen,
+			CreatedAt: x.now,
+			UpdatedAt: x.now,
+		},
+		arrive: x.now.Add(x.cfg.Latency),
+		frozen: need,
+	}
+	x.orders[ord.ID] = ord
+	x.open = append(x.open, ord)
+	return ord.snapshot(), nil
+}
+
+func (x *exchange) Cancel(id int64) error {
+	o, ok := x.orders[id]
+	if !ok {
+		return errors.WithStack(ErrOrderNotFound)
+	}
+	if o.finished() {
+		return errors.WithStack(ErrOrderFinished)
+	}
+	if o.cancelAt.IsZero() {
+		o.cancelAt = x.now.Add(x.cfg.Latency)
+	}
+	return nil
+}
+
+func (x *exchange) Order(id int64) (*Order, error) {
+	o, ok := x.orders[id]
+	if !ok {
+		return nil, errors.WithStack(ErrOrderNotFound)
+	}
+	return o.snapshot(), nil
+}
+
+func (x *exchange) OpenOrders() []*Order {
+	list := make([]*Order, 0, len(x.open))
+	for _, o := range x.open {
+		list = append(list, o.snapshot())
+	}
+	return list
+}
+
+// 处理一个事件: 先处理到达的撤单, 再处理到达的下单, 最后用事件撮合挂单, 返回状态变化的订单
+func (x *exchange) apply(e Event) []*Order {
+	x.changed = x.changed[:0]
+	if book, ok := e.(*Book); ok {
+		x.asks = append([][2]decimal.Decimal(nil), book.Asks...)
+		x.bids = append([][2]decimal.Decimal(nil), book.Bids...)
+		x.hasBook = true
+	}
+
+	for _, o := range x.open {
+		if !o.cancelAt.IsZero() && !o.cancelAt.After(x.now) {
+			x.finish(o, paper.StatusCanceled)
+		}
+	}
+	x.prune()
+	for _, o := range x.open {
+		if !o.active && !o.arrive.After(x.now) {
+			x.arriveOrder(o, e)
+		}
+	}
+	x.prune()
+	for _, o := range x.open {
+		if o.active {
+			x.match(o, e)
+		}
+	}
+	x.prune()
+
+	switch e := e.(type) {
+	case *Book:
+		switch {
+		case len(e.Asks) > 0 && len(e.Bids) > 0:
+			x.last = e.Asks[0][0].Add(e.Bids[0][0]).Div(decimal.NewFromInt(2))
+		case len(e.Asks) > 0:
+			x.last = e.Asks[0][0]
+		case len(e.Bids) > 0:
+			x.last = e.Bids[0][0]
+		}
+	case *Trade:
+		x.last = e.Price
+	case *Bar:
+		x.last = e.Close
+	}
+
+	seen := make(map[int64]bool, len(x.changed))
+	list := make([]*Order, 0, len(x.changed))
+	for _, o := range x.changed {
+		if !seen[o.ID] {
+			seen[o.ID] = true
+			list = append(list, o.snapshot())
+		}
+	}
+	return list
+}
+
+func (x *exchange) prune() {
+	open := x.open[:0]
+	for _, o := range x.open {
+		if !o.finished() {
+			open = append(open, o)
+		}
+	}
+	x.open = open
+}
+
+// 订单到达交易所, 可以立即成交的部分作为 taker 成交, 剩余部分挂单或撤销
+func (x *exchange) arriveOrder(o *order, e Event) {
+	o.active = true
+	levels := x.opposite(o, e)
+	if o.PostOnly && len(*levels) > 0 && crosses(o, (*levels)[0][0]) {
+		x.finish(o, paper.StatusCanceled)
+		return
+	}
+
+	book := *levels
+	for len(book) > 0 && o.left().IsPositive() {
+		lv := &book[0]
+		if !crosses(o, lv[0]) {
+			break
+		}
+		n := x.fill(o, lv[0], decimal.Min(lv[1], o.left()), false)
+		if !n.IsPositive() {
+			break
+		}
+		lv[1] = lv[1].Sub(n)
+		if lv[1].IsPositive() {
+			break
+		}
+		book = book[1:]
+	}
+	*levels = book
+
+	if o.finished() {
+		return
+	}
+	if o.Type == paper.TypeMarket {
+		x.finish(o, paper.StatusCanceled)
+		return
+	}
+	if _, ok := e.(*Bar); !ok && x.hasBook {
+		o.queue = amountAt(x.own(o), o.Price)
+	}
+}
+
+// 对手盘: 有深度时为当前深度, 按 K 线回测或只有成交时为事件价格上不限数量的一档
+func (x *exchange) opposite(o *order, e Event) *[][2]decimal.Decimal {
+	var price decimal.Decimal
+	switch e := e.(type) {
+	case *Bar:
+		price = e.Open
+	case *Trade:
+		if !x.hasBook {
+			price = e.Price
+		}
+	}
+	if price.IsPositive() {
+		one := [][2]decimal.Decimal{{price, o.left()}}
+		return &one
+	}
+	if o.Side == paper.SideBuy {
+		return &x.asks
+	}
+	return &x.bids
+}
+
+func (x *exchange) own(o *order) [][2]decimal.Decimal {
+	if o.Side == paper.SideBuy {
+		return x.bids
+	}
+	return x.asks
+}
+
+// 对手价格是否可以与订单成交
+func crosses(o *order, price decimal.Decimal) bool {
+	if o.Type == paper.TypeMarket {
+		return true
+	}
+	if o.Side == paper.SideBuy {
+		return price.LessThanOrEqual(o.Price)
+	}
+	return price.GreaterThanOrEqual(o.Price)
+}
+
+// 穿过挂单价格
+func through(o *order, price decimal.Decimal) bool {
+	if o.Side == paper.SideBuy {
+		return price.LessThan(o.Price)
+	}
+	return price.GreaterThan(o.Price)
+}
+
+func amountAt(levels [][2]decimal.Decimal, price decimal.Decimal) decimal.Decimal {
+	for _, lv := range levels {
+		if lv[0].Equal(price) {
+			return lv[1]
+		}
+	}
+	return decimal.Zero
+}
+
+// 先消耗排在前面的数量, 返回轮到订单的数量
+func (o *order) dequeue(amount decimal.Decimal) decimal.Decimal {
+	used := decimal.Min(o.queue, amount)
+	o.queue = o.queue.Sub(used)
+	return amount.Sub(used)
+}
+
+// 用事件撮合挂单, 按挂单价格作为 maker 成交
+func (x *exchange) match(o *order, e Event) {
+	switch e := e.(type) {
+	case *Book:
+		// 前面的订单撤销时排队位置前移
+		o.queue = decimal.Min(o.queue, amountAt(x.own(o), o.Price))
+		levels := &x.asks
+		if o.Side == paper.SideSell {
+			levels = &x.bids
+		}
+		book := *levels
+		for len(book) > 0 && o.left().IsPositive() {
+			lv := &book[0]
+			if !crosses(o, lv[0]) {
+				break
+			}
+			before := o.queue
+			n := decimal.Min(o.dequeue(lv[1]), o.left())
+			if n.IsPositive() {
+				x.fill(o, o.Price, n, true)
+			}
+			lv[1] = lv[1].Sub(before.Sub(o.queue)).Sub(n)
+			if lv[1].IsPositive() {
+				break
+			}
+			book = book[1:]
+		}
+		*levels = book
+	case *Trade:
+		// 同方向的 taker 不会与挂单成交
+		if e.Side == o.Side || !crosses(o, e.Price) {
+			return
+		}
+		n := o.left()
+		if !through(o, e.Price) {
+			n = decimal.Min(o.dequeue(e.Amount), n)
+		}
+		if n.IsPositive() {
+			x.fill(o, o.Price, n, true)
+		}
+	case *Bar:
+		extreme := e.Low
+		if o.Side == paper.SideSell {
+			extreme = e.High
+		}
+		if through(o, extreme) || x.cfg.FillOnTouch && extreme.Equal(o.Price) {
+			x.fill(o, o.Price, o.left(), true)
+		}
+	}
+}
+
+// 成交并结算余额, 市价买单按可用余额截断成交数量, 返回实际成交数量
+func (x *exchange) fill(o *order, price, amount decimal.Decimal, maker bool) decimal.Decimal {
+	r := x.cfg.Rules
+	rate := r.TakerFeeRate
+	if maker {
+		rate = r.MakerFeeRate
+	}
+	base := x.balance(r.Base)
+	quote := x.balance(r.Quote)
+
+	if o.Side == paper.SideBuy && o.Type == paper.TypeMarket {
+		afford := quote.Available.Div(price).Truncate(r.AmountPrecision)
+		amount = decimal.Min(amount, afford)
+	}
+	if !amount.IsPositive() {
+		return decimal.Zero
+	}
+
+	value := price.Mul(amount)
+	var fee decimal.Decimal
+	if o.Side == paper.SideBuy {
+		if o.Type == paper.TypeLimit {
+			release := o.Price.Mul(amount)
+			o.frozen = o.frozen.Sub(release)
+			quote.Frozen = quote.Frozen.Sub(release)
+			quote.Available = quote.Available.Add(release).Sub(value)
+		} else {
+			quote.Available = quote.Available.Sub(value)
+		}
+		fee = amount.Mul(rate)
+		base.Available = base.Available.Add(amount).Sub(fee)
+		o.FeeCcy = r.Base
+	} else {
+		o.frozen = o.frozen.Sub(amount)
+		base.Frozen = base.Frozen.Sub(amount)
+		fee = value.Mul(rate)
+		quote.Available = quote.Available.Add(value).Sub(fee)
+		o.FeeCcy = r.Quote
+	}
+
+	o.Filled = o.Filled.Add(amount)
+	o.FilledValue = o.FilledValue.Add(value)
+	o.Fee = o.Fee.Add(fee)
+	o.UpdatedAt = x.now
+	o.Status = paper.StatusPartFilled
+	x.changed = append(x.changed, o)
+
+	f := Fill{
+		OrderID: o.ID,
+		Time:    x.now,
+		Side:    o.Side,
+		Price:   price,
+		Amount:  amount,
+		Fee:     fee,
+		FeeCcy:  o.FeeCcy,
+		Maker:   maker,
+	}
+	x.fills = append(x.fills, f)
+	x.ledger.fill(x.initialBase(), f)
+
+	if !o.left().IsPositive() {
+		x.finish(o, paper.StatusFilled)
+	}
+	return amount
+}
+
+// 结束订单并退回剩余冻结余额
+func (x *exchange) finish(o *order, status string) {
+	if status == paper.StatusCanceled && o.Filled.IsPositive() {
+		status = paper.StatusPartCanceled
+	}
+	if o.frozen.IsPositive() {
+		b := x.balance(x.cfg.Rules.Base)
+		if o.Side == paper.SideBuy {
+			b = x.balance(x.cfg.Rules.Quote)
+		}
+		b.Frozen = b.Frozen.Sub(o.frozen)
+		b.Available = b.Available.Add(o.frozen)
+		o.frozen = decimal.Zero
+	}
+	o.Status = status
+	o.UpdatedAt = x.now
+	x.changed = append(x.changed, o)
+}
+
+func (x *exchange) initialBase() decimal.Decimal {
+	return x.cfg.Balances[x.cfg.Rules.Base]
+}
+
+// 按最新价格记录权益, 同一时间只保留最后一个点
+func (x *exchange) mark() {
+	if !x.last.IsPositive() {
+		return
+	}
+	x.ledger.start(x.initialBase(), x.last)
+	base := x.balance(x.cfg.Rules.Base)
+	quote := x.balance(x.cfg.Rules.Quote)
+	equity := base.Available.Add(base.Frozen).Mul(x.last).Add(quote.Available).Add(quote.Frozen)
+	if n := len(x.equity); n > 0 && x.equity[n-1].Time.Equal(x.now) {
+		x.equity[n-1].Equity = equity
+		return
+	}
+	x.equity = append(x.equity, EquityPoint{Time: x.now, Equity: equity})
+}
@@ -0,0 +1,163 @@
+package backtest
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/icwl/go-exchange-api/paper"
+	"github.com/shopspring/decimal"
+)
+
+// 资金曲线上的一个点, 权益按最新价格折算为报价币种
+type EquityPoint struct {
+	Time   time.Time
+	Equity decimal.Decimal
+}
+
+type Result struct {
+	// 资金曲线
+	Equity []EquityPoint
+	// 成交记录, 按时间升序
+	Fills []Fill
+	// 全部订单, 按 ID 升序
+	Orders []*Order
+	Stats  Stats
+}
+
+type Stats struct {
+	StartEquity decimal.Decimal
+	EndEquity   decimal.Decimal
+	// 收益率
+	Return float64
+	// 最大回撤比例
+	MaxDrawdown float64
+	// 资金曲线相邻两点收益率的均值除以标准差, 未年化
+	Sharpe float64
+	// 成交次数
+	Fills int
+	// 报价币种成交额
+	Volume decimal.Decimal
+	// 手续费, 按成交价格折算为报价币种
+	Fees decimal.Decimal
+	// 按平均成本计算的已实现盈亏, 已扣除手续费
+	RealizedPnL decimal.Decimal
+	// 卖出成交中盈利的比例
+	WinRate float64
+}
+
+// 按平均成本跟踪持仓, 初始持仓的成本为第一个价格
+type ledger struct {
+	started  bool
+	position decimal.Decimal
+	cost     decimal.Decimal
+	realized decimal.Decimal
+	sells    int
+	wins     int
+	fees     decimal.Decimal
+	volume   decimal.Decimal
+}
+
+func (l *ledger) start(base, price decimal.Decimal) {
+	if l.started {
+		return
+	}
+	l.started = true
+	l.position = base
+	l.cost = base.Mul(price)
+}
+
+func (l *ledger) fill(base decimal.Decimal, f Fill) {
+	l.start(base, f.Price)
+	value := f.Price.Mul(f.Amount)
+	l.volume = l.volume.Add(value)
+	if f.Side == paper.SideBuy {
+		l.fees = l.fees.Add(f.Fee.Mul(f.Price))
+		l.position = l.position.Add(f.Amount).Sub(f.Fee)
+		l.cost = l.cost.Add(value)
+		return
+	}
+
+	l.fees = l.fees.Add(f.Fee)
+	if !l.position.IsPositive() {
+		return
+	}
+	amount := decimal.Min(f.Amount, l.position)
+	avg := l.cost.Div(l.position)
+	pnl := f.Price.Sub(avg).Mul(amount).Sub(f.Fee)
+	l.realized = l.realized.Add(pnl)
+	l.sells++
+	if pnl.IsPositive() {
+		l.wins++
+	}
+	l.cost = l.cost.Sub(avg.Mul(amount))
+	l.position = l.position.Sub(amount)
+}
+
+func (x *exchange) result() *Result {
+	orders := make([]*Order, 0, len(x.orders))
+	for _, o := range x.orders {
+		orders = append(orders, o.snapshot())
+	}
+	sort.Slice(orders, func(i, j int) bool {
+		return orders[i].ID < orders[j].ID
+	})
+	return &Result{
+		Equity: x.equity,
+		Fills:  x.fills,
+		Orders: orders,
+		Stats:  stats(x.equity, &x.ledger, len(x.fills)),
+	}
+}
+
+func stats(equity []EquityPoint, l *ledger, fills int) Stats {
+	s := Stats{
+		Fills:       fills,
+		Volume:      l.volume,
+		Fees:        l.fees,
+		RealizedPnL: l.realized,
+	}
+	if l.sells > 0 {
+		s.WinRate = float64(l.wins) / float64(l.sells)
+	}
+	if len(equity) == 0 {
+		return s
+	}
+	s.StartEquity = equity[0].Equity
+	s.EndEquity = equity[len(equity)-1].Equity
+	if s.StartEquity.IsPositive() {
+		s.Return = s.EndEquity.Div(s.StartEquity).Sub(decimal.NewFromInt(1)).InexactFloat64()
+	}
+
+	peak := equity[0].Equity
+	returns := make([]float64, 0, len(equity))
+	for i, p := range equity {
+		if p.Equity.GreaterThan(peak) {
+			peak = p.Equity
+		}
+		if peak.IsPositive() {
+			if dd := peak.Sub(p.Equity).Div(peak).InexactFloat64(); dd > s.MaxDrawdown {
+				s.MaxDrawdown = dd
+			}
+		}
+		if i > 0 && equity[i-1].Equity.IsPositive() {
+			returns = append(returns, p.Equity.Div(equity[i-1].Equity).InexactFloat64()-1)
+		}
+	}
+
+	if len(returns) > 1 {
+		var mean, variance float64
+		for _, r := range returns {
+			mean += r
+		}
+		mean /= float64(len(returns))
+		for _, r := range returns {
+			variance += (r - mean) * (r - mean)
+		}
+		variance /= float64(len(returns) - 1)
+		if variance > 0 {
+			s.Sharpe = mean / math.Sqrt(variance)
+		}
+	}
+	return s
+}
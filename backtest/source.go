@@ -0,0 +1,175 @@
+package backtest
+
+import (
+	"io"
+	"sort"
+	"time"
+
+	coinex "github.com/icwl/go-exchange-api/coinex/v2"
+	gate "github.com/icwl/go-exchange-api/gate/v4"
+	"github.com/shopspring/decimal"
+)
+
+type sliceSource struct {
+	events []Event
+	i      int
+}
+
+func (s *sliceSource) Next() (Event, error) {
+	if s.i >= len(s.events) {
+		return nil, io.EOF
+	}
+	e := s.events[s.i]
+	s.i++
+	return e, nil
+}
+
+// 内存中的事件, 按时间稳定排序
+func Events(events ...Event) Source {
+	list := append([]Event(nil), events...)
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Time().Before(list[j].Time())
+	})
+	return &sliceSource{events: list}
+}
+
+// CoinEx K 线, created_at 为毫秒级开盘时间, period 为 K 线周期
+func CoinExKLines(klines []*coinex.SpotKLine, period time.Duration) Source {
+	events := make([]Event, 0, len(klines))
+	for _, k := range klines {
+		start := time.UnixMilli(k.CreatedAt)
+		events = append(events, &Bar{
+			Start:  start,
+			End:    start.Add(period),
+			Open:   k.Open,
+			High:   k.High,
+			Low:    k.Low,
+			Close:  k.Close,
+			Volume: k.Volume,
+		})
+	}
+	return Events(events...)
+}
+
+// Gate K 线, t 为秒级开盘时间, period 为 K 线周期
+func GateCandlesticks(candles []*gate.Candlestick, period time.Duration) Source {
+	events := make([]Event, 0, len(candles))
+	for _, c := range candles {
+		start := time.Unix(c.Time, 0)
+		events = append(events, &Bar{
+			Start:  start,
+			End:    start.Add(period),
+			Open:   c.Open,
+			High:   c.High,
+			Low:    c.Low,
+			Close:  c.Close,
+			Volume: c.Amount,
+		})
+	}
+	return Events(events...)
+}
+
+type coinexReplay struct {
+	r       *coinex.Replay
+	market  string
+	asks    [][2]decimal.Decimal
+	bids    [][2]decimal.Decimal
+	pending []Event
+}
+
+// 回放录制的 CoinEx 深度和成交推送, 事件时间为录制时的接收时间
+// 增量深度合并到最近的全量深度上, 需要关闭 SetFixedPoint, 其它推送和其它市场被忽略
+func CoinExReplay(r *coinex.Replay, market string) Source {
+	return &coinexReplay{r: r, market: market}
+}
+
+func (s *coinexReplay) Next() (Event, error) {
+	for len(s.pending) == 0 {
+		msg, at, err := s.r.ReadWithTime()
+		if err != nil {
+			return nil, err
+		}
+		switch msg := msg.(type) {
+		case *coinex.SpotDepth:
+			if msg.Market != s.market {
+				continue
+			}
+			if msg.IsFull {
+				s.asks = append([][2]decimal.Decimal(nil), msg.Depth.Asks...)
+				s.bids = append([][2]decimal.Decimal(nil), msg.Depth.Bids...)
+			} else {
+				s.asks = merge(s.asks, msg.Depth.Asks, false)
+				s.bids = merge(s.bids, msg.Depth.Bids, true)
+			}
+			s.pending = append(s.pending, &Book{At: at, Asks: s.asks, Bids: s.bids})
+		case *coinex.SpotDeals:
+			if msg.Market != s.market {
+				continue
+			}
+			// 推送按时间倒序
+			for i := len(msg.DealList) - 1; i >= 0; i-- {
+				deal := msg.DealList[i]
+				s.pending = append(s.pending, &Trade{At: at, Side: deal.Side, Price: deal.Price, Amount: deal.Amount})
+			}
+		}
+	}
+	e := s.pending[0]
+	s.pending = s.pending[1:]
+	return e, nil
+}
+
+type gateReplay struct {
+	r    *gate.Replay
+	pair string
+}
+
+// 回放录制的 Gate 订单簿和成交推送, 事件时间为录制时的接收时间
+// 需要关闭 SetFixedPoint, 其它推送和其它交易对被忽略
+func GateReplay(r *gate.Replay, pair string) Source {
+	return &gateReplay{r: r, pair: pair}
+}
+
+func (s *gateReplay) Next() (Event, error) {
+	for {
+		msg, at, err := s.r.ReadWithTime()
+		if err != nil {
+			return nil, err
+		}
+		switch msg := msg.(type) {
+		case *gate.OrderBook:
+			if msg.Pair == s.pair {
+				return &Book{At: at, Asks: msg.Asks, Bids: msg.Bids}, nil
+			}
+		case *gate.Trade:
+			if msg.CurrencyPair == s.pair {
+				return &Trade{At: at, Side: msg.Side, Price: msg.Price, Amount: msg.Amount}, nil
+			}
+		}
+	}
+}
+
+// 合并增量深度, 数量为 0 时删除该价位, desc 为 true 时价格降序
+func merge(levels, updates [][2]decimal.Decimal, desc bool) [][2]decimal.Decimal {
+	list := append([][2]decimal.Decimal(nil), levels...)
+	for _, u := range updates {
+		i := sort.Search(len(list), func(i int) bool {
+			if desc {
+				return list[i][0].LessThanOrEqual(u[0])
+			}
+			return list[i][0].GreaterThanOrEqual(u[0])
+		})
+		found := i < len(list) && list[i][0].Equal(u[0])
+		switch {
+		case u[1].IsZero() && found:
+			list = append(list[:i], list[i+1:]...)
+		case u[1].IsZero():
+		case found:
+			list[i][1] = u[1]
+		default:
+			list = append(list, [2]decimal.Decimal{})
+			copy(list[i+1:], list[i:])
+			list[i] = u
+		}
+	}
+	return list
+}
@@ -0,0 +1,216 @@
+// http 录制回放, 用于离线测试
+//
+// 录制模式下请求真实服务器, 并将请求和响应保存到 json 文件
+// 回放模式下按方法, URL 和请求体返回录制的响应, 不访问网络
+// 文件也可以手工编写, 格式与录制结果相同
+// 请求头不保存, 认证信息 (KEY, SIGN 等) 不会写入文件, 其他敏感字符串通过 Scrub 替换
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+type Mode int
+
+const (
+	// 回放录制的响应
+	ModeReplay Mode = iota
+	// 请求真实服务器并录制
+	ModeRecord
+)
+
+// 敏感字符串替换后的内容
+const Redacted = "REDACTED"
+
+var ErrNotFound = errors.New("cassette: interaction not found")
+
+type Request struct {
+	Method string `json:"method"`
+	// 路径和查询参数, 不含域名
+	URL  string `json:"url"`
+	Body string `json:"body,omitempty"`
+}
+
+type Response struct {
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        string `json:"body"`
+}
+
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// 实现 http.RoundTripper
+type Cassette struct {
+	name string
+	mode Mode
+	next http.RoundTripper
+
+	lock         *sync.Mutex
+	interactions []*Interaction
+	// 回放时已使用的记录, 相同请求按录制顺序依次返回
+	used   []bool
+	scrubs []string
+}
+
+// 加载或创建录制文件
+// - name 文件路径, 如 testdata/cassettes/TestHTTPClient_SpotMarket.json
+// - next 录制模式下实际发送请求的 RoundTripper, 为 nil 时使用 http.DefaultTransport
+func New(name string, mode Mode, next http.RoundTripper) (*Cassette, error) {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	c := &Cassette{
+		name:         name,
+		mode:         mode,
+		next:         next,
+		lock:         new(sync.Mutex),
+		interactions: make([]*Interaction, 0),
+	}
+
+	if mode == ModeReplay {
+		b, err := os.ReadFile(name)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if err := json.Unmarshal(b, &c.interactions); err != nil {
+			return nil, errors.Wrap(err, name)
+		}
+		c.used = make([]bool, len(c.interactions))
+	}
+	return c, nil
+}
+
+// 录制时将 values 替换为 Redacted, 空字符串忽略
+func (c *Cassette) Scrub(values ...string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, v := range values {
+		if v != "" {
+			c.scrubs = append(c.scrubs, v)
+		}
+	}
+}
+
+// 使用该录制的 http.Client
+func (c *Cassette) Client() *http.Client {
+	return &http.Client{Transport: c}
+}
+
+func (c *Cassette) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		_ = req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	if c.mode == ModeRecord {
+		return c.record(req, body)
+	}
+	return c.replay(req, body)
+}
+
+// 录制模式下保存到文件, 回放模式下无操作
+func (c *Cassette) Stop() error {
+	if c.mode != ModeRecord {
+		return nil
+	}
+
+	c.lock.Lock()
+	b, err := json.MarshalIndent(c.interactions, "", "  ")
+	c.lock.Unlock()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(c.name), 0o755); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(os.WriteFile(c.name, append(b, '\n'), 0o644))
+}
+
+func (c *Cassette) record(req *http.Request, body []byte) (*http.Response, error) {
+	resp, err := c.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	c.lock.Lock()
+	c.interactions = append(c.interactions, &Interaction{
+		Request: Request{
+			Method: req.Method,
+			URL:    c.scrub(req.URL.RequestURI()),
+			Body:   c.scrub(string(body)),
+		},
+		Response: Response{
+			Status:      resp.StatusCode,
+			ContentType: resp.Header.Get("Content-Type"),
+			Body:        c.scrub(string(respBody)),
+		},
+	})
+	c.lock.Unlock()
+
+	return resp, nil
+}
+
+func (c *Cassette) replay(req *http.Request, body []byte) (*http.Response, error) {
+	uri := req.URL.RequestURI()
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	// 与录制时相同, 请求体中的敏感字符串替换后再比较
+	reqBody := c.scrub(string(body))
+	for i, item := range c.interactions {
+		if c.used[i] || item.Request.Method != req.Method || item.Request.URL != uri || item.Request.Body != reqBody {
+			continue
+		}
+		c.used[i] = true
+
+		header := make(http.Header)
+		if item.Response.ContentType != "" {
+			header.Set("Content-Type", item.Response.ContentType)
+		}
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", item.Response.Status, http.StatusText(item.Response.Status)),
+			StatusCode:    item.Response.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          io.NopCloser(strings.NewReader(item.Response.Body)),
+			ContentLength: int64(len(item.Response.Body)),
+			Request:       req,
+		}, nil
+	}
+	return nil, errors.Wrapf(ErrNotFound, "%s %s %s in %s", req.Method, uri, reqBody, c.name)
+}
+
+func (c *Cassette) scrub(s string) string {
+	for _, v := range c.scrubs {
+		s = strings.ReplaceAll(s, v, Redacted)
+	}
+	return s
+}
@@ -0,0 +1,95 @@
+package cassette
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCassette(t *testing.T) {
+	n := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"key":"`+r.Header.Get("KEY")+`","n":`+string(rune('0'+n))+`}`)
+	}))
+	defer srv.Close()
+
+	name := filepath.Join(t.TempDir(), "cassettes", "test.json")
+	rec, err := New(name, ModeRecord, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.Scrub("my-key", "")
+
+	get := func(cli *http.Client, path string) (string, error) {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+		req.Header.Set("KEY", "my-key")
+		resp, err := cli.Do(req)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		return string(b), err
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := get(rec.Client(), "/a?x=1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	post := func(cli *http.Client, body string) error {
+		resp, err := cli.Post(srv.URL+"/b", "application/json", strings.NewReader(body))
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+	if err := post(rec.Client(), `{"amount":"1"}`); err != nil {
+		t.Fatal(err)
+	}
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), "my-key") {
+		t.Fatalf("secret not scrubbed: %s", b)
+	}
+
+	// 回放不访问服务器, 相同请求按录制顺序返回
+	srv.Close()
+	play, err := New(name, ModeReplay, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`{"key":"REDACTED","n":1}`, `{"key":"REDACTED","n":2}`} {
+		got, err := get(play.Client(), "/a?x=1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Fatalf("got %s, want %s", got, want)
+		}
+	}
+	if _, err := get(play.Client(), "/a?x=1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("got %v, want ErrNotFound", err)
+	}
+
+	// 请求体不同时不匹配
+	if err := post(play.Client(), `{"amount":"2"}`); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("got %v, want ErrNotFound", err)
+	}
+	if err := post(play.Client(), `{"amount":"1"}`); err != nil {
+		t.Fatal(err)
+	}
+}
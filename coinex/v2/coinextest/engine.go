@@ -0,0 +1,293 @@
+package coinextest
+
+import (
+	"sort"
+	"time"
+
+	coinex "github.com/icwl/go-exchange-api/coinex/v2"
+	"github.com/shopspring/decimal"
+)
+
+// 账户
+type account struct {
+	key    string
+	secret string
+	// 币种 -> 余额, Frozen 为挂单冻结
+	balances map[string]*coinex.SpotBalance
+	// 全部订单, 包括已完成订单
+	orders []*order
+}
+
+func (a *account) balance(ccy string) *coinex.SpotBalance {
+	b, ok := a.balances[ccy]
+	if !ok {
+		b = &coinex.SpotBalance{Ccy: ccy}
+		a.balances[ccy] = b
+	}
+	return b
+}
+
+type order struct {
+	*coinex.SpotOrder
+	owner *account
+	// 买单按委托价冻结的报价币种, 成交价优于委托价时退回差额
+	frozen decimal.Decimal
+}
+
+func (o *order) finished() bool {
+	switch o.Status {
+	case coinex.OrderStatusFilled, coinex.OrderStatusCanceled, coinex.OrderStatusPartCanceled:
+		return true
+	}
+	return false
+}
+
+func (o *order) snapshot() *coinex.SpotOrder {
+	cp := *o.SpotOrder
+	return &cp
+}
+
+// 成交记录
+type deal struct {
+	*coinex.SpotDeal
+	market string
+}
+
+// 单个市场的订单簿, 价格优先时间优先
+type book struct {
+	market *coinex.SpotMarket
+	// 卖单价格升序, 买单价格降序, 同价格按时间排序
+	asks []*order
+	bids []*order
+	last decimal.Decimal
+}
+
+func (b *book) side(side string) *[]*order {
+	if side == "buy" {
+		return &b.bids
+	}
+	return &b.asks
+}
+
+// 与 taker 方向相反的挂单
+func (b *book) opposite(side string) *[]*order {
+	if side == "buy" {
+		return &b.asks
+	}
+	return &b.bids
+}
+
+func crosses(taker *order, maker *order) bool {
+	if taker.Type == coinex.OrderTypeMarket {
+		return true
+	}
+	if taker.Side == "buy" {
+		return taker.Price.GreaterThanOrEqual(maker.Price)
+	}
+	return taker.Price.LessThanOrEqual(maker.Price)
+}
+
+func (b *book) insert(o *order) {
+	list := b.side(o.Side)
+	i := sort.Search(len(*list), func(i int) bool {
+		if o.Side == "buy" {
+			return (*list)[i].Price.LessThan(o.Price)
+		}
+		return (*list)[i].Price.GreaterThan(o.Price)
+	})
+	*list = append(*list, nil)
+	copy((*list)[i+1:], (*list)[i:])
+	(*list)[i] = o
+}
+
+func (b *book) remove(o *order) bool {
+	list := b.side(o.Side)
+	for i, item := range *list {
+		if item == o {
+			*list = append((*list)[:i], (*list)[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// 可立即成交的数量
+func (b *book) available(taker *order) decimal.Decimal {
+	total := decimal.Zero
+	for _, maker := range *b.opposite(taker.Side) {
+		if !crosses(taker, maker) {
+			break
+		}
+		total = total.Add(maker.UnfilledAmount)
+	}
+	return total
+}
+
+// 按价格合并的深度
+func (b *book) levels(list []*order, limit int) [][2]decimal.Decimal {
+	levels := make([][2]decimal.Decimal, 0)
+	for _, o := range list {
+		n := len(levels)
+		if n > 0 && levels[n-1][0].Equal(o.Price) {
+			levels[n-1][1] = levels[n-1][1].Add(o.UnfilledAmount)
+			continue
+		}
+		if limit > 0 && n >= limit {
+			break
+		}
+		levels = append(levels, [2]decimal.Decimal{o.Price, o.UnfilledAmount})
+	}
+	return levels
+}
+
+func (b *book) depth(limit int) *coinex.SpotDepth {
+	dp := &coinex.SpotDepth{
+		IsFull: true,
+		Market: b.market.Market,
+	}
+	dp.Depth.Asks = b.levels(b.asks, limit)
+	dp.Depth.Bids = b.levels(b.bids, limit)
+	dp.Depth.Last = b.last
+	dp.Depth.UpdatedAt = time.Now().UnixMilli()
+	return dp
+}
+
+// 撮合结果, 用于推送
+type result struct {
+	// 状态变化的订单及事件类型
+	events []*coinex.SpotOrderEvent
+	owners []*account
+	deals  []*coinex.SpotDeal
+}
+
+func (r *result) add(event string, o *order) {
+	r.events = append(r.events, &coinex.SpotOrderEvent{Event: event, Order: o.snapshot()})
+	r.owners = append(r.owners, o.owner)
+}
+
+// 撮合 taker, 成交价为 maker 价格
+// 市价买单的数量为交易币种数量, 报价币种不足时停止撮合
+func (b *book) match(taker *order, res *result, nextDealID func() int64) {
+	base := b.market.BaseCcy
+	quote := b.market.QuoteCcy
+	opposite := b.opposite(taker.Side)
+
+	for len(*opposite) > 0 && taker.UnfilledAmount.IsPositive() {
+		maker := (*opposite)[0]
+		if !crosses(taker, maker) {
+			break
+		}
+
+		qty := decimal.Min(taker.UnfilledAmount, maker.UnfilledAmount)
+		price := maker.Price
+		if taker.Type == coinex.OrderTypeMarket && taker.Side == "buy" {
+			// 市价买单按可用余额限制成交数量
+			avail := taker.owner.balance(quote).Available
+			affordable := avail.Div(price).Truncate(b.market.BaseCcyPrecision)
+			qty = decimal.Min(qty, affordable)
+			if !qty.IsPositive() {
+				break
+			}
+		}
+
+		buyer, seller := taker, maker
+		if taker.Side == "sell" {
+			buyer, seller = maker, taker
+		}
+		b.fill(buyer, seller, qty, price, base, quote, buyer == taker)
+
+		b.last = price
+		now := time.Now().UnixMilli()
+		res.deals = append(res.deals, &coinex.SpotDeal{
+			DealID:    nextDealID(),
+			CreatedAt: now,
+			Side:      taker.Side,
+			Price:     price,
+			Amount:    qty,
+		})
+
+		if maker.UnfilledAmount.IsZero() {
+			maker.Status = coinex.OrderStatusFilled
+			*opposite = (*opposite)[1:]
+			res.add("finish", maker)
+		} else {
+			maker.Status = coinex.OrderStatusPartFilled
+			res.add("update", maker)
+		}
+	}
+}
+
+// 结算一笔成交, 交易币种和报价币种分别扣除对应方向的手续费
+func (b *book) fill(buyer, seller *order, qty, price decimal.Decimal, base, quote string, buyerIsTaker bool) {
+	value := qty.Mul(price)
+	now := time.Now().UnixMilli()
+
+	buyerRate, sellerRate := b.market.MakerFeeRate, b.market.TakerFeeRate
+	if buyerIsTaker {
+		buyerRate, sellerRate = b.market.TakerFeeRate, b.market.MakerFeeRate
+	}
+
+	// 买方: 扣除报价币种, 收到交易币种减手续费
+	bq := buyer.owner.balance(quote)
+	if buyer.Type == coinex.OrderTypeMarket {
+		bq.Available = bq.Available.Sub(value)
+	} else {
+		reserved := qty.Mul(buyer.Price)
+		bq.Frozen = bq.Frozen.Sub(reserved)
+		buyer.frozen = buyer.frozen.Sub(reserved)
+		bq.Available = bq.Available.Add(reserved.Sub(value))
+	}
+	buyFee := qty.Mul(buyerRate)
+	bb := buyer.owner.balance(base)
+	bb.Available = bb.Available.Add(qty.Sub(buyFee))
+	buyer.BaseFee = buyer.BaseFee.Add(buyFee)
+
+	// 卖方: 扣除冻结的交易币种, 收到报价币种减手续费
+	sb := seller.owner.balance(base)
+	if seller.Type == coinex.OrderTypeMarket {
+		sb.Available = sb.Available.Sub(qty)
+	} else {
+		sb.Frozen = sb.Frozen.Sub(qty)
+	}
+	sellFee := value.Mul(sellerRate)
+	sq := seller.owner.balance(quote)
+	sq.Available = sq.Available.Add(value.Sub(sellFee))
+	seller.QuoteFee = seller.QuoteFee.Add(sellFee)
+
+	for _, o := range []*order{buyer, seller} {
+		o.UnfilledAmount = o.UnfilledAmount.Sub(qty)
+		o.FilledAmount = o.FilledAmount.Add(qty)
+		o.FilledValue = o.FilledValue.Add(value)
+		o.LastFillAmount = qty.String()
+		o.LastFillPrice = price.String()
+		o.UpdatedAt = now
+	}
+}
+
+// 撤单并退回冻结余额
+func (b *book) cancel(o *order) {
+	b.remove(o)
+	b.release(o)
+	if o.FilledAmount.IsPositive() {
+		o.Status = coinex.OrderStatusPartCanceled
+	} else {
+		o.Status = coinex.OrderStatusCanceled
+	}
+	o.UpdatedAt = time.Now().UnixMilli()
+}
+
+func (b *book) release(o *order) {
+	if o.Type == coinex.OrderTypeMarket {
+		return
+	}
+	if o.Side == "buy" {
+		q := o.owner.balance(b.market.QuoteCcy)
+		q.Frozen = q.Frozen.Sub(o.frozen)
+		q.Available = q.Available.Add(o.frozen)
+		o.frozen = decimal.Zero
+		return
+	}
+	base := o.owner.balance(b.market.BaseCcy)
+	base.Frozen = base.Frozen.Sub(o.UnfilledAmount)
+	base.Available = base.Available.Add(o.UnfilledAmount)
+}
@@ -0,0 +1,445 @@
+package coinextest
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	coinex "github.com/icwl/go-exchange-api/coinex/v2"
+	"github.com/shopspring/decimal"
+)
+
+type handler func(a *account, query map[string]string, body map[string]interface{}) (interface{}, error)
+
+type route struct {
+	method string
+	auth   bool
+	handle handler
+}
+
+func (s *Server) routes() map[string]route {
+	return map[string]route{
+		"/v2/spot/market":                        {http.MethodGet, false, s.handleMarket},
+		"/v2/spot/depth":                         {http.MethodGet, false, s.handleDepth},
+		"/v2/spot/kline":                         {http.MethodGet, false, s.handleKLine},
+		"/v2/spot/deals":                         {http.MethodGet, false, s.handleDeals},
+		"/v2/spot/order":                         {http.MethodPost, true, s.handleOrder},
+		"/v2/spot/cancel-order":                  {http.MethodPost, true, s.handleCancelOrder},
+		"/v2/spot/order-status":                  {http.MethodGet, true, s.handleOrderStatus},
+		"/v2/spot/pending-order":                 {http.MethodGet, true, s.handlePendingOrder},
+		"/v2/spot/finished-order":                {http.MethodGet, true, s.handleFinishedOrder},
+		"/v2/assets/spot/balance":                {http.MethodGet, true, s.handleBalance},
+		"/v2/assets/info":                        {http.MethodGet, false, s.handleInfo},
+		"/v2/assets/deposit-withdraw-config":     {http.MethodGet, false, s.handleDepositWithdrawConfig},
+		"/v2/assets/all-deposit-withdraw-config": {http.MethodGet, false, s.handleAllDepositWithdrawConfig},
+		"/v2/assets/deposit-address":             {http.MethodGet, true, s.handleDepositAddress},
+		"/v2/assets/withdraw":                    {http.MethodPost, true, s.handleWithdraw},
+	}
+}
+
+func (s *Server) serveREST(w http.ResponseWriter, r *http.Request) {
+	rt, ok := s.routes()[r.URL.Path]
+	if !ok || rt.method != r.Method {
+		http.NotFound(w, r)
+		return
+	}
+
+	reqBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var a *account
+	if rt.auth {
+		a, err = s.authenticate(r, reqBody)
+		if err != nil {
+			writeReply(w, nil, err)
+			return
+		}
+	}
+
+	query := make(map[string]string)
+	for k, v := range r.URL.Query() {
+		query[k] = v[0]
+	}
+	body := make(map[string]interface{})
+	if len(reqBody) > 0 {
+		d := json.NewDecoder(strings.NewReader(string(reqBody)))
+		d.UseNumber()
+		if err := d.Decode(&body); err != nil {
+			writeReply(w, nil, newAPIError(CodeInvalidArgument, "invalid json body"))
+			return
+		}
+	}
+
+	data, err := rt.handle(a, query, body)
+	writeReply(w, data, err)
+}
+
+// 校验签名, 与 coinex.Sign 一致
+func (s *Server) authenticate(r *http.Request, body []byte) (*account, error) {
+	a, ok := s.account(r.Header.Get("X-COINEX-KEY"))
+	if !ok {
+		return nil, newAPIError(CodeSignatureIncorrect, "access id not found")
+	}
+	timestamp := r.Header.Get("X-COINEX-TIMESTAMP")
+	want := coinex.Sign(r.Method, r.URL.RequestURI(), string(body), timestamp, a.secret)
+	if r.Header.Get("X-COINEX-SIGN") != want {
+		return nil, newAPIError(CodeSignatureIncorrect, "signature incorrect")
+	}
+	return a, nil
+}
+
+func writeReply(w http.ResponseWriter, data interface{}, err error) {
+	reply := map[string]interface{}{"code": 0, "data": data, "message": "OK"}
+	if p, ok := data.(pageReply); ok {
+		reply["data"] = p.data
+		reply["pagination"] = map[string]interface{}{"has_next": p.hasNext}
+	}
+	if err != nil {
+		code := CodeInvalidArgument
+		if e, ok := err.(*apiError); ok {
+			code = e.code
+		}
+		reply = map[string]interface{}{"code": code, "data": map[string]interface{}{}, "message": err.Error()}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(reply)
+}
+
+// 分页响应, pagination 与 data 同级
+type pageReply struct {
+	data    interface{}
+	hasNext bool
+}
+
+// 分页参数, 默认第 1 页, 每页 10 条
+func paging(query map[string]string) (page, limit int) {
+	page, _ = strconv.Atoi(query["page"])
+	limit, _ = strconv.Atoi(query["limit"])
+	if page <= 0 {
+		page = 1
+	}
+	if limit <= 0 {
+		limit = 10
+	}
+	return page, limit
+}
+
+func (s *Server) handleMarket(_ *account, query map[string]string, _ map[string]interface{}) (interface{}, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	markets := make([]*coinex.SpotMarket, 0)
+	filter := make(map[string]bool)
+	for _, m := range strings.Split(query["market"], ",") {
+		if m != "" {
+			filter[m] = true
+		}
+	}
+	for name, b := range s.books {
+		if len(filter) > 0 && !filter[name] {
+			continue
+		}
+		m := *b.market
+		markets = append(markets, &m)
+	}
+	sort.Slice(markets, func(i, j int) bool { return markets[i].Market < markets[j].Market })
+	return markets, nil
+}
+
+func (s *Server) handleDepth(_ *account, query map[string]string, _ map[string]interface{}) (interface{}, error) {
+	limit, _ := strconv.Atoi(query["limit"])
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	b, ok := s.books[query["market"]]
+	if !ok {
+		return nil, newAPIError(CodeMarketNotFound, "market not found")
+	}
+	return b.depth(limit), nil
+}
+
+var periods = map[string]int64{
+	"1min": 60, "3min": 180, "5min": 300, "15min": 900, "30min": 1800,
+	"1hour": 3600, "2hour": 7200, "4hour": 14400, "6hour": 21600, "12hour": 43200,
+	"1day": 86400, "3day": 259200, "1week": 604800,
+}
+
+// 由成交记录生成 K 线
+func (s *Server) handleKLine(_ *account, query map[string]string, _ map[string]interface{}) (interface{}, error) {
+	period, ok := periods[query["period"]]
+	if !ok {
+		return nil, newAPIError(CodeInvalidArgument, "invalid period")
+	}
+	limit, _ := strconv.Atoi(query["limit"])
+	if limit <= 0 {
+		limit = 100
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	market := query["market"]
+	if _, ok := s.books[market]; !ok {
+		return nil, newAPIError(CodeMarketNotFound, "market not found")
+	}
+
+	klines := make([]*coinex.SpotKLine, 0)
+	for _, d := range s.deals[market] {
+		start := d.CreatedAt / 1000 / period * period * 1000
+		n := len(klines)
+		if n == 0 || klines[n-1].CreatedAt != start {
+			klines = append(klines, &coinex.SpotKLine{
+				Market:    market,
+				CreatedAt: start,
+				Open:      d.Price,
+				Close:     d.Price,
+				High:      d.Price,
+				Low:       d.Price,
+			})
+			n++
+		}
+		k := klines[n-1]
+		k.Close = d.Price
+		k.High = decimal.Max(k.High, d.Price)
+		k.Low = decimal.Min(k.Low, d.Price)
+		k.Volume = k.Volume.Add(d.Amount)
+		k.Value = k.Value.Add(d.Amount.Mul(d.Price))
+	}
+	if len(klines) > limit {
+		klines = klines[len(klines)-limit:]
+	}
+	return klines, nil
+}
+
+// 最新成交, 按时间倒序
+func (s *Server) handleDeals(_ *account, query map[string]string, _ map[string]interface{}) (interface{}, error) {
+	limit, _ := strconv.Atoi(query["limit"])
+	if limit <= 0 {
+		limit = 100
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	all := s.deals[query["market"]]
+	deals := make([]*coinex.SpotDeal, 0, limit)
+	for i := len(all) - 1; i >= 0 && len(deals) < limit; i-- {
+		deals = append(deals, all[i])
+	}
+	return deals, nil
+}
+
+func (s *Server) handleOrder(a *account, _ map[string]string, body map[string]interface{}) (interface{}, error) {
+	return s.place(a, stringParams(body))
+}
+
+func (s *Server) handleCancelOrder(a *account, _ map[string]string, body map[string]interface{}) (interface{}, error) {
+	orderID, err := int64Param(body["order_id"])
+	if err != nil {
+		return nil, newAPIError(CodeInvalidArgument, "invalid order_id")
+	}
+	return s.cancel(a, stringParam(body, "market"), orderID)
+}
+
+func (s *Server) handleOrderStatus(a *account, query map[string]string, _ map[string]interface{}) (interface{}, error) {
+	orderID, err := strconv.ParseInt(query["order_id"], 10, 64)
+	if err != nil {
+		return nil, newAPIError(CodeInvalidArgument, "invalid order_id")
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	o := a.find(query["market"], orderID)
+	if o == nil {
+		return nil, newAPIError(CodeOrderNotFound, "order not found")
+	}
+	return o.snapshot(), nil
+}
+
+func (s *Server) handlePendingOrder(a *account, query map[string]string, _ map[string]interface{}) (interface{}, error) {
+	return s.orders(a, query, false), nil
+}
+
+func (s *Server) handleFinishedOrder(a *account, query map[string]string, _ map[string]interface{}) (interface{}, error) {
+	return s.orders(a, query, true), nil
+}
+
+// 按条件筛选订单, 按创建时间倒序分页
+func (s *Server) orders(a *account, query map[string]string, finished bool) interface{} {
+	page, limit := paging(query)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	list := make([]*coinex.SpotOrder, 0)
+	for i := len(a.orders) - 1; i >= 0; i-- {
+		o := a.orders[i]
+		if o.finished() != finished {
+			continue
+		}
+		if m := query["market"]; m != "" && o.Market != m {
+			continue
+		}
+		if side := query["side"]; side != "" && o.Side != side {
+			continue
+		}
+		if id := query["client_id"]; id != "" && o.ClientID != id {
+			continue
+		}
+		list = append(list, o.snapshot())
+	}
+
+	total := len(list)
+	start := (page - 1) * limit
+	if start > total {
+		start = total
+	}
+	end := start + limit
+	if end > total {
+		end = total
+	}
+	return pageReply{data: list[start:end], hasNext: end < total}
+}
+
+func (s *Server) handleBalance(a *account, _ map[string]string, _ map[string]interface{}) (interface{}, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	balances := make([]*coinex.SpotBalance, 0, len(a.balances))
+	for _, b := range a.balances {
+		cp := *b
+		balances = append(balances, &cp)
+	}
+	sort.Slice(balances, func(i, j int) bool { return balances[i].Ccy < balances[j].Ccy })
+	return balances, nil
+}
+
+// 全部市场涉及的币种
+func (s *Server) currencies() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	set := make(map[string]bool)
+	for _, b := range s.books {
+		set[b.market.BaseCcy] = true
+		set[b.market.QuoteCcy] = true
+	}
+	list := make([]string, 0, len(set))
+	for ccy := range set {
+		list = append(list, ccy)
+	}
+	sort.Strings(list)
+	return list
+}
+
+func (s *Server) handleInfo(_ *account, query map[string]string, _ map[string]interface{}) (interface{}, error) {
+	list := make([]*coinex.CurrencyInfo, 0)
+	for _, ccy := range s.currencies() {
+		if query["ccy"] != "" && query["ccy"] != ccy {
+			continue
+		}
+		list = append(list, &coinex.CurrencyInfo{ShortName: ccy, FullName: ccy})
+	}
+	return list, nil
+}
+
+func depositWithdrawConfig(ccy string) *coinex.DepositWithdrawConfig {
+	cfg := new(coinex.DepositWithdrawConfig)
+	cfg.Asset.Ccy = ccy
+	cfg.Asset.DepositEnabled = true
+	cfg.Asset.WithdrawEnabled = true
+	cfg.Asset.InterTransferEnabled = true
+	return cfg
+}
+
+func (s *Server) handleDepositWithdrawConfig(_ *account, query map[string]string, _ map[string]interface{}) (interface{}, error) {
+	for _, ccy := range s.currencies() {
+		if ccy == query["ccy"] {
+			return depositWithdrawConfig(ccy), nil
+		}
+	}
+	return nil, newAPIError(CodeInvalidArgument, "invalid ccy")
+}
+
+func (s *Server) handleAllDepositWithdrawConfig(_ *account, _ map[string]string, _ map[string]interface{}) (interface{}, error) {
+	list := make([]*coinex.DepositWithdrawConfig, 0)
+	for _, ccy := range s.currencies() {
+		list = append(list, depositWithdrawConfig(ccy))
+	}
+	return list, nil
+}
+
+func (s *Server) handleDepositAddress(a *account, query map[string]string, _ map[string]interface{}) (interface{}, error) {
+	return &coinex.DepositAddress{
+		Address: "fake-" + strings.ToLower(query["ccy"]+"-"+query["chain"]) + "-" + a.key,
+	}, nil
+}
+
+// 提现直接扣除可用余额
+func (s *Server) handleWithdraw(a *account, _ map[string]string, body map[string]interface{}) (interface{}, error) {
+	params := stringParams(body)
+	amount, err := decimal.NewFromString(params["amount"].(string))
+	if err != nil || !amount.IsPositive() {
+		return nil, newAPIError(CodeInvalidArgument, "invalid amount")
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	ccy := stringParam(params, "ccy")
+	b := a.balance(ccy)
+	if b.Available.LessThan(amount) {
+		return nil, newAPIError(CodeInsufficientBalance, "balance not enough")
+	}
+	b.Available = b.Available.Sub(amount)
+
+	s.orderID++
+	return &coinex.Withdraw{
+		WithdrawID:     s.orderID,
+		CreatedAt:      time.Now().UnixMilli(),
+		Ccy:            ccy,
+		Chain:          stringParam(params, "chain"),
+		Amount:         amount,
+		ActualAmount:   amount,
+		WithdrawMethod: stringParam(params, "withdraw_method"),
+		Memo:           stringParam(params, "memo"),
+		ToAddress:      stringParam(params, "to_address"),
+		Status:         "processing",
+		Remark:         stringParam(params, "remark"),
+	}, nil
+}
+
+// 数字参数转为字符串
+func stringParams(body map[string]interface{}) map[string]interface{} {
+	params := make(map[string]interface{}, len(body))
+	for k, v := range body {
+		switch v := v.(type) {
+		case json.Number:
+			params[k] = v.String()
+		case string:
+			params[k] = v
+		}
+	}
+	if _, ok := params["amount"]; !ok {
+		params["amount"] = ""
+	}
+	return params
+}
+
+func int64Param(v interface{}) (int64, error) {
+	switch v := v.(type) {
+	case json.Number:
+		return v.Int64()
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	}
+	return 0, newAPIError(CodeInvalidArgument, "invalid number")
+}
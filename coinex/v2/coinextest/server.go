@@ -0,0 +1,357 @@
+// 本地模拟的 CoinEx v2 交易所, 用于集成测试
+//
+// REST 接口校验 X-COINEX-SIGN 签名, 维护账户余额和简单的撮合引擎
+// websocket 接口使用 gzip 压缩推送 depth.update, deals.update 和 order.update
+package coinextest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	coinex "github.com/icwl/go-exchange-api/coinex/v2"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+// 错误码
+const (
+	CodeInvalidArgument     = 3001
+	CodeSignatureIncorrect  = 3002
+	CodeMarketNotFound      = 3003
+	CodeInsufficientBalance = 3109
+	CodeMakerOnlyRejected   = 3125
+	CodeOrderNotFound       = 3600
+)
+
+type Server struct {
+	srv *httptest.Server
+
+	lock     *sync.Mutex
+	accounts map[string]*account
+	books    map[string]*book
+	// 市场名称 -> 成交记录, 按时间正序
+	deals   map[string][]*coinex.SpotDeal
+	orderID int64
+	dealID  int64
+
+	sessions map[*session]bool
+}
+
+// 启动服务, 默认包含 BTCUSDT 和 ETHUSDT 市场
+func NewServer() *Server {
+	s := &Server{
+		lock:     new(sync.Mutex),
+		accounts: make(map[string]*account),
+		books:    make(map[string]*book),
+		deals:    make(map[string][]*coinex.SpotDeal),
+		orderID:  100000000000,
+		dealID:   1000000000,
+		sessions: make(map[*session]bool),
+	}
+	for _, m := range []*coinex.SpotMarket{
+		{
+			Market:            "BTCUSDT",
+			MakerFeeRate:      decimal.RequireFromString("0.002"),
+			TakerFeeRate:      decimal.RequireFromString("0.002"),
+			MinAmount:         decimal.RequireFromString("0.0001"),
+			BaseCcy:           "BTC",
+			QuoteCcy:          "USDT",
+			BaseCcyPrecision:  8,
+			QuoteCcyPrecision: 2,
+		},
+		{
+			Market:            "ETHUSDT",
+			MakerFeeRate:      decimal.RequireFromString("0.002"),
+			TakerFeeRate:      decimal.RequireFromString("0.002"),
+			MinAmount:         decimal.RequireFromString("0.005"),
+			BaseCcy:           "ETH",
+			QuoteCcy:          "USDT",
+			BaseCcyPrecision:  8,
+			QuoteCcyPrecision: 2,
+		},
+	} {
+		s.AddMarket(m)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v2/spot", s.serveWS)
+	mux.HandleFunc("/v2/", s.serveREST)
+	s.srv = httptest.NewServer(mux)
+	return s
+}
+
+func (s *Server) Close() {
+	s.lock.Lock()
+	for sess := range s.sessions {
+		sess.close()
+	}
+	s.lock.Unlock()
+	s.srv.Close()
+}
+
+// REST 地址, 用于 coinex.NewHTTPClient
+func (s *Server) URL() string {
+	return s.srv.URL
+}
+
+// websocket 地址, 用于 coinex.NewWSClient
+func (s *Server) WSURL() string {
+	return "ws" + strings.TrimPrefix(s.srv.URL, "http")
+}
+
+// 添加或替换市场
+func (s *Server) AddMarket(m *coinex.SpotMarket) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	cp := *m
+	if b, ok := s.books[m.Market]; ok {
+		b.market = &cp
+		return
+	}
+	s.books[m.Market] = &book{market: &cp}
+}
+
+// 添加 api 账户
+func (s *Server) AddAccount(key, secret string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.accounts[key] = &account{
+		key:      key,
+		secret:   secret,
+		balances: make(map[string]*coinex.SpotBalance),
+	}
+}
+
+// 设置可用余额
+func (s *Server) SetBalance(key, ccy string, available decimal.Decimal) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	a, ok := s.accounts[key]
+	if !ok {
+		return errors.Errorf("account %s not found", key)
+	}
+	a.balance(ccy).Available = available
+	return nil
+}
+
+// 账户余额
+func (s *Server) Balance(key, ccy string) (*coinex.SpotBalance, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	a, ok := s.accounts[key]
+	if !ok {
+		return nil, errors.Errorf("account %s not found", key)
+	}
+	b := *a.balance(ccy)
+	return &b, nil
+}
+
+// 直接下单, 不经过签名校验, 用于准备订单簿
+func (s *Server) PlaceOrder(key, market, side, type_, amount, price string) (*coinex.SpotOrder, error) {
+	a, ok := s.account(key)
+	if !ok {
+		return nil, errors.Errorf("account %s not found", key)
+	}
+	o, err := s.place(a, map[string]interface{}{
+		"market": market,
+		"side":   side,
+		"type":   type_,
+		"amount": amount,
+		"price":  price,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// 市场深度
+func (s *Server) Depth(market string, limit int) (*coinex.SpotDepth, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	b, ok := s.books[market]
+	if !ok {
+		return nil, errors.Errorf("market %s not found", market)
+	}
+	return b.depth(limit), nil
+}
+
+func (s *Server) account(key string) (*account, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	a, ok := s.accounts[key]
+	return a, ok
+}
+
+// 接口错误, 以 code 和 message 返回
+type apiError struct {
+	code    int
+	message string
+}
+
+func (e *apiError) Error() string {
+	return e.message
+}
+
+func newAPIError(code int, message string) error {
+	return &apiError{code: code, message: message}
+}
+
+// 下单, 撮合后推送订单、深度和成交
+func (s *Server) place(a *account, params map[string]interface{}) (*coinex.SpotOrder, error) {
+	var (
+		market = stringParam(params, "market")
+		side   = stringParam(params, "side")
+		type_  = stringParam(params, "type")
+	)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	b, ok := s.books[market]
+	if !ok {
+		return nil, newAPIError(CodeMarketNotFound, "market not found")
+	}
+	if side != "buy" && side != "sell" {
+		return nil, newAPIError(CodeInvalidArgument, "invalid side")
+	}
+	switch type_ {
+	case coinex.OrderTypeLimit, coinex.OrderTypeMarket, coinex.OrderTypeMakerOnly, coinex.OrderTypeIOC, coinex.OrderTypeFOK:
+	default:
+		return nil, newAPIError(CodeInvalidArgument, "invalid type")
+	}
+
+	amount, err := decimal.NewFromString(stringParam(params, "amount"))
+	if err != nil || amount.LessThan(b.market.MinAmount) {
+		return nil, newAPIError(CodeInvalidArgument, "invalid amount")
+	}
+	price := decimal.Zero
+	if type_ != coinex.OrderTypeMarket {
+		price, err = decimal.NewFromString(stringParam(params, "price"))
+		if err != nil || !price.IsPositive() {
+			return nil, newAPIError(CodeInvalidArgument, "invalid price")
+		}
+	}
+
+	now := time.Now().UnixMilli()
+	s.orderID++
+	o := &order{
+		SpotOrder: &coinex.SpotOrder{
+			OrderID:        s.orderID,
+			Market:         market,
+			MarketType:     coinex.MarketTypeSpot,
+			Ccy:            b.market.BaseCcy,
+			Side:           side,
+			Type:           type_,
+			Amount:         amount,
+			Price:          price,
+			UnfilledAmount: amount,
+			ClientID:       stringParam(params, "client_id"),
+			MakerFeeRate:   b.market.MakerFeeRate,
+			TakerFeeRate:   b.market.TakerFeeRate,
+			CreatedAt:      now,
+			UpdatedAt:      now,
+			Status:         coinex.OrderStatusOpen,
+		},
+		owner: a,
+	}
+
+	// 冻结余额, 市价买单按成交逐笔扣除
+	switch {
+	case side == "buy" && type_ != coinex.OrderTypeMarket:
+		q := a.balance(b.market.QuoteCcy)
+		cost := amount.Mul(price)
+		if q.Available.LessThan(cost) {
+			return nil, newAPIError(CodeInsufficientBalance, "balance not enough")
+		}
+		q.Available = q.Available.Sub(cost)
+		q.Frozen = q.Frozen.Add(cost)
+		o.frozen = cost
+	case side == "sell":
+		base := a.balance(b.market.BaseCcy)
+		if base.Available.LessThan(amount) {
+			return nil, newAPIError(CodeInsufficientBalance, "balance not enough")
+		}
+		if type_ != coinex.OrderTypeMarket {
+			base.Available = base.Available.Sub(amount)
+			base.Frozen = base.Frozen.Add(amount)
+		}
+	}
+
+	res := new(result)
+	switch {
+	case type_ == coinex.OrderTypeMakerOnly && b.available(o).IsPositive():
+		b.release(o)
+		return nil, newAPIError(CodeMakerOnlyRejected, "maker only order would be filled")
+	case type_ == coinex.OrderTypeFOK && b.available(o).LessThan(amount):
+		// 无法全部成交, 直接撤销
+	default:
+		b.match(o, res, func() int64 {
+			s.dealID++
+			return s.dealID
+		})
+	}
+
+	a.orders = append(a.orders, o)
+	switch {
+	case o.UnfilledAmount.IsZero():
+		o.Status = coinex.OrderStatusFilled
+		b.release(o)
+		res.add("finish", o)
+	case type_ == coinex.OrderTypeLimit || type_ == coinex.OrderTypeMakerOnly:
+		if o.FilledAmount.IsPositive() {
+			o.Status = coinex.OrderStatusPartFilled
+		}
+		b.insert(o)
+		res.add("put", o)
+	default:
+		b.cancel(o)
+		res.add("finish", o)
+	}
+
+	s.deals[market] = append(s.deals[market], res.deals...)
+	s.publish(b, res)
+	return o.snapshot(), nil
+}
+
+func (s *Server) cancel(a *account, market string, orderID int64) (*coinex.SpotOrder, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	b, ok := s.books[market]
+	if !ok {
+		return nil, newAPIError(CodeMarketNotFound, "market not found")
+	}
+	o := a.find(market, orderID)
+	if o == nil || o.finished() {
+		return nil, newAPIError(CodeOrderNotFound, "order not found")
+	}
+
+	b.cancel(o)
+	res := new(result)
+	res.add("finish", o)
+	s.publish(b, res)
+	return o.snapshot(), nil
+}
+
+func (a *account) find(market string, orderID int64) *order {
+	for _, o := range a.orders {
+		if o.OrderID == orderID && o.Market == market {
+			return o
+		}
+	}
+	return nil
+}
+
+func stringParam(params map[string]interface{}, key string) string {
+	v, _ := params[key].(string)
+	return v
+}
@@ -0,0 +1,173 @@
+package coinextest
+
+import (
+	"testing"
+	"time"
+
+	coinex "github.com/icwl/go-exchange-api/coinex/v2"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+func d(s string) decimal.Decimal {
+	return decimal.RequireFromString(s)
+}
+
+func TestServer_OrderLifecycle(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	logger := zap.NewExample()
+	srv.AddAccount("maker", "maker-secret")
+	srv.AddAccount("taker", "taker-secret")
+	_ = srv.SetBalance("maker", "BTC", d("1"))
+	_ = srv.SetBalance("taker", "USDT", d("100000"))
+
+	maker := coinex.NewHTTPClient(srv.URL(), "maker", "maker-secret", logger)
+	taker := coinex.NewHTTPClient(srv.URL(), "taker", "taker-secret", logger)
+
+	ask, err := maker.SpotOrder("BTCUSDT", coinex.MarketTypeSpot, "sell", coinex.OrderTypeLimit, "", "1", "30000", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ws := coinex.NewWSClient(srv.WSURL(), logger)
+	if err := ws.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	if err := ws.Login("taker", "taker-secret"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.SubDepth([]string{"BTCUSDT"}, 10, "0", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.SubOrder([]string{"BTCUSDT"}); err != nil {
+		t.Fatal(err)
+	}
+	events := make(chan interface{}, 16)
+	go func() {
+		for {
+			msg, err := ws.Read()
+			if err != nil {
+				return
+			}
+			if msg != nil {
+				events <- msg
+			}
+		}
+	}()
+
+	next := func(match func(interface{}) bool) {
+		t.Helper()
+		timeout := time.After(2 * time.Second)
+		for {
+			select {
+			case msg := <-events:
+				if match(msg) {
+					return
+				}
+			case <-timeout:
+				t.Fatal("push not received")
+			}
+		}
+	}
+	next(func(msg interface{}) bool {
+		dp, ok := msg.(*coinex.SpotDepth)
+		return ok && len(dp.Depth.Asks) == 1 && dp.Depth.Asks[0][1].Equal(d("1"))
+	})
+
+	// 限价买单以 maker 价格成交
+	bid, err := taker.SpotOrder("BTCUSDT", coinex.MarketTypeSpot, "buy", coinex.OrderTypeLimit, "", "0.4", "30100", "c-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bid.Status != coinex.OrderStatusFilled || !bid.FilledValue.Equal(d("12000")) || !bid.BaseFee.Equal(d("0.0008")) {
+		t.Fatalf("unexpected order: %+v", bid)
+	}
+	// 订单推送和深度推送顺序不固定
+	var finished, updated bool
+	next(func(msg interface{}) bool {
+		switch msg := msg.(type) {
+		case *coinex.SpotOrderEvent:
+			finished = finished || msg.Event == "finish" && msg.Order.OrderID == bid.OrderID
+		case *coinex.SpotDepth:
+			updated = updated || len(msg.Depth.Asks) == 1 && msg.Depth.Asks[0][1].Equal(d("0.6"))
+		}
+		return finished && updated
+	})
+
+	balances, err := taker.SpotBalance()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, b := range balances {
+		want := map[string]string{"BTC": "0.3992", "USDT": "88000"}[b.Ccy]
+		if !b.Available.Equal(d(want)) || !b.Frozen.IsZero() {
+			t.Fatalf("unexpected balance: %+v", b)
+		}
+	}
+
+	// 撤销剩余挂单, 退回冻结余额
+	cancelled, err := maker.SpotCancelOrder("BTCUSDT", coinex.MarketTypeSpot, ask.OrderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cancelled.Status != coinex.OrderStatusPartCanceled || !cancelled.UnfilledAmount.Equal(d("0.6")) {
+		t.Fatalf("unexpected order: %+v", cancelled)
+	}
+	btc, _ := srv.Balance("maker", "BTC")
+	usdt, _ := srv.Balance("maker", "USDT")
+	if !btc.Available.Equal(d("0.6")) || !btc.Frozen.IsZero() || !usdt.Available.Equal(d("11976")) {
+		t.Fatalf("unexpected maker balance: %+v %+v", btc, usdt)
+	}
+
+	orders, err := maker.SpotFinishedOrder("BTCUSDT", coinex.MarketTypeSpot, "", 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 1 || orders[0].OrderID != ask.OrderID {
+		t.Fatalf("unexpected finished orders: %+v", orders)
+	}
+}
+
+func TestServer_Reject(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	logger := zap.NewExample()
+	srv.AddAccount("key", "secret")
+	_ = srv.SetBalance("key", "USDT", d("100"))
+	if _, err := srv.PlaceOrder("key", "BTCUSDT", "buy", coinex.OrderTypeLimit, "0.001", "30000"); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		cli   *coinex.HTTPClient
+		type_ string
+		code  int
+	}{
+		{coinex.NewHTTPClient(srv.URL(), "key", "wrong", logger), coinex.OrderTypeLimit, CodeSignatureIncorrect},
+		{coinex.NewHTTPClient(srv.URL(), "key", "secret", logger), coinex.OrderTypeLimit, CodeInsufficientBalance},
+	}
+	for _, c := range cases {
+		_, err := c.cli.SpotOrder("BTCUSDT", coinex.MarketTypeSpot, "buy", c.type_, "", "1", "30000", "")
+		var e *coinex.ErrResponse
+		if !errors.As(err, &e) || e.Code != c.code {
+			t.Fatalf("got %v, want code %d", err, c.code)
+		}
+	}
+
+	// 只做 maker 的卖单会与买单成交, 被拒绝
+	srv.AddAccount("seller", "secret")
+	_ = srv.SetBalance("seller", "BTC", d("1"))
+	_, err := srv.PlaceOrder("seller", "BTCUSDT", "sell", coinex.OrderTypeMakerOnly, "0.001", "29000")
+	var e *apiError
+	if !errors.As(err, &e) || e.code != CodeMakerOnlyRejected {
+		t.Fatalf("got %v, want maker only rejected", err)
+	}
+	if b, _ := srv.Balance("seller", "BTC"); !b.Available.Equal(d("1")) {
+		t.Fatalf("balance not released: %+v", b)
+	}
+}
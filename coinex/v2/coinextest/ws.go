@@ -0,0 +1,246 @@
+package coinextest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/websocket"
+	coinex "github.com/icwl/go-exchange-api/coinex/v2"
+)
+
+// websocket 连接
+type session struct {
+	conn *websocket.Conn
+	send chan []byte
+
+	// 以下字段受 Server.lock 保护
+	closed  bool
+	account *account
+	// 主题 -> 市场 -> 深度条数, 空市场表示全部市场
+	subs map[string]map[string]int
+}
+
+func (sess *session) close() {
+	if !sess.closed {
+		sess.closed = true
+		close(sess.send)
+	}
+}
+
+func (sess *session) subscribed(topic, market string) (int, bool) {
+	markets, ok := sess.subs[topic]
+	if !ok {
+		return 0, false
+	}
+	if limit, ok := markets[market]; ok {
+		return limit, true
+	}
+	limit, ok := markets[""]
+	return limit, ok
+}
+
+// 推送消息, 发送队列已满时断开连接, 调用方持有 Server.lock
+func (sess *session) push(v interface{}) {
+	if sess.closed {
+		return
+	}
+	msg, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, _ = zw.Write(msg)
+	_ = zw.Close()
+
+	select {
+	case sess.send <- buf.Bytes():
+	default:
+		sess.close()
+	}
+}
+
+func (sess *session) writeLoop() {
+	for msg := range sess.send {
+		if err := sess.conn.WriteMessage(websocket.BinaryMessage, msg); err != nil {
+			break
+		}
+	}
+	_ = sess.conn.Close()
+}
+
+type wsRequest struct {
+	ID     int64           `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+var upgrader = websocket.Upgrader{}
+
+func (s *Server) serveWS(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	sess := &session{
+		conn: conn,
+		send: make(chan []byte, 1024),
+		subs: make(map[string]map[string]int),
+	}
+	s.lock.Lock()
+	s.sessions[sess] = true
+	s.lock.Unlock()
+
+	go sess.writeLoop()
+	defer func() {
+		s.lock.Lock()
+		delete(s.sessions, sess)
+		sess.close()
+		s.lock.Unlock()
+	}()
+
+	for {
+		var req *wsRequest
+		if err := conn.ReadJSON(&req); err != nil {
+			return
+		}
+		s.handleWS(sess, req)
+	}
+}
+
+func (s *Server) handleWS(sess *session, req *wsRequest) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	reply := func(code int, message string, data interface{}) {
+		if data == nil {
+			data = map[string]interface{}{}
+		}
+		sess.push(map[string]interface{}{"id": req.ID, "code": code, "message": message, "data": data})
+	}
+
+	switch req.Method {
+	case "server.ping":
+		reply(0, "OK", map[string]interface{}{"result": "pong"})
+	case "server.sign":
+		var params struct {
+			AccessID  string `json:"access_id"`
+			SignedStr string `json:"signed_str"`
+			Timestamp int64  `json:"timestamp"`
+		}
+		_ = json.Unmarshal(req.Params, &params)
+		a, ok := s.accounts[params.AccessID]
+		if !ok || coinex.WSSign(strconv.FormatInt(params.Timestamp, 10), a.secret) != params.SignedStr {
+			reply(CodeSignatureIncorrect, "signature incorrect", nil)
+			return
+		}
+		sess.account = a
+		reply(0, "OK", nil)
+	case "depth.subscribe":
+		var params struct {
+			MarketList [][]interface{} `json:"market_list"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			reply(CodeInvalidArgument, "invalid params", nil)
+			return
+		}
+		markets := s.topic(sess, coinex.TopicDepth)
+		for _, item := range params.MarketList {
+			market, _ := item[0].(string)
+			limit := 0
+			if len(item) > 1 {
+				n, _ := item[1].(float64)
+				limit = int(n)
+			}
+			markets[market] = limit
+		}
+		reply(0, "OK", nil)
+		for _, item := range params.MarketList {
+			market, _ := item[0].(string)
+			if b, ok := s.books[market]; ok {
+				sess.push(map[string]interface{}{"method": "depth.update", "data": b.depth(markets[market]), "id": nil})
+			}
+		}
+	case "order.subscribe":
+		if sess.account == nil {
+			reply(CodeSignatureIncorrect, "not authenticated", nil)
+			return
+		}
+		s.subscribe(sess, coinex.TopicOrder, req)
+		reply(0, "OK", nil)
+	case "deals.subscribe", "state.subscribe", "bbo.subscribe", "index.subscribe":
+		s.subscribe(sess, req.Method[:len(req.Method)-len(".subscribe")], req)
+		reply(0, "OK", nil)
+	case "depth.unsubscribe", "deals.unsubscribe", "state.unsubscribe", "bbo.unsubscribe", "index.unsubscribe", "order.unsubscribe":
+		topic := req.Method[:len(req.Method)-len(".unsubscribe")]
+		var params struct {
+			MarketList []string `json:"market_list"`
+		}
+		_ = json.Unmarshal(req.Params, &params)
+		if len(params.MarketList) == 0 {
+			delete(sess.subs, topic)
+		}
+		for _, market := range params.MarketList {
+			delete(s.topic(sess, topic), market)
+		}
+		reply(0, "OK", nil)
+	default:
+		reply(CodeInvalidArgument, "method not found", nil)
+	}
+}
+
+func (s *Server) topic(sess *session, topic string) map[string]int {
+	markets, ok := sess.subs[topic]
+	if !ok {
+		markets = make(map[string]int)
+		sess.subs[topic] = markets
+	}
+	return markets
+}
+
+// 市场列表订阅, 空列表表示全部市场
+func (s *Server) subscribe(sess *session, topic string, req *wsRequest) {
+	var params struct {
+		MarketList []string `json:"market_list"`
+	}
+	_ = json.Unmarshal(req.Params, &params)
+	markets := s.topic(sess, topic)
+	if len(params.MarketList) == 0 {
+		markets[""] = 0
+	}
+	for _, market := range params.MarketList {
+		markets[market] = 0
+	}
+}
+
+// 推送撮合结果, 调用方持有 s.lock
+func (s *Server) publish(b *book, res *result) {
+	market := b.market.Market
+	for sess := range s.sessions {
+		if limit, ok := sess.subscribed(coinex.TopicDepth, market); ok {
+			sess.push(map[string]interface{}{"method": "depth.update", "data": b.depth(limit), "id": nil})
+		}
+		if _, ok := sess.subscribed(coinex.TopicDeals, market); ok && len(res.deals) > 0 {
+			deals := make([]*coinex.SpotDeal, 0, len(res.deals))
+			for i := len(res.deals) - 1; i >= 0; i-- {
+				deals = append(deals, res.deals[i])
+			}
+			sess.push(map[string]interface{}{
+				"method": "deals.update",
+				"data":   &coinex.SpotDeals{Market: market, DealList: deals},
+				"id":     nil,
+			})
+		}
+		if _, ok := sess.subscribed(coinex.TopicOrder, market); ok {
+			for i, event := range res.events {
+				if res.owners[i] != sess.account {
+					continue
+				}
+				sess.push(map[string]interface{}{"method": "order.update", "data": event, "id": nil})
+			}
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package coinex
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+var ErrDeadManStarted = errors.New("coinex: dead man switch already started")
+
+// 客户端失效保护, 超过 timeout 未调用 Heartbeat 时通过 SpotCancelAllOrder 撤销指定市场的全部挂单
+// CoinEx 没有交易所端的倒计时撤单, 只能覆盖主循环卡死等进程仍在运行的情况, 进程退出后挂单不会被撤销
+type DeadManSwitch struct {
+	cli        *HTTPClient
+	marketType string
+	markets    []string
+	timeout    time.Duration
+	logger     *zap.Logger
+
+	lock     *sync.Mutex
+	lastBeat time.Time
+	// 超时后已撤单, 下一次 Heartbeat 前不再撤单
+	fired bool
+
+	// 串行化 Start 和 Stop
+	runLock *sync.Mutex
+	running bool
+	errors  chan error
+	stop    chan interface{}
+	wait    *sync.WaitGroup
+}
+
+// - marketType 为空时为 SPOT
+// - timeout 超过该时间未调用 Heartbeat 时撤单
+func (c *HTTPClient) DeadManSwitch(marketType string, timeout time.Duration, markets ...string) *DeadManSwitch {
+	if marketType == "" {
+		marketType = MarketTypeSpot
+	}
+	return &DeadManSwitch{
+		cli:        c,
+		marketType: marketType,
+		markets:    markets,
+		timeout:    timeout,
+		logger:     c.logger,
+		lock:       new(sync.Mutex),
+		runLock:    new(sync.Mutex),
+		errors:     make(chan error, 16),
+		wait:       new(sync.WaitGroup),
+	}
+}
+
+// 开始计时, 需要在 timeout 内定期调用 Heartbeat
+// 运行中再次调用返回 ErrDeadManStarted, Stop 后可以重新 Start
+func (d *DeadManSwitch) Start() error {
+	if d.timeout <= 0 {
+		return errors.New("coinex: timeout must be positive")
+	}
+	if len(d.markets) == 0 {
+		return errors.New("coinex: no market to cancel")
+	}
+	d.runLock.Lock()
+	defer d.runLock.Unlock()
+	if d.running {
+		return errors.WithStack(ErrDeadManStarted)
+	}
+	d.Heartbeat()
+
+	// 上一次 Stop 已关闭错误通道
+	if d.stop != nil {
+		d.errors = make(chan error, 16)
+	}
+	d.running = true
+	d.stop = make(chan interface{})
+	d.wait.Add(1)
+	go d.run(d.stop, d.errors)
+	return nil
+}
+
+// 停止计时, 用于正常退出, 挂单不会被撤销
+func (d *DeadManSwitch) Stop() error {
+	d.runLock.Lock()
+	defer d.runLock.Unlock()
+	if !d.running {
+		return nil
+	}
+	d.running = false
+	close(d.stop)
+	d.wait.Wait()
+	close(d.errors)
+	return nil
+}
+
+// 重新开始计时, 超时撤单后调用时重新生效
+func (d *DeadManSwitch) Heartbeat() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.lastBeat = time.Now()
+	d.fired = false
+}
+
+// 是否已因超时撤单
+func (d *DeadManSwitch) Fired() bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.fired
+}
+
+// 超时撤单失败的错误, 失败时下一次检查会重试
+// 未及时读取时丢弃, Stop 后关闭, 重新 Start 后需要再次调用 Errors 获取新的通道
+func (d *DeadManSwitch) Errors() <-chan error {
+	d.runLock.Lock()
+	defer d.runLock.Unlock()
+	return d.errors
+}
+
+func (d *DeadManSwitch) run(stop chan interface{}, errs chan error) {
+	defer d.wait.Done()
+
+	interval := d.timeout / 10
+	if interval < 10*time.Millisecond {
+		interval = 10 * time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			d.check(errs)
+		}
+	}
+}
+
+func (d *DeadManSwitch) check(errs chan error) {
+	d.lock.Lock()
+	lastBeat, fired := d.lastBeat, d.fired
+	d.lock.Unlock()
+	if fired || time.Since(lastBeat) <= d.timeout {
+		return
+	}
+
+	d.logger.Warn("DeadManSwitch fired", zap.Time("last_beat", lastBeat), zap.Strings("markets", d.markets))
+	failed := false
+	for _, market := range d.markets {
+		results, err := d.cli.SpotCancelAllOrder(market, d.marketType, "")
+		if err != nil {
+			d.logger.Error("DeadManSwitch.cancel", zap.String("market", market), zap.Error(err))
+			failed = true
+			select {
+			case errs <- err:
+			default:
+			}
+			continue
+		}
+		d.logger.Warn("DeadManSwitch.cancel", zap.String("market", market), zap.Int("orders", len(results)))
+	}
+	if failed {
+		return
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	// 撤单期间收到 Heartbeat 时保持计时
+	if d.lastBeat.Equal(lastBeat) {
+		d.fired = true
+	}
+}
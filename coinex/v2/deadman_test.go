@@ -0,0 +1,118 @@
+package coinex
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+func TestDeadManSwitch(t *testing.T) {
+	var (
+		lock      sync.Mutex
+		markets   []string
+		cancelled []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		switch r.URL.Path {
+		case "/v2/spot/pending-order":
+			market := r.URL.Query().Get("market")
+			markets = append(markets, market)
+			if market == "ETHUSDT" && len(markets) <= 2 {
+				_, _ = w.Write([]byte(`{"code":3008,"data":{},"message":"service busy"}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"code":0,"data":[{"order_id":1,"market":"` + market + `","status":"open"}],"pagination":{"has_next":false},"message":"OK"}`))
+		case "/v2/spot/cancel-batch-order":
+			var body struct {
+				Market   string  `json:"market"`
+				OrderIDs []int64 `json:"order_ids"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			cancelled = append(cancelled, body.Market)
+			_, _ = w.Write([]byte(`{"code":0,"data":[{"code":0,"data":{"order_id":1,"status":"canceled"},"message":"OK"}],"message":"OK"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	cli := NewHTTPClient(srv.URL, "key", "secret", zap.NewNop())
+	d := cli.DeadManSwitch("", 100*time.Millisecond, "BTCUSDT", "ETHUSDT")
+	if err := d.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Start(); !errors.Is(err, ErrDeadManStarted) {
+		t.Fatalf("expected already started: %v", err)
+	}
+
+	// 按时 Heartbeat 时不撤单
+	for i := 0; i < 5; i++ {
+		time.Sleep(40 * time.Millisecond)
+		d.Heartbeat()
+	}
+	lock.Lock()
+	n := len(markets)
+	lock.Unlock()
+	if n != 0 {
+		t.Fatalf("cancelled before timeout: %d", n)
+	}
+
+	// 超时后撤单, 失败的市场从 Errors 返回并在下一次检查时重试
+	select {
+	case err := <-d.Errors():
+		t.Logf("撤单失败 : %v", err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("cancel error not reported")
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for !d.Fired() {
+		if time.Now().After(deadline) {
+			t.Fatal("switch not fired")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := d.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if len(markets) != 4 || markets[2] != "BTCUSDT" || markets[3] != "ETHUSDT" {
+		t.Fatalf("unexpected queries: %v", markets)
+	}
+	if len(cancelled) != 3 || cancelled[2] != "ETHUSDT" {
+		t.Fatalf("unexpected cancels: %v", cancelled)
+	}
+}
+
+func TestDeadManSwitch_Restart(t *testing.T) {
+	cli := NewHTTPClient("http://127.0.0.1:0", "key", "secret", zap.NewNop())
+	d := cli.DeadManSwitch("", time.Hour, "BTCUSDT")
+	if err := d.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := d.Start(); err != nil {
+			t.Fatal(err)
+		}
+		errs := d.Errors()
+		if err := d.Stop(); err != nil {
+			t.Fatal(err)
+		}
+		if err := d.Stop(); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := <-errs; ok {
+			t.Fatal("errors not closed after stop")
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package coinex
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/icwl/go-exchange-api/fixed"
+	"github.com/icwl/go-exchange-api/internal/jsonscan"
+	"github.com/pkg/errors"
+)
+
+// 复用 gzip.Reader, 避免每帧重新分配解压缓冲区
+var gzipReaders sync.Pool
+
+// 市场深度推送, 价格和数量为定点数, 由 SetFixedPoint 开启
+type SpotDepthFixed struct {
+	Depth struct {
+		// [[卖方价格, 卖方数量],...]
+		Asks [][2]fixed.Fixed `json:"asks"`
+		// [[买方价格, 买方数量],...]
+		Bids     [][2]fixed.Fixed `json:"bids"`
+		Checksum int64            `json:"checksum"`
+		// 最新价格
+		Last      fixed.Fixed `json:"last"`
+		UpdatedAt int64       `json:"updated_at"`
+	} `json:"depth"`
+	// true为全量推送, false为增量推送
+	IsFull bool `json:"is_full"`
+	// 市场名称
+	Market string `json:"market"`
+}
+
+// 解压 r 并追加到 buf
+func gunzip(buf *bytes.Buffer, r io.Reader) error {
+	var err error
+	reader, _ := gzipReaders.Get().(*gzip.Reader)
+	if reader == nil {
+		reader, err = gzip.NewReader(r)
+	} else {
+		err = reader.Reset(r)
+	}
+	if err != nil {
+		if reader != nil {
+			gzipReaders.Put(reader)
+		}
+		return err
+	}
+	defer gzipReaders.Put(reader)
+
+	if _, err := buf.ReadFrom(reader); err != nil {
+		return err
+	}
+	return reader.Close()
+}
+
+// 开启后深度推送解析为 *SpotDepthFixed, 需要在 Read 之前调用
+// 价格或数量超出 fixed.Decimals 位小数或 int64 范围的帧仍解析为 *SpotDepth
+func (c *WSClient) SetFixedPoint(enable bool) {
+	c.fixedPoint = enable
+}
+
+// 解压一帧, 返回的切片在下一次调用前有效
+func (c *WSClient) inflate(frame []byte) ([]byte, error) {
+	c.reader.Reset(frame)
+	c.buf.Reset()
+	if err := gunzip(c.buf, c.reader); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return c.buf.Bytes(), nil
+}
+
+// 解析解压后的消息, 只扫描一次顶层字段, data 按 method 直接解析为对应类型
+func (c *WSClient) decode(msg []byte) (interface{}, error) {
+	var method, data, id []byte
+	err := jsonscan.Object(msg, func(key, value []byte) bool {
+		switch string(key) {
+		case "method":
+			method = jsonscan.Unquote(value)
+		case "data":
+			data = value
+		case "id":
+			id = value
+		}
+		return true
+	})
+	if err != nil {
+		err = errors.Wrap(err, string(msg))
+		return nil, errors.WithStack(err)
+	}
+
+	// server.ping 响应
+	if len(id) > 0 && !jsonscan.IsNull(id) {
+		n, err := strconv.ParseInt(string(id), 10, 64)
+		if err == nil && n == atomic.LoadInt64(&c.hb.pingID) {
+			c.pong(atomic.SwapInt64(&c.hb.pingAt, 0))
+			return nil, nil
+		}
+	}
+
+	switch string(method) {
+	case "depth.update":
+		if c.fixedPoint {
+			dp, err := decodeDepthFixed(data)
+			if err == nil {
+				return dp, nil
+			}
+			// 超出定点数精度或范围, 或定点数不支持的写法 (如科学计数法) 的帧回退为 *SpotDepth
+			if cause := errors.Cause(err); cause != fixed.ErrRange && cause != fixed.ErrSyntax {
+				err = errors.Wrap(err, string(data))
+				return nil, errors.WithStack(err)
+			}
+		}
+		var dp *SpotDepth
+		if err := unmarshal(data, &dp); err != nil {
+			return nil, err
+		}
+		return dp, nil
+	case "deals.update":
+		var deals *SpotDeals
+		if err := unmarshal(data, &deals); err != nil {
+			return nil, err
+		}
+		return deals, nil
+	case "state.update":
+		var states *SpotStates
+		if err := unmarshal(data, &states); err != nil {
+			return nil, err
+		}
+		return states, nil
+	case "bbo.update":
+		var bbo *SpotBBO
+		if err := unmarshal(data, &bbo); err != nil {
+			return nil, err
+		}
+		return bbo, nil
+	case "index.update":
+		var index *SpotIndex
+		if err := unmarshal(data, &index); err != nil {
+			return nil, err
+		}
+		return index, nil
+	case "order.update":
+		var event *SpotOrderEvent
+		if err := unmarshal(data, &event); err != nil {
+			return nil, err
+		}
+		return event, nil
+	}
+	return nil, nil
+}
+
+func unmarshal(data []byte, v interface{}) error {
+	if err := json.Unmarshal(data, v); err != nil {
+		err = errors.Wrap(err, string(data))
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+func decodeDepthFixed(data []byte) (*SpotDepthFixed, error) {
+	var (
+		dp  = new(SpotDepthFixed)
+		err error
+	)
+	scanErr := jsonscan.Object(data, func(key, value []byte) bool {
+		switch string(key) {
+		case "market":
+			dp.Market = string(jsonscan.Unquote(value))
+		case "is_full":
+			dp.IsFull = string(value) == "true"
+		case "depth":
+			scanErr := jsonscan.Object(value, func(key, value []byte) bool {
+				switch string(key) {
+				case "asks":
+					dp.Depth.Asks, err = fixed.ParseLevels(value, nil)
+				case "bids":
+					dp.Depth.Bids, err = fixed.ParseLevels(value, nil)
+				case "checksum":
+					dp.Depth.Checksum, err = strconv.ParseInt(string(value), 10, 64)
+				case "last":
+					dp.Depth.Last, err = fixed.Parse(jsonscan.Unquote(value))
+				case "updated_at":
+					dp.Depth.UpdatedAt, err = strconv.ParseInt(string(value), 10, 64)
+				}
+				return err == nil
+			})
+			if scanErr != nil {
+				err = scanErr
+			}
+		}
+		return err == nil
+	})
+	if scanErr != nil {
+		return nil, scanErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return dp, nil
+}
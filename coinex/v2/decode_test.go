@@ -0,0 +1,165 @@
+package coinex
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+func loadFrame(t testing.TB, name string) []byte {
+	msg, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, _ = zw.Write(msg)
+	_ = zw.Close()
+	return buf.Bytes()
+}
+
+func TestWSClient_decode(t *testing.T) {
+	cli := NewWSClient(WSURL, zap.NewNop())
+	frame := loadFrame(t, "depth.json")
+
+	msg, err := cli.inflate(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := cli.decode(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dp := v.(*SpotDepth)
+
+	cli.SetFixedPoint(true)
+	msg, err = cli.inflate(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err = cli.decode(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fp := v.(*SpotDepthFixed)
+
+	if fp.Market != dp.Market || fp.IsFull != dp.IsFull || fp.Depth.Checksum != dp.Depth.Checksum ||
+		fp.Depth.UpdatedAt != dp.Depth.UpdatedAt || !fp.Depth.Last.Decimal().Equal(dp.Depth.Last) {
+		t.Fatalf("header mismatch: %+v", fp)
+	}
+	if len(fp.Depth.Asks) != len(dp.Depth.Asks) || len(fp.Depth.Bids) != len(dp.Depth.Bids) {
+		t.Fatal("level count mismatch")
+	}
+	for i, level := range dp.Depth.Asks {
+		if !fp.Depth.Asks[i][0].Decimal().Equal(level[0]) || !fp.Depth.Asks[i][1].Decimal().Equal(level[1]) {
+			t.Fatalf("ask %d mismatch", i)
+		}
+	}
+	for i, level := range dp.Depth.Bids {
+		if !fp.Depth.Bids[i][0].Decimal().Equal(level[0]) || !fp.Depth.Bids[i][1].Decimal().Equal(level[1]) {
+			t.Fatalf("bid %d mismatch", i)
+		}
+	}
+
+	// 超出定点数精度的帧回退为 *SpotDepth
+	v, err = cli.decode([]byte(`{"method":"depth.update","data":{"market":"PEPEUSDT","is_full":true,"depth":{"asks":[],"bids":[["0.0000012345678","100"]],"last":"0.0000012345678","updated_at":1700000000123,"checksum":1}},"id":null}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dp, ok := v.(*SpotDepth); !ok || dp.Market != "PEPEUSDT" || dp.Depth.Bids[0][0].String() != "0.0000012345678" {
+		t.Fatalf("unexpected fallback: %+v", v)
+	}
+
+	// 科学计数法同样回退
+	v, err = cli.decode([]byte(`{"method":"depth.update","data":{"market":"PEPEUSDT","is_full":true,"depth":{"asks":[],"bids":[["1e-8","100"]],"last":"1e-8","updated_at":1700000000123,"checksum":1}},"id":null}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dp, ok := v.(*SpotDepth); !ok || !dp.Depth.Bids[0][0].Equal(decimal.RequireFromString("0.00000001")) {
+		t.Fatalf("unexpected fallback: %+v", v)
+	}
+
+	msg, err = cli.inflate(loadFrame(t, "deals.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err = cli.decode(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deals := v.(*SpotDeals); deals.Market != "BTCUSDT" || len(deals.DealList) != 20 {
+		t.Fatalf("unexpected deals: %+v", deals)
+	}
+}
+
+func benchmarkDecode(b *testing.B, name string, fixedPoint bool) {
+	cli := NewWSClient(WSURL, zap.NewNop())
+	cli.SetFixedPoint(fixedPoint)
+	frame := loadFrame(b, name)
+
+	b.SetBytes(int64(len(frame)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		msg, err := cli.inflate(frame)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := cli.decode(msg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// 改造前的解析方式, 作为对比基准
+func benchmarkDecodeBaseline(b *testing.B, name string) {
+	frame := loadFrame(b, name)
+
+	b.SetBytes(int64(len(frame)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		reader, err := gzip.NewReader(bytes.NewReader(frame))
+		if err != nil {
+			b.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if _, err := buf.ReadFrom(reader); err != nil {
+			b.Fatal(err)
+		}
+		var raw struct {
+			Method string          `json:"method"`
+			Data   json.RawMessage `json:"data"`
+			ID     *int64          `json:"id"`
+		}
+		if err := json.Unmarshal(buf.Bytes(), &raw); err != nil {
+			b.Fatal(err)
+		}
+		var v interface{}
+		switch raw.Method {
+		case "depth.update":
+			v = new(SpotDepth)
+		case "deals.update":
+			v = new(SpotDeals)
+		}
+		if err := json.Unmarshal(raw.Data, v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecode_Depth(b *testing.B) {
+	b.Run("baseline", func(b *testing.B) { benchmarkDecodeBaseline(b, "depth.json") })
+	b.Run("decimal", func(b *testing.B) { benchmarkDecode(b, "depth.json", false) })
+	b.Run("fixed", func(b *testing.B) { benchmarkDecode(b, "depth.json", true) })
+}
+
+func BenchmarkDecode_Deals(b *testing.B) {
+	b.Run("baseline", func(b *testing.B) { benchmarkDecodeBaseline(b, "deals.json") })
+	b.Run("decimal", func(b *testing.B) { benchmarkDecode(b, "deals.json", false) })
+}
@@ -15,6 +15,9 @@ const (
 	MarketTypeMargin  = "MARGIN"
 	MarketTypeFutures = "FUTURES"
 
+	SideBuy  = "buy"
+	SideSell = "sell"
+
 	OrderTypeLimit     = "limit"      // 限价单, 一直生效, GTC 订单
 	OrderTypeMarket    = "market"     // 市价单
 	OrderTypeMakerOnly = "maker_only" // 只做 maker 单, post_only 订单
@@ -26,6 +29,24 @@ const (
 	OrderStatusFilled       = "filled"        // 完全成交(订单已完成);
 	OrderStatusPartCanceled = "part_canceled" // 已撤销部分成交(订单成交部分后被撤销);
 	OrderStatusCanceled     = "canceled"      // 订单已取消(订单已完成);为了保证服务器性能，所有取消的没有任何成交的订单均不会保存
+
+	STPModeCancelTaker = "ct"   // 取消 taker 订单
+	STPModeCancelMaker = "cm"   // 取消 maker 订单
+	STPModeCancelBoth  = "both" // 双方都取消
+
+	RoleMaker = "maker"
+	RoleTaker = "taker"
+
+	TriggerDirectionHigher = "higher" // 价格上涨到触发价时触发
+	TriggerDirectionLower  = "lower"  // 价格下跌到触发价时触发
+)
+
+// 以下类型用于 SpotOrderRequest, 对应的常量为无类型常量, 同时可以用于字符串参数
+// CoinEx 没有单独的 time in force 参数, 通过 OrderType 的 maker_only / ioc / fok 表示
+type (
+	Side      string
+	OrderType string
+	STPMode   string
 )
 
 type SpotMarket struct {
@@ -213,3 +234,148 @@ type SpotOrder struct {
 	LastFillPrice  string `json:"last_fill_price"`
 	Status         string `json:"status"`
 }
+
+// 计划委托, 最新成交价达到触发价后按委托参数下单
+type SpotStopOrder struct {
+	// 计划委托 ID
+	StopID int64 `json:"stop_id"`
+	// 市场名称
+	Market string `json:"market"`
+	// 市场类型
+	MarketType string `json:"market_type"`
+	// 币种名称
+	Ccy string `json:"ccy"`
+	// 订单方向
+	Side string `json:"side"`
+	// 订单类型
+	Type string `json:"type"`
+	// 委托数量
+	Amount decimal.Decimal `json:"amount"`
+	// 委托价格
+	Price decimal.Decimal `json:"price"`
+	// 触发价格
+	TriggerPrice decimal.Decimal `json:"trigger_price"`
+	// 触发方向, higher 或 lower
+	TriggerDirection string `json:"trigger_direction"`
+	// 客户端 ID
+	ClientID  string `json:"client_id"`
+	CreatedAt int64  `json:"created_at"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+// 个人成交记录
+type SpotUserDeal struct {
+	// 成交 ID
+	DealID int64 `json:"deal_id"`
+	// 成交时间, 毫秒
+	CreatedAt int64 `json:"created_at"`
+	// 市场名称
+	Market string `json:"market"`
+	// 本方订单方向 [buy / sell]
+	Side string `json:"side"`
+	// 关联的订单 ID
+	OrderID int64 `json:"order_id"`
+	// 订单的客户端 ID
+	ClientID string `json:"client_id"`
+	// 成交价格
+	Price decimal.Decimal `json:"price"`
+	// 成交数量
+	Amount decimal.Decimal `json:"amount"`
+	// 成交角色 [maker / taker]
+	Role string `json:"role"`
+	// 手续费
+	Fee decimal.Decimal `json:"fee"`
+	// 手续费币种
+	FeeCcy string `json:"fee_ccy"`
+}
+
+// 批量下单或撤单中单个订单的结果, Code 不为 0 时失败, Data 为空
+type BatchOrderResult struct {
+	Code    int        `json:"code"`
+	Data    *SpotOrder `json:"data"`
+	Message string     `json:"message"`
+	// 请求中的订单 ID 和客户端 ID, 用于失败时对账
+	OrderID  int64  `json:"-"`
+	ClientID string `json:"-"`
+}
+
+// 订单推送
+type SpotOrderEvent struct {
+	// 事件类型 [put / update / finish]
+	Event string     `json:"event"`
+	Order *SpotOrder `json:"order"`
+}
+
+type SpotDeal struct {
+	// 成交 ID
+	DealID int64 `json:"deal_id"`
+	// 成交时间
+	CreatedAt int64 `json:"created_at"`
+	// taker 方向 [buy / sell]
+	Side string `json:"side"`
+	// 成交价格
+	Price decimal.Decimal `json:"price"`
+	// 成交数量
+	Amount decimal.Decimal `json:"amount"`
+}
+
+// 最新成交推送
+type SpotDeals struct {
+	// 市场名称
+	Market string `json:"market"`
+	// 成交列表, 按时间倒序
+	DealList []*SpotDeal `json:"deal_list"`
+}
+
+type SpotState struct {
+	// 市场名称
+	Market string `json:"market"`
+	// 最新价格
+	Last decimal.Decimal `json:"last"`
+	// 开盘价
+	Open decimal.Decimal `json:"open"`
+	// 收盘价
+	Close decimal.Decimal `json:"close"`
+	// 最高价
+	High decimal.Decimal `json:"high"`
+	// 最低价
+	Low decimal.Decimal `json:"low"`
+	// 成交量
+	Volume decimal.Decimal `json:"volume"`
+	// 成交额
+	Value decimal.Decimal `json:"value"`
+	// 主动卖出量
+	VolumeSell decimal.Decimal `json:"volume_sell"`
+	// 主动买入量
+	VolumeBuy decimal.Decimal `json:"volume_buy"`
+	// 统计周期, 固定为 86400 秒
+	Period int64 `json:"period"`
+}
+
+// 市场状态推送
+type SpotStates struct {
+	StateList []*SpotState `json:"state_list"`
+}
+
+// 最优挂单推送
+type SpotBBO struct {
+	// 市场名称
+	Market    string `json:"market"`
+	UpdatedAt int64  `json:"updated_at"`
+	// 买一价
+	BestBidPrice decimal.Decimal `json:"best_bid_price"`
+	// 买一量
+	BestBidSize decimal.Decimal `json:"best_bid_size"`
+	// 卖一价
+	BestAskPrice decimal.Decimal `json:"best_ask_price"`
+	// 卖一量
+	BestAskSize decimal.Decimal `json:"best_ask_size"`
+}
+
+// 指数价格推送
+type SpotIndex struct {
+	// 市场名称
+	Market string `json:"market"`
+	// 指数价格
+	Price decimal.Decimal `json:"price"`
+}
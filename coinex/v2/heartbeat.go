@@ -0,0 +1,189 @@
+package coinex
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// 连接失效事件, 超过 pong 超时时间未收到 pong 时触发
+// Read 在重连并恢复订阅后返回该事件
+type StaleEvent struct {
+	// 最后一次收到 pong 的时间
+	LastPong time.Time
+	// 判定失效的时间
+	At time.Time
+}
+
+// ping 往返延迟统计
+type Latency struct {
+	// 最近一次往返延迟
+	Last time.Duration
+	// 平滑往返延迟, 算法同 TCP SRTT
+	Smoothed time.Duration
+	Min      time.Duration
+	Max      time.Duration
+	// 样本数量
+	Samples int64
+}
+
+type heartbeat struct {
+	// 超过该时间未收到 pong 视为连接失效, 同时作为读超时时间, 纳秒
+	timeout int64
+	// 最后一次收到 pong 的时间, UnixNano
+	lastPong int64
+	// 最后一次应用层 ping 的 id 和发送时间
+	pingID int64
+	pingAt int64
+	// 连接失效标记
+	stale int32
+
+	lock    sync.Mutex
+	latency Latency
+}
+
+// 设置 pong 超时时间, 默认 120 秒
+func (c *WSClient) SetPongTimeout(timeout time.Duration) {
+	atomic.StoreInt64(&c.hb.timeout, int64(timeout))
+}
+
+func (c *WSClient) pongTimeout() time.Duration {
+	return time.Duration(atomic.LoadInt64(&c.hb.timeout))
+}
+
+// ping 往返延迟统计
+func (c *WSClient) Latency() Latency {
+	c.hb.lock.Lock()
+	defer c.hb.lock.Unlock()
+	return c.hb.latency
+}
+
+func (c *WSClient) pong(sentAt int64) {
+	now := time.Now()
+	atomic.StoreInt64(&c.hb.lastPong, now.UnixNano())
+	if sentAt == 0 {
+		return
+	}
+
+	rtt := now.Sub(time.Unix(0, sentAt))
+	c.hb.lock.Lock()
+	defer c.hb.lock.Unlock()
+
+	l := &c.hb.latency
+	l.Last = rtt
+	if l.Samples == 0 {
+		l.Smoothed, l.Min, l.Max = rtt, rtt, rtt
+	} else {
+		l.Smoothed += (rtt - l.Smoothed) / 8
+		if rtt < l.Min {
+			l.Min = rtt
+		}
+		if rtt > l.Max {
+			l.Max = rtt
+		}
+	}
+	l.Samples++
+}
+
+// 发送应用层 ping 和 websocket 协议层 ping, payload 为发送时间
+func (c *WSClient) sendPing() error {
+	now := time.Now().UnixNano()
+	id := atomic.AddInt64(&c.seq, 1)
+	atomic.StoreInt64(&c.hb.pingID, id)
+	atomic.StoreInt64(&c.hb.pingAt, now)
+
+	if err := c.sendMethod(id, "server.ping", struct{}{}); err != nil {
+		return errors.WithStack(err)
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	cli := c.cli.Load()
+	if cli == nil {
+		return nil
+	}
+	payload := []byte(strconv.FormatInt(now, 10))
+	if err := cli.WriteControl(websocket.PingMessage, payload, time.Now().Add(5*time.Second)); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// 检查是否超时未收到 pong
+func (c *WSClient) checkStale() bool {
+	lastPong := atomic.LoadInt64(&c.hb.lastPong)
+	if time.Since(time.Unix(0, lastPong)) <= c.pongTimeout() {
+		return false
+	}
+	c.markStale("pong timeout")
+	return true
+}
+
+// 标记连接失效并关闭连接, 阻塞中的 Read 返回后重连
+func (c *WSClient) markStale(reason string) {
+	if !atomic.CompareAndSwapInt32(&c.hb.stale, 0, 1) {
+		return
+	}
+
+	c.logger.Warn("websocket stale",
+		zap.String("reason", reason),
+		zap.Time("last_pong", time.Unix(0, atomic.LoadInt64(&c.hb.lastPong))))
+
+	// 置空后 Close 不再关闭同一连接, 由 Read 重连
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if cli := c.cli.Swap(nil); cli != nil {
+		_ = cli.Close()
+	}
+}
+
+// 重连并恢复订阅
+func (c *WSClient) reconnect() (*StaleEvent, error) {
+	event := &StaleEvent{
+		LastPong: time.Unix(0, atomic.LoadInt64(&c.hb.lastPong)),
+		At:       time.Now(),
+	}
+
+	select {
+	case <-c.stop:
+		return nil, errors.New("websocket closed")
+	default:
+	}
+
+	if err := c.dial(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	// 重连期间 Close 时不再保留新连接
+	c.lock.Lock()
+	if c.closed() {
+		if cli := c.cli.Swap(nil); cli != nil {
+			_ = cli.Close()
+		}
+		c.lock.Unlock()
+		return nil, errors.New("websocket closed")
+	}
+	c.lock.Unlock()
+	atomic.StoreInt32(&c.hb.stale, 0)
+
+	// 同一连接按顺序处理, 认证先于订阅生效
+	if key, secret := c.credentials(); key != "" {
+		if err := c.Login(key, secret); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+
+	if subs := c.Subscriptions(); len(subs) > 0 {
+		if err := c.Subscribe(subs); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+
+	c.logger.Info("websocket reconnected", zap.String("url", c.url))
+	return event, nil
+}
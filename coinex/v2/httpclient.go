@@ -19,6 +19,8 @@ type HTTPClient struct {
 	secret string
 	cli    *http.Client
 	logger *zap.Logger
+	// 设置后 SpotOrder 发送前先在本地校验
+	validator *Validator
 }
 
 func NewHTTPClient(url, key, secret string, logger *zap.Logger) *HTTPClient {
@@ -31,6 +33,16 @@ func NewHTTPClient(url, key, secret string, logger *zap.Logger) *HTTPClient {
 	}
 }
 
+// 替换默认的 http.Client, 如设置代理, 超时或录制回放
+func (c *HTTPClient) SetHTTPClient(cli *http.Client) {
+	c.cli = cli
+}
+
+// 设置下单前的本地校验, 校验不通过时 SpotOrder 和 SpotBatchOrder 返回 *ValidationError 而不发送请求, 为 nil 时关闭
+func (c *HTTPClient) SetValidator(v *Validator) {
+	c.validator = v
+}
+
 func (c *HTTPClient) Request(method, path string, query url.Values, body map[string]interface{}, auth bool) ([]byte, error) {
 	var (
 		reqBody []byte
@@ -456,6 +468,12 @@ func (c *HTTPClient) SpotBalance() ([]*SpotBalance, error) {
 func (c *HTTPClient) SpotOrder(market, marketType, side, type_, ccy, amount, price, clientId string) (*SpotOrder, error) {
 	method := http.MethodPost
 	path := "/v2/spot/order"
+	if c.validator != nil {
+		if err := c.validator.validateArgs(market, marketType, side, type_, ccy, amount, price, clientId); err != nil {
+			c.logger.Error(method+" "+path, zap.Error(err))
+			return nil, errors.WithStack(err)
+		}
+	}
 	body := make(map[string]interface{})
 	body["market"] = market
 	body["market_type"] = marketType
@@ -498,6 +516,175 @@ func (c *HTTPClient) SpotOrder(market, marketType, side, type_, ccy, amount, pri
 	return reply.Data, nil
 }
 
+// 使用 SpotOrderRequest 下单, 先检查参数格式, 设置了 Validator 时再按市场规则校验
+func (c *HTTPClient) PlaceSpotOrder(req *SpotOrderRequest) (*SpotOrder, error) {
+	method := http.MethodPost
+	path := "/v2/spot/order"
+	if err := req.Validate(); err != nil {
+		c.logger.Error(method+" "+path, zap.Error(err))
+		return nil, errors.WithStack(err)
+	}
+	if c.validator != nil {
+		if err := c.validator.ValidateRequest(req); err != nil {
+			c.logger.Error(method+" "+path, zap.Error(err))
+			return nil, errors.WithStack(err)
+		}
+	}
+
+	resp, err := c.Request(method, path, nil, req.body(), true)
+	if err != nil {
+		c.logger.Error(method+" "+path, zap.Error(err))
+		return nil, errors.WithStack(err)
+	}
+
+	var reply struct {
+		Code    int        `json:"code"`
+		Data    *SpotOrder `json:"data"`
+		Message string     `json:"message"`
+	}
+	if err := json.Unmarshal(resp, &reply); err != nil {
+		c.logger.Error(method+" "+path, zap.String("resp", string(resp)), zap.Error(err))
+		err := ErrResponseBody(resp)
+		return nil, errors.WithStack(err)
+	}
+
+	if reply.Code != 0 {
+		c.logger.Error(method+" "+path, zap.String("resp", string(resp)), zap.Error(err))
+		err := NewErrResponse(reply.Code, reply.Message)
+		return nil, errors.WithStack(err)
+	}
+
+	return reply.Data, nil
+}
+
+// 每次批量下单或撤单的最大订单数
+const batchOrderLimit = 20
+
+// 失败时返回对应的 *ErrResponse
+func (r *BatchOrderResult) Err() error {
+	if r.Code == 0 {
+		return nil
+	}
+	return NewErrResponse(r.Code, r.Message)
+}
+
+// 批量下单, 结果与请求一一对应, 单个订单失败不影响其它订单
+// 超过 20 个时分多次请求, 中途请求失败时返回已完成部分的结果和错误
+// 设置了 Validator 时逐个校验, 余额按单个订单检查, 不累计同一批次的其它订单
+func (c *HTTPClient) SpotBatchOrder(reqs []*SpotOrderRequest) ([]*BatchOrderResult, error) {
+	method := http.MethodPost
+	path := "/v2/spot/batch-order"
+
+	var list []Violation
+	for i, req := range reqs {
+		err := req.Validate()
+		if err == nil && c.validator != nil {
+			err = c.validator.ValidateRequest(req)
+		}
+		var e *ValidationError
+		if errors.As(err, &e) {
+			for _, v := range e.Violations {
+				v.Field = "orders[" + strconv.Itoa(i) + "]." + v.Field
+				list = append(list, v)
+			}
+		} else if err != nil {
+			c.logger.Error(method+" "+path, zap.Error(err))
+			return nil, errors.WithStack(err)
+		}
+	}
+	if len(list) > 0 {
+		err := &ValidationError{Violations: list}
+		c.logger.Error(method+" "+path, zap.Error(err))
+		return nil, errors.WithStack(err)
+	}
+
+	results := make([]*BatchOrderResult, 0, len(reqs))
+	for start := 0; start < len(reqs); start += batchOrderLimit {
+		end := start + batchOrderLimit
+		if end > len(reqs) {
+			end = len(reqs)
+		}
+		orders := make([]map[string]interface{}, 0, end-start)
+		for _, req := range reqs[start:end] {
+			orders = append(orders, req.body())
+		}
+		body := make(map[string]interface{})
+		body["orders"] = orders
+
+		reply, err := c.batch(method, path, body)
+		if err != nil {
+			return results, errors.WithStack(err)
+		}
+		for i, item := range reply {
+			if start+i < end {
+				item.ClientID = reqs[start+i].ClientID
+			}
+		}
+		results = append(results, reply...)
+	}
+
+	return results, nil
+}
+
+// 批量撤销同一市场的订单, 结果与请求一一对应, 单个订单失败不影响其它订单
+// 超过 20 个时分多次请求, 中途请求失败时返回已完成部分的结果和错误
+func (c *HTTPClient) SpotCancelBatchOrder(market, marketType string, orderIDs []int64) ([]*BatchOrderResult, error) {
+	method := http.MethodPost
+	path := "/v2/spot/cancel-batch-order"
+
+	results := make([]*BatchOrderResult, 0, len(orderIDs))
+	for start := 0; start < len(orderIDs); start += batchOrderLimit {
+		end := start + batchOrderLimit
+		if end > len(orderIDs) {
+			end = len(orderIDs)
+		}
+		body := make(map[string]interface{})
+		body["market"] = market
+		body["market_type"] = marketType
+		body["order_ids"] = orderIDs[start:end]
+
+		reply, err := c.batch(method, path, body)
+		if err != nil {
+			return results, errors.WithStack(err)
+		}
+		for i, item := range reply {
+			if start+i < end {
+				item.OrderID = orderIDs[start+i]
+			}
+		}
+		results = append(results, reply...)
+	}
+
+	return results, nil
+}
+
+func (c *HTTPClient) batch(method, path string, body map[string]interface{}) ([]*BatchOrderResult, error) {
+	resp, err := c.Request(method, path, nil, body, true)
+	if err != nil {
+		c.logger.Error(method+" "+path, zap.Error(err))
+		return nil, errors.WithStack(err)
+	}
+
+	var reply struct {
+		Code    int                 `json:"code"`
+		Data    []*BatchOrderResult `json:"data"`
+		Message string              `json:"message"`
+	}
+	if err := json.Unmarshal(resp, &reply); err != nil {
+		c.logger.Error(method+" "+path, zap.String("resp", string(resp)), zap.Error(err))
+		err := ErrResponseBody(resp)
+		return nil, errors.WithStack(err)
+	}
+
+	if reply.Code != 0 {
+		c.logger.Error(method+" "+path, zap.String("resp", string(resp)), zap.Error(err))
+		err := NewErrResponse(reply.Code, reply.Message)
+		return nil, errors.WithStack(err)
+	}
+
+	return reply.Data, nil
+}
+
 func (c *HTTPClient) SpotCancelOrder(market, marketType string, orderID int64) (*SpotOrder, error) {
 	method := http.MethodPost
 	path := "/v2/spot/cancel-order"
@@ -532,6 +719,96 @@ func (c *HTTPClient) SpotCancelOrder(market, marketType string, orderID int64) (
 	return reply.Data, nil
 }
 
+// 修改挂单的数量或价格, 返回修改后的订单
+func (c *HTTPClient) SpotModifyOrder(req *SpotModifyOrderRequest) (*SpotOrder, error) {
+	method := http.MethodPost
+	path := "/v2/spot/modify-order"
+	if err := req.Validate(); err != nil {
+		c.logger.Error(method+" "+path, zap.Error(err))
+		return nil, errors.WithStack(err)
+	}
+
+	resp, err := c.Request(method, path, nil, req.body(), true)
+	if err != nil {
+		c.logger.Error(method+" "+path, zap.Error(err))
+		return nil, errors.WithStack(err)
+	}
+
+	var reply struct {
+		Code    int        `json:"code"`
+		Data    *SpotOrder `json:"data"`
+		Message string     `json:"message"`
+	}
+	if err := json.Unmarshal(resp, &reply); err != nil {
+		c.logger.Error(method+" "+path, zap.String("resp", string(resp)), zap.Error(err))
+		err := ErrResponseBody(resp)
+		return nil, errors.WithStack(err)
+	}
+
+	if reply.Code != 0 {
+		c.logger.Error(method+" "+path, zap.String("resp", string(resp)), zap.Error(err))
+		err := NewErrResponse(reply.Code, reply.Message)
+		return nil, errors.WithStack(err)
+	}
+
+	return reply.Data, nil
+}
+
+// 按客户端 ID 撤单, 同一客户端 ID 的全部挂单都会被撤销, 返回每个订单的撤单结果
+func (c *HTTPClient) SpotCancelOrderByClientID(market, marketType, clientId string) ([]*BatchOrderResult, error) {
+	method := http.MethodPost
+	path := "/v2/spot/cancel-order-by-client-id"
+	body := make(map[string]interface{})
+	body["market"] = market
+	body["market_type"] = marketType
+	body["client_id"] = clientId
+
+	reply, err := c.batch(method, path, body)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	for _, item := range reply {
+		item.ClientID = clientId
+		if item.Data != nil {
+			item.OrderID = item.Data.OrderID
+		}
+	}
+	return reply, nil
+}
+
+// 撤销市场的全部挂单, side 为空时撤销买卖双方
+// 交易所的 cancel-all-order 不返回被撤销的订单, 这里先分页查询挂单再通过 SpotCancelBatchOrder 撤销,
+// 结果与查询到的挂单一一对应, 查询之后新下的订单不会被撤销
+func (c *HTTPClient) SpotCancelAllOrder(market, marketType, side string) ([]*BatchOrderResult, error) {
+	q := &SpotOrderQuery{
+		Market:     market,
+		MarketType: marketType,
+		Side:       Side(side),
+		Page:       1,
+		Limit:      100,
+	}
+	var orderIDs []int64
+	for {
+		orders, hasNext, err := c.SpotPendingOrder(q)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		for _, order := range orders {
+			orderIDs = append(orderIDs, order.OrderID)
+		}
+		if !hasNext || len(orders) == 0 {
+			break
+		}
+		q.Page++
+	}
+
+	results, err := c.SpotCancelBatchOrder(market, marketType, orderIDs)
+	if err != nil {
+		return results, errors.WithStack(err)
+	}
+	return results, nil
+}
+
 func (c *HTTPClient) SpotOrderStatus(market string, orderID int64) (*SpotOrder, error) {
 	method := http.MethodGet
 	path := "/v2/spot/order-status"
@@ -567,29 +844,210 @@ func (c *HTTPClient) SpotOrderStatus(market string, orderID int64) (*SpotOrder,
 }
 
 func (c *HTTPClient) SpotFinishedOrder(market, market_type, side string, page, limit int) ([]*SpotOrder, error) {
+	q := &SpotOrderQuery{
+		Market:     market,
+		MarketType: market_type,
+		Side:       Side(side),
+		Page:       page,
+		Limit:      limit,
+	}
+	orders, _, err := c.finishedOrder(q)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return orders, nil
+}
+
+func (c *HTTPClient) finishedOrder(q *SpotOrderQuery) ([]*SpotOrder, bool, error) {
 	method := http.MethodGet
 	path := "/v2/spot/finished-order"
-	query := url.Values{}
-	if market != "" {
-		query.Add("market", market)
+	resp, err := c.Request(method, path, q.values(), nil, true)
+	if err != nil {
+		c.logger.Error(path, zap.Error(err))
+		return nil, false, errors.WithStack(err)
 	}
-	query.Add("market_type", market_type)
-	if side != "" {
-		query.Add("side", side)
+
+	var reply struct {
+		Code       int          `json:"code"`
+		Data       []*SpotOrder `json:"data"`
+		Message    string       `json:"message"`
+		Pagination struct {
+			HasNext bool `json:"has_next"`
+		} `json:"pagination"`
 	}
-	if page != 0 {
-		query.Add("page", strconv.Itoa(page))
+
+	if err := json.Unmarshal(resp, &reply); err != nil {
+		c.logger.Error(path, zap.String("resp", string(resp)), zap.Error(err))
+		err := ErrResponseBody(resp)
+		return nil, false, errors.WithStack(err)
 	}
-	if limit != 0 {
-		query.Add("limit", strconv.Itoa(limit))
+
+	if reply.Code != 0 {
+		c.logger.Error(path, zap.String("resp", string(resp)), zap.Error(err))
+		err := NewErrResponse(reply.Code, reply.Message)
+		return nil, false, errors.WithStack(err)
 	}
 
-	resp, err := c.Request(method, path, query, nil, true)
+	return reply.Data, reply.Pagination.HasNext, nil
+}
+
+// 使用 SpotStopOrderRequest 下计划委托, 返回计划委托 ID
+func (c *HTTPClient) PlaceSpotStopOrder(req *SpotStopOrderRequest) (int64, error) {
+	method := http.MethodPost
+	path := "/v2/spot/stop-order"
+	if err := req.Validate(); err != nil {
+		c.logger.Error(method+" "+path, zap.Error(err))
+		return 0, errors.WithStack(err)
+	}
+
+	resp, err := c.Request(method, path, nil, req.body(), true)
 	if err != nil {
-		c.logger.Error(path, zap.Error(err))
+		c.logger.Error(method+" "+path, zap.Error(err))
+		return 0, errors.WithStack(err)
+	}
+
+	var reply struct {
+		Code int `json:"code"`
+		Data struct {
+			StopID int64 `json:"stop_id"`
+		} `json:"data"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(resp, &reply); err != nil {
+		c.logger.Error(method+" "+path, zap.String("resp", string(resp)), zap.Error(err))
+		err := ErrResponseBody(resp)
+		return 0, errors.WithStack(err)
+	}
+
+	if reply.Code != 0 {
+		c.logger.Error(method+" "+path, zap.String("resp", string(resp)), zap.Error(err))
+		err := NewErrResponse(reply.Code, reply.Message)
+		return 0, errors.WithStack(err)
+	}
+
+	return reply.Data.StopID, nil
+}
+
+// 修改未触发的计划委托
+func (c *HTTPClient) SpotModifyStopOrder(req *SpotModifyStopOrderRequest) error {
+	method := http.MethodPost
+	path := "/v2/spot/modify-stop-order"
+	if err := req.Validate(); err != nil {
+		c.logger.Error(method+" "+path, zap.Error(err))
+		return errors.WithStack(err)
+	}
+
+	resp, err := c.Request(method, path, nil, req.body(), true)
+	if err != nil {
+		c.logger.Error(method+" "+path, zap.Error(err))
+		return errors.WithStack(err)
+	}
+
+	var reply struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(resp, &reply); err != nil {
+		c.logger.Error(method+" "+path, zap.String("resp", string(resp)), zap.Error(err))
+		err := ErrResponseBody(resp)
+		return errors.WithStack(err)
+	}
+
+	if reply.Code != 0 {
+		c.logger.Error(method+" "+path, zap.String("resp", string(resp)), zap.Error(err))
+		err := NewErrResponse(reply.Code, reply.Message)
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
+
+// 撤销未触发的计划委托, 返回撤销的计划委托
+func (c *HTTPClient) SpotCancelStopOrder(market, marketType string, stopID int64) (*SpotStopOrder, error) {
+	method := http.MethodPost
+	path := "/v2/spot/cancel-stop-order"
+	body := make(map[string]interface{})
+	body["market"] = market
+	body["market_type"] = marketType
+	body["stop_id"] = stopID
+
+	resp, err := c.Request(method, path, nil, body, true)
+	if err != nil {
+		c.logger.Error(method+" "+path, zap.Error(err))
 		return nil, errors.WithStack(err)
 	}
 
+	var reply struct {
+		Code    int            `json:"code"`
+		Data    *SpotStopOrder `json:"data"`
+		Message string         `json:"message"`
+	}
+	if err := json.Unmarshal(resp, &reply); err != nil {
+		c.logger.Error(method+" "+path, zap.String("resp", string(resp)), zap.Error(err))
+		err := ErrResponseBody(resp)
+		return nil, errors.WithStack(err)
+	}
+
+	if reply.Code != 0 {
+		c.logger.Error(method+" "+path, zap.String("resp", string(resp)), zap.Error(err))
+		err := NewErrResponse(reply.Code, reply.Message)
+		return nil, errors.WithStack(err)
+	}
+
+	return reply.Data, nil
+}
+
+// 查询未触发的计划委托, 第二个返回值表示是否还有下一页
+func (c *HTTPClient) SpotPendingStopOrder(q *SpotOrderQuery) ([]*SpotStopOrder, bool, error) {
+	return c.stopOrders("/v2/spot/pending-stop-order", q)
+}
+
+// 查询已触发或已撤销的计划委托, 第二个返回值表示是否还有下一页
+func (c *HTTPClient) SpotFinishedStopOrder(q *SpotOrderQuery) ([]*SpotStopOrder, bool, error) {
+	return c.stopOrders("/v2/spot/finished-stop-order", q)
+}
+
+func (c *HTTPClient) stopOrders(path string, q *SpotOrderQuery) ([]*SpotStopOrder, bool, error) {
+	method := http.MethodGet
+	resp, err := c.Request(method, path, q.values(), nil, true)
+	if err != nil {
+		c.logger.Error(path, zap.Error(err))
+		return nil, false, errors.WithStack(err)
+	}
+
+	var reply struct {
+		Code       int              `json:"code"`
+		Data       []*SpotStopOrder `json:"data"`
+		Message    string           `json:"message"`
+		Pagination struct {
+			HasNext bool `json:"has_next"`
+		} `json:"pagination"`
+	}
+	if err := json.Unmarshal(resp, &reply); err != nil {
+		c.logger.Error(path, zap.String("resp", string(resp)), zap.Error(err))
+		err := ErrResponseBody(resp)
+		return nil, false, errors.WithStack(err)
+	}
+
+	if reply.Code != 0 {
+		c.logger.Error(path, zap.String("resp", string(resp)), zap.Error(err))
+		err := NewErrResponse(reply.Code, reply.Message)
+		return nil, false, errors.WithStack(err)
+	}
+
+	return reply.Data, reply.Pagination.HasNext, nil
+}
+
+// 查询未完成的挂单, 第二个返回值表示是否还有下一页
+func (c *HTTPClient) SpotPendingOrder(q *SpotOrderQuery) ([]*SpotOrder, bool, error) {
+	method := http.MethodGet
+	path := "/v2/spot/pending-order"
+	resp, err := c.Request(method, path, q.values(), nil, true)
+	if err != nil {
+		c.logger.Error(path, zap.Error(err))
+		return nil, false, errors.WithStack(err)
+	}
+
 	var reply struct {
 		Code       int          `json:"code"`
 		Data       []*SpotOrder `json:"data"`
@@ -598,18 +1056,60 @@ func (c *HTTPClient) SpotFinishedOrder(market, market_type, side string, page, l
 			HasNext bool `json:"has_next"`
 		} `json:"pagination"`
 	}
+	if err := json.Unmarshal(resp, &reply); err != nil {
+		c.logger.Error(path, zap.String("resp", string(resp)), zap.Error(err))
+		err := ErrResponseBody(resp)
+		return nil, false, errors.WithStack(err)
+	}
+
+	if reply.Code != 0 {
+		c.logger.Error(path, zap.String("resp", string(resp)), zap.Error(err))
+		err := NewErrResponse(reply.Code, reply.Message)
+		return nil, false, errors.WithStack(err)
+	}
+
+	return reply.Data, reply.Pagination.HasNext, nil
+}
+
+// 查询个人成交记录, 第二个返回值表示是否还有下一页
+// 设置了 ClientID 时在本地过滤, 返回的数量可能少于 Limit
+func (c *HTTPClient) SpotUserDeals(q *SpotDealQuery) ([]*SpotUserDeal, bool, error) {
+	method := http.MethodGet
+	path := "/v2/spot/user-deals"
+	resp, err := c.Request(method, path, q.values(), nil, true)
+	if err != nil {
+		c.logger.Error(path, zap.Error(err))
+		return nil, false, errors.WithStack(err)
+	}
 
+	var reply struct {
+		Code       int             `json:"code"`
+		Data       []*SpotUserDeal `json:"data"`
+		Message    string          `json:"message"`
+		Pagination struct {
+			HasNext bool `json:"has_next"`
+		} `json:"pagination"`
+	}
 	if err := json.Unmarshal(resp, &reply); err != nil {
 		c.logger.Error(path, zap.String("resp", string(resp)), zap.Error(err))
 		err := ErrResponseBody(resp)
-		return nil, errors.WithStack(err)
+		return nil, false, errors.WithStack(err)
 	}
 
 	if reply.Code != 0 {
 		c.logger.Error(path, zap.String("resp", string(resp)), zap.Error(err))
 		err := NewErrResponse(reply.Code, reply.Message)
-		return nil, errors.WithStack(err)
+		return nil, false, errors.WithStack(err)
 	}
 
-	return reply.Data, nil
+	deals := reply.Data
+	if q.ClientID != "" {
+		deals = make([]*SpotUserDeal, 0, len(reply.Data))
+		for _, item := range reply.Data {
+			if item.ClientID == q.ClientID {
+				deals = append(deals, item)
+			}
+		}
+	}
+	return deals, reply.Pagination.HasNext, nil
 }
@@ -1,9 +1,15 @@
 package coinex
 
 import (
+	"context"
 	"flag"
 	"testing"
+	"time"
 
+	"github.com/icwl/go-exchange-api/cassette"
+	"github.com/icwl/go-exchange-api/paging"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
 	"go.uber.org/zap"
 )
 
@@ -12,11 +18,13 @@ var (
 	pSecret  = flag.String("secret", "", "secret")
 	pHTTPURL = flag.String("http_url", HTTPURL, "http_url")
 	pWSURL   = flag.String("ws_url", WSURL, "ws_url")
+	pRecord  = flag.Bool("record", false, "请求真实服务器并重新录制 testdata/cassettes")
 
 	key     string
 	secret  string
 	httpURL string
 	wsURL   string
+	record  bool
 )
 
 func init() {
@@ -28,12 +36,36 @@ func init() {
 	secret = *pSecret
 	httpURL = *pHTTPURL
 	wsURL = *pWSURL
+	record = *pRecord
+}
+
+// 默认回放 testdata/cassettes, 不访问网络
+// testdata/cassettes 中的文件为按接口文档手工编写的数据, 不是真实交易所的响应
+// -record 时使用 -key/-secret 请求真实服务器并重新录制, 录制文件中的 key 和 secret 会被替换
+func newTestHTTPClient(t *testing.T) *HTTPClient {
+	mode := cassette.ModeReplay
+	if record {
+		mode = cassette.ModeRecord
+	}
+	c, err := cassette.New("testdata/cassettes/"+t.Name()+".json", mode, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Scrub(key, secret)
+	t.Cleanup(func() {
+		if err := c.Stop(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	client := NewHTTPClient(httpURL, key, secret, zap.NewExample())
+	client.SetHTTPClient(c.Client())
+	return client
 }
 
 func TestHTTPClient_SpotMarket(t *testing.T) {
 
-	logger := zap.NewExample()
-	client := NewHTTPClient(httpURL, key, secret, logger)
+	client := newTestHTTPClient(t)
 
 	res, err := client.SpotMarket("")
 	if err != nil {
@@ -47,8 +79,7 @@ func TestHTTPClient_SpotMarket(t *testing.T) {
 
 func TestHTTPClient_SpotKLine(t *testing.T) {
 
-	logger := zap.NewExample()
-	client := NewHTTPClient(httpURL, key, secret, logger)
+	client := newTestHTTPClient(t)
 
 	res, err := client.SpotKLine("LATUSDT", "", 10, "15min")
 	if err != nil {
@@ -62,8 +93,7 @@ func TestHTTPClient_SpotKLine(t *testing.T) {
 
 func TestHTTPClient_SpotDepth(t *testing.T) {
 
-	logger := zap.NewExample()
-	client := NewHTTPClient(httpURL, key, secret, logger)
+	client := newTestHTTPClient(t)
 
 	res, err := client.SpotDepth("BTCUSDT", 5, "0")
 	if err != nil {
@@ -80,8 +110,7 @@ func TestHTTPClient_SpotDepth(t *testing.T) {
 }
 
 func TestHTTPClient_DepositWithdrawConfig(t *testing.T) {
-	logger := zap.NewExample()
-	client := NewHTTPClient(httpURL, key, secret, logger)
+	client := newTestHTTPClient(t)
 
 	res, err := client.DepositWithdrawConfig("LADYS")
 	if err != nil {
@@ -92,8 +121,7 @@ func TestHTTPClient_DepositWithdrawConfig(t *testing.T) {
 }
 
 func TestHTTPClient_Info(t *testing.T) {
-	logger := zap.NewExample()
-	client := NewHTTPClient(httpURL, key, secret, logger)
+	client := newTestHTTPClient(t)
 
 	res, err := client.Info("USDT")
 	if err != nil {
@@ -106,8 +134,7 @@ func TestHTTPClient_Info(t *testing.T) {
 }
 
 func TestHTTPClient_SpotOrderStatus(t *testing.T) {
-	logger := zap.NewExample()
-	client := NewHTTPClient(httpURL, key, secret, logger)
+	client := newTestHTTPClient(t)
 
 	res, err := client.SpotOrderStatus("DOGEUSDT", 112906854752)
 	if err != nil {
@@ -117,9 +144,214 @@ func TestHTTPClient_SpotOrderStatus(t *testing.T) {
 	t.Logf("查询订单状态 : %+v", res)
 }
 
+func TestHTTPClient_PlaceSpotOrder(t *testing.T) {
+	cli := newTestHTTPClient(t)
+	order, err := cli.PlaceSpotOrder(&SpotOrderRequest{
+		Market:   "BTCUSDT",
+		Side:     SideSell,
+		Type:     OrderTypeMakerOnly,
+		Amount:   decimal.RequireFromString("0.01"),
+		Price:    decimal.RequireFromString("30000"),
+		ClientID: "hidden-1",
+		IsHide:   true,
+		STPMode:  STPModeCancelTaker,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.ClientID != "hidden-1" || order.Type != OrderTypeMakerOnly {
+		t.Fatalf("unexpected order: %+v", order)
+	}
+}
+
+func TestHTTPClient_SpotBatchOrder(t *testing.T) {
+	cli := newTestHTTPClient(t)
+
+	reqs := []*SpotOrderRequest{
+		{Market: "BTCUSDT", Side: SideBuy, Type: OrderTypeLimit, Amount: decimal.RequireFromString("0.001"), Price: decimal.RequireFromString("29990"), ClientID: "ladder-0"},
+		{Market: "BTCUSDT", Side: SideBuy, Type: OrderTypeLimit, Amount: decimal.RequireFromString("0.001"), Price: decimal.RequireFromString("29989"), ClientID: "ladder-1"},
+	}
+	results, err := cli.SpotBatchOrder(reqs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var e *ErrResponse
+	if len(results) != 2 || results[0].Err() != nil || results[1].ClientID != "ladder-1" || !errors.As(results[1].Err(), &e) || e.Code != 3109 {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+
+	cancelled, err := cli.SpotCancelBatchOrder("BTCUSDT", MarketTypeSpot, []int64{results[0].Data.OrderID, 112906854771})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cancelled) != 2 || cancelled[0].Data.Status != OrderStatusCanceled || cancelled[1].OrderID != 112906854771 || cancelled[1].Err() == nil {
+		t.Fatalf("unexpected results: %+v", cancelled)
+	}
+}
+
+func TestHTTPClient_SpotModifyOrder(t *testing.T) {
+	cli := newTestHTTPClient(t)
+
+	// 数量和价格都未设置时不发送请求
+	_, err := cli.SpotModifyOrder(&SpotModifyOrderRequest{Market: "BTCUSDT", OrderID: 112906854782})
+	var verr *ValidationError
+	if !errors.As(err, &verr) || !verr.Has(ReasonInvalidValue) {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+
+	order, err := cli.SpotModifyOrder(&SpotModifyOrderRequest{
+		Market:  "BTCUSDT",
+		OrderID: 112906854782,
+		Price:   decimal.RequireFromString("29980"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.OrderID != 112906854782 || !order.Price.Equal(decimal.RequireFromString("29980")) {
+		t.Fatalf("unexpected order: %+v", order)
+	}
+}
+
+func TestHTTPClient_SpotCancelAllOrder(t *testing.T) {
+	cli := newTestHTTPClient(t)
+
+	results, err := cli.SpotCancelOrderByClientID("BTCUSDT", MarketTypeSpot, "quote-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[1].OrderID != 112906854781 || results[1].Data.Status != OrderStatusCanceled {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+
+	// 查询到两个挂单, 第二个在撤单前已成交
+	results, err = cli.SpotCancelAllOrder("BTCUSDT", MarketTypeSpot, "sell")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].OrderID != 112906854790 || results[0].Data.Status != OrderStatusCanceled {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+	if results[1].OrderID != 112906854791 || results[1].Code == 0 || results[1].Data != nil {
+		t.Fatalf("unexpected result: %+v", results[1])
+	}
+}
+
+func TestHTTPClient_SpotFinishedOrderIterator(t *testing.T) {
+	cli := newTestHTTPClient(t)
+
+	// 按创建时间倒序, 遇到早于起始时间的订单后不再翻页
+	it := cli.SpotFinishedOrderIterator(SpotOrderQuery{Market: "BTCUSDT", Limit: 2})
+	it.SetTimeWindow(time.UnixMilli(1700000050000), time.Time{})
+	orders, err := it.All(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 3 || orders[2].OrderID != 112906854802 {
+		t.Fatalf("unexpected orders: %+v", orders)
+	}
+}
+
+func TestHTTPClient_SpotStopOrder(t *testing.T) {
+	cli := newTestHTTPClient(t)
+
+	// 止损卖单, 价格跌破触发价时以 27900 挂单
+	stopID, err := cli.PlaceSpotStopOrder(&SpotStopOrderRequest{
+		Market:       "BTCUSDT",
+		Side:         SideSell,
+		Type:         OrderTypeLimit,
+		Amount:       decimal.RequireFromString("0.001"),
+		TriggerPrice: decimal.RequireFromString("28100"),
+		Price:        decimal.RequireFromString("27900"),
+		ClientID:     "stop-1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stopID != 117180138153 {
+		t.Fatalf("unexpected stop id: %d", stopID)
+	}
+
+	err = cli.SpotModifyStopOrder(&SpotModifyStopOrderRequest{
+		Market:       "BTCUSDT",
+		StopID:       stopID,
+		TriggerPrice: decimal.RequireFromString("28000"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	orders, hasNext, err := cli.SpotPendingStopOrder(&SpotOrderQuery{Market: "BTCUSDT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 1 || hasNext || orders[0].TriggerDirection != TriggerDirectionLower {
+		t.Fatalf("unexpected orders: %+v", orders)
+	}
+
+	order, err := cli.SpotCancelStopOrder("BTCUSDT", MarketTypeSpot, stopID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.StopID != stopID || !order.TriggerPrice.Equal(decimal.RequireFromString("28000")) {
+		t.Fatalf("unexpected order: %+v", order)
+	}
+
+	orders, hasNext, err = cli.SpotFinishedStopOrder(&SpotOrderQuery{Market: "BTCUSDT", Page: 1, Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 1 || !hasNext {
+		t.Fatalf("unexpected orders: %+v", orders)
+	}
+}
+
+func TestHTTPClient_SpotPendingOrder(t *testing.T) {
+	cli := newTestHTTPClient(t)
+	ctx := context.Background()
+
+	it := cli.SpotPendingOrderIterator(SpotOrderQuery{Market: "BTCUSDT", Side: SideBuy, Limit: 2})
+	orders, err := it.All(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 3 || orders[2].OrderID != 112906854792 {
+		t.Fatalf("unexpected orders: %+v", orders)
+	}
+	if _, err := it.Next(ctx); !errors.Is(err, paging.ErrDone) {
+		t.Fatalf("expected ErrDone, got %v", err)
+	}
+
+	// 交易所不支持按 client_id 查询成交, 在本地过滤
+	deals := cli.SpotUserDealIterator(SpotDealQuery{
+		Market:    "BTCUSDT",
+		ClientID:  "quote-1",
+		StartTime: time.UnixMilli(1700000000000),
+		EndTime:   time.UnixMilli(1700086400000),
+		Limit:     2,
+	})
+	var fee decimal.Decimal
+	var n int
+	for {
+		item, err := deals.Next(ctx)
+		if errors.Is(err, paging.ErrDone) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if item.ClientID != "quote-1" {
+			t.Fatalf("unexpected deal: %+v", item)
+		}
+		fee = fee.Add(item.Fee)
+		n++
+	}
+	if n != 2 || !fee.Equal(decimal.RequireFromString("0.000004")) {
+		t.Fatalf("unexpected deals: %d, fee %s", n, fee)
+	}
+}
+
 func TestHTTPClient_SpotFinishedOrder(t *testing.T) {
-	logger := zap.NewExample()
-	client := NewHTTPClient(httpURL, key, secret, logger)
+	client := newTestHTTPClient(t)
 
 	res, err := client.SpotFinishedOrder("", MarketTypeSpot, "", 0, 0)
 	if err != nil {
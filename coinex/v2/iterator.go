@@ -0,0 +1,70 @@
+package coinex
+
+import (
+	"time"
+
+	"github.com/icwl/go-exchange-api/paging"
+)
+
+func orderTime(o *SpotOrder) time.Time {
+	return time.UnixMilli(o.CreatedAt)
+}
+
+func stopOrderTime(o *SpotStopOrder) time.Time {
+	return time.UnixMilli(o.CreatedAt)
+}
+
+func userDealTime(d *SpotUserDeal) time.Time {
+	return time.UnixMilli(d.CreatedAt)
+}
+
+// 遍历未完成的挂单, 从查询条件的 Page 开始, 按交易所返回的 has_next 翻页
+func (c *HTTPClient) SpotPendingOrderIterator(q SpotOrderQuery) *paging.Iterator[*SpotOrder] {
+	it := paging.New(q.Page, func(page int) ([]*SpotOrder, bool, error) {
+		q.Page = page
+		return c.SpotPendingOrder(&q)
+	})
+	it.SetTimeOf(orderTime, false)
+	return it
+}
+
+// 遍历已完成的订单, 按创建时间倒序
+func (c *HTTPClient) SpotFinishedOrderIterator(q SpotOrderQuery) *paging.Iterator[*SpotOrder] {
+	it := paging.New(q.Page, func(page int) ([]*SpotOrder, bool, error) {
+		q.Page = page
+		return c.finishedOrder(&q)
+	})
+	it.SetTimeOf(orderTime, true)
+	return it
+}
+
+// 遍历未触发的计划委托
+func (c *HTTPClient) SpotPendingStopOrderIterator(q SpotOrderQuery) *paging.Iterator[*SpotStopOrder] {
+	it := paging.New(q.Page, func(page int) ([]*SpotStopOrder, bool, error) {
+		q.Page = page
+		return c.SpotPendingStopOrder(&q)
+	})
+	it.SetTimeOf(stopOrderTime, false)
+	return it
+}
+
+// 遍历已触发或已撤销的计划委托, 按创建时间倒序
+func (c *HTTPClient) SpotFinishedStopOrderIterator(q SpotOrderQuery) *paging.Iterator[*SpotStopOrder] {
+	it := paging.New(q.Page, func(page int) ([]*SpotStopOrder, bool, error) {
+		q.Page = page
+		return c.SpotFinishedStopOrder(&q)
+	})
+	it.SetTimeOf(stopOrderTime, true)
+	return it
+}
+
+// 遍历个人成交记录, 按成交时间倒序
+// 设置了 ClientID 时在本地过滤, 交易所的时间范围见 SpotDealQuery.StartTime
+func (c *HTTPClient) SpotUserDealIterator(q SpotDealQuery) *paging.Iterator[*SpotUserDeal] {
+	it := paging.New(q.Page, func(page int) ([]*SpotUserDeal, bool, error) {
+		q.Page = page
+		return c.SpotUserDeals(&q)
+	})
+	it.SetTimeOf(userDealTime, true)
+	return it
+}
@@ -0,0 +1,275 @@
+package coinex
+
+import (
+	"sync"
+	"time"
+
+	"github.com/icwl/go-exchange-api/paper"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+// 现货下单相关接口, HTTPClient 和 PaperClient 均实现该接口, 策略可以在实盘和模拟盘之间切换
+type SpotTrader interface {
+	SpotBalance() ([]*SpotBalance, error)
+	SpotOrder(market, marketType, side, type_, ccy, amount, price, clientId string) (*SpotOrder, error)
+	SpotCancelOrder(market, marketType string, orderID int64) (*SpotOrder, error)
+	SpotOrderStatus(market string, orderID int64) (*SpotOrder, error)
+	SpotFinishedOrder(market, market_type, side string, page, limit int) ([]*SpotOrder, error)
+}
+
+var (
+	_ SpotTrader = (*HTTPClient)(nil)
+	_ SpotTrader = (*PaperClient)(nil)
+)
+
+// 模拟交易客户端, 订单不发送到交易所, 按实时深度撮合, 余额为虚拟余额
+// 市场信息和深度通过 HTTPClient 获取, 也可以通过 OnDepth 使用 websocket 推送的深度
+type PaperClient struct {
+	cli    *HTTPClient
+	engine *paper.Engine
+	logger *zap.Logger
+
+	lock *sync.Mutex
+	// 超过该时间未更新的深度在下单前通过 SpotDepth 重新获取
+	maxAge time.Duration
+}
+
+func NewPaperClient(cli *HTTPClient, logger *zap.Logger) *PaperClient {
+	return &PaperClient{
+		cli:    cli,
+		engine: paper.NewEngine(),
+		logger: logger,
+		lock:   new(sync.Mutex),
+		maxAge: time.Second,
+	}
+}
+
+// 模拟撮合引擎, 用于设置时钟或直接查询订单
+func (c *PaperClient) Engine() *paper.Engine {
+	return c.engine
+}
+
+// 设置深度最长有效时间, 默认 1 秒
+func (c *PaperClient) SetMaxBookAge(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.maxAge = d
+}
+
+// 设置虚拟可用余额
+func (c *PaperClient) SetBalance(ccy string, available decimal.Decimal) {
+	c.engine.SetBalance(ccy, available)
+}
+
+// 使用 websocket 推送的全量深度撮合挂单, 增量深度被忽略, 返回状态变化的订单
+func (c *PaperClient) OnDepth(dp *SpotDepth) []*SpotOrder {
+	if dp == nil || !dp.IsFull {
+		return nil
+	}
+	changed := c.engine.UpdateBook(dp.Market, dp.Depth.Asks, dp.Depth.Bids)
+	return c.spotOrders(changed)
+}
+
+// 加载市场信息
+func (c *PaperClient) market(market string) (paper.Market, error) {
+	if m, ok := c.engine.Market(market); ok {
+		return m, nil
+	}
+	markets, err := c.cli.SpotMarket(market)
+	if err != nil {
+		return paper.Market{}, errors.WithStack(err)
+	}
+	for _, item := range markets {
+		if item.Market != market {
+			continue
+		}
+		m := paper.Market{
+			Name:            item.Market,
+			Base:            item.BaseCcy,
+			Quote:           item.QuoteCcy,
+			MakerFeeRate:    item.MakerFeeRate,
+			TakerFeeRate:    item.TakerFeeRate,
+			MinAmount:       item.MinAmount,
+			AmountPrecision: item.BaseCcyPrecision,
+		}
+		c.engine.AddMarket(m)
+		return m, nil
+	}
+	return paper.Market{}, errors.WithStack(paper.ErrMarketNotFound)
+}
+
+// 深度过期时重新获取
+func (c *PaperClient) refresh(market string) error {
+	c.lock.Lock()
+	maxAge := c.maxAge
+	c.lock.Unlock()
+
+	if at, ok := c.engine.BookTime(market); ok && time.Since(at) <= maxAge {
+		return nil
+	}
+	dp, err := c.cli.SpotDepth(market, 50, "0")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	c.engine.UpdateBook(market, dp.Depth.Asks, dp.Depth.Bids)
+	return nil
+}
+
+func (c *PaperClient) SpotBalance() ([]*SpotBalance, error) {
+	balances := c.engine.Balances()
+	reply := make([]*SpotBalance, 0, len(balances))
+	for _, b := range balances {
+		reply = append(reply, &SpotBalance{Ccy: b.Ccy, Available: b.Available, Frozen: b.Frozen})
+	}
+	return reply, nil
+}
+
+// 参数同 HTTPClient.SpotOrder, 只支持现货市场
+// 市价买单的 ccy 为报价币种时 amount 为报价币种金额
+func (c *PaperClient) SpotOrder(market, marketType, side, type_, ccy, amount, price, clientId string) (*SpotOrder, error) {
+	if marketType != MarketTypeSpot {
+		return nil, errors.Wrap(paper.ErrInvalidOrder, "market type")
+	}
+	m, err := c.market(market)
+	if err != nil {
+		c.logger.Error("PaperClient.SpotOrder", zap.String("market", market), zap.Error(err))
+		return nil, errors.WithStack(err)
+	}
+	if err := c.refresh(market); err != nil {
+		c.logger.Error("PaperClient.SpotOrder", zap.String("market", market), zap.Error(err))
+		return nil, errors.WithStack(err)
+	}
+
+	o := paper.Order{
+		ClientID: clientId,
+		Market:   market,
+		Side:     side,
+		Type:     paper.TypeLimit,
+	}
+	switch type_ {
+	case OrderTypeLimit:
+	case OrderTypeMarket:
+		o.Type = paper.TypeMarket
+		o.QuoteAmount = side == "buy" && ccy == m.Quote
+	case OrderTypeMakerOnly:
+		o.TimeInForce = paper.TimeInForcePostOnly
+	case OrderTypeIOC:
+		o.TimeInForce = paper.TimeInForceIOC
+	case OrderTypeFOK:
+		o.TimeInForce = paper.TimeInForceFOK
+	default:
+		return nil, errors.Wrap(paper.ErrInvalidOrder, "type")
+	}
+	if o.Amount, err = decimal.NewFromString(amount); err != nil {
+		return nil, errors.Wrap(paper.ErrInvalidOrder, "amount")
+	}
+	if o.Type == paper.TypeLimit {
+		if o.Price, err = decimal.NewFromString(price); err != nil {
+			return nil, errors.Wrap(paper.ErrInvalidOrder, "price")
+		}
+	}
+
+	placed, err := c.engine.Place(o)
+	if err != nil {
+		c.logger.Error("PaperClient.SpotOrder", zap.String("market", market), zap.Error(err))
+		return nil, errors.WithStack(err)
+	}
+	return c.spotOrder(placed), nil
+}
+
+func (c *PaperClient) SpotCancelOrder(market, marketType string, orderID int64) (*SpotOrder, error) {
+	o, err := c.engine.Cancel(market, orderID)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return c.spotOrder(o), nil
+}
+
+func (c *PaperClient) SpotOrderStatus(market string, orderID int64) (*SpotOrder, error) {
+	o, err := c.engine.Order(market, orderID)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return c.spotOrder(o), nil
+}
+
+// 查询未完成订单, 按创建时间倒序分页
+func (c *PaperClient) SpotPendingOrder(market, marketType, side string, page, limit int) ([]*SpotOrder, error) {
+	return c.page(c.engine.Orders(market, side, false), page, limit), nil
+}
+
+// 查询已完成订单, 按创建时间倒序分页
+func (c *PaperClient) SpotFinishedOrder(market, market_type, side string, page, limit int) ([]*SpotOrder, error) {
+	return c.page(c.engine.Orders(market, side, true), page, limit), nil
+}
+
+func (c *PaperClient) page(orders []*paper.Order, page, limit int) []*SpotOrder {
+	if page <= 0 {
+		page = 1
+	}
+	if limit <= 0 {
+		limit = 10
+	}
+	start := (page - 1) * limit
+	if start > len(orders) {
+		start = len(orders)
+	}
+	end := start + limit
+	if end > len(orders) {
+		end = len(orders)
+	}
+	return c.spotOrders(orders[start:end])
+}
+
+func (c *PaperClient) spotOrders(orders []*paper.Order) []*SpotOrder {
+	list := make([]*SpotOrder, 0, len(orders))
+	for _, o := range orders {
+		list = append(list, c.spotOrder(o))
+	}
+	return list
+}
+
+func (c *PaperClient) spotOrder(o *paper.Order) *SpotOrder {
+	m, _ := c.engine.Market(o.Market)
+	type_ := OrderTypeLimit
+	switch {
+	case o.Type == paper.TypeMarket:
+		type_ = OrderTypeMarket
+	case o.TimeInForce == paper.TimeInForcePostOnly:
+		type_ = OrderTypeMakerOnly
+	case o.TimeInForce == paper.TimeInForceIOC:
+		type_ = OrderTypeIOC
+	case o.TimeInForce == paper.TimeInForceFOK:
+		type_ = OrderTypeFOK
+	}
+	so := &SpotOrder{
+		OrderID:        o.ID,
+		Market:         o.Market,
+		MarketType:     MarketTypeSpot,
+		Ccy:            m.Base,
+		Side:           o.Side,
+		Type:           type_,
+		Amount:         o.Amount,
+		Price:          o.Price,
+		UnfilledAmount: o.Left,
+		FilledAmount:   o.Filled,
+		FilledValue:    o.FilledValue,
+		ClientID:       o.ClientID,
+		MakerFeeRate:   m.MakerFeeRate,
+		TakerFeeRate:   m.TakerFeeRate,
+		CreatedAt:      o.CreatedAt,
+		UpdatedAt:      o.UpdatedAt,
+		Status:         o.Status,
+	}
+	if o.QuoteAmount {
+		so.Ccy = m.Quote
+	}
+	if o.Side == "buy" {
+		so.BaseFee = o.Fee
+	} else {
+		so.QuoteFee = o.Fee
+	}
+	return so
+}
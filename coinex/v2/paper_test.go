@@ -0,0 +1,61 @@
+package coinex
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+func TestPaperClient_SpotOrder(t *testing.T) {
+	d := decimal.RequireFromString
+
+	client := NewPaperClient(newTestHTTPClient(t), zap.NewExample())
+	client.SetMaxBookAge(time.Hour)
+	client.SetBalance("USDT", d("30000"))
+
+	// 按实时深度吃掉两档
+	taker, err := client.SpotOrder("BTCUSDT", MarketTypeSpot, "buy", OrderTypeLimit, "", "0.6", "30000.2", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if taker.Status != OrderStatusFilled || !taker.FilledValue.Equal(d("18000.07")) || !taker.BaseFee.Equal(d("0.0012")) {
+		t.Fatalf("unexpected order: %+v", taker)
+	}
+
+	maker, err := client.SpotOrder("BTCUSDT", MarketTypeSpot, "buy", OrderTypeMakerOnly, "", "0.1", "29999.5", "c-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if maker.Status != OrderStatusOpen {
+		t.Fatalf("unexpected order: %+v", maker)
+	}
+
+	// websocket 推送的深度穿过挂单价格
+	dp := &SpotDepth{Market: "BTCUSDT", IsFull: true}
+	dp.Depth.Asks = [][2]decimal.Decimal{{d("29999.4"), d("1")}}
+	changed := client.OnDepth(dp)
+	if len(changed) != 1 || changed[0].OrderID != maker.OrderID || changed[0].Status != OrderStatusFilled {
+		t.Fatalf("unexpected changed orders: %+v", changed)
+	}
+
+	balances, err := client.SpotBalance()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, b := range balances {
+		want := map[string]string{"BTC": "0.6987", "USDT": "8999.98"}[b.Ccy]
+		if !b.Available.Equal(d(want)) || !b.Frozen.IsZero() {
+			t.Fatalf("unexpected balance: %+v", b)
+		}
+	}
+
+	orders, err := client.SpotFinishedOrder("BTCUSDT", MarketTypeSpot, "", 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 2 || orders[0].OrderID != maker.OrderID || orders[0].Type != OrderTypeMakerOnly {
+		t.Fatalf("unexpected finished orders: %+v", orders)
+	}
+}
@@ -0,0 +1,64 @@
+package coinex
+
+import (
+	"time"
+
+	"github.com/icwl/go-exchange-api/wsrecord"
+	"go.uber.org/zap"
+)
+
+// 开启帧录制, 记录收到的原始 gzip 帧和解压后的消息, 需要在 Read 之前调用
+func (c *WSClient) SetRecorder(w *wsrecord.Writer) {
+	c.recorder = w
+}
+
+func (c *WSClient) record(at time.Time, frame, msg []byte) {
+	if c.recorder == nil {
+		return
+	}
+	if err := c.recorder.Write(at, frame, msg); err != nil {
+		c.logger.Error("record frame", zap.Error(err))
+	}
+}
+
+// 回放录制的帧, 与 WSClient.Read 使用相同的解压和解析逻辑
+type Replay struct {
+	cli    *WSClient
+	player *wsrecord.Player
+}
+
+func NewReplay(player *wsrecord.Player, logger *zap.Logger) *Replay {
+	return &Replay{
+		cli:    NewWSClient("", logger),
+		player: player,
+	}
+}
+
+// 开启后深度推送解析为 *SpotDepthFixed, 超出定点数精度的帧仍为 *SpotDepth
+func (r *Replay) SetFixedPoint(enable bool) {
+	r.cli.SetFixedPoint(enable)
+}
+
+// 读取下一条推送, 录制结束时返回 io.EOF
+func (r *Replay) Read() (interface{}, error) {
+	msg, _, err := r.ReadWithTime()
+	return msg, err
+}
+
+// 读取下一条推送及其录制时的接收时间, 录制结束时返回 io.EOF
+func (r *Replay) ReadWithTime() (interface{}, time.Time, error) {
+	frame, err := r.player.Next()
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	raw, err := r.cli.inflate(frame.Raw)
+	if err != nil {
+		return nil, frame.At, err
+	}
+	msg, err := r.cli.decode(raw)
+	return msg, frame.At, err
+}
+
+func (r *Replay) Close() error {
+	return r.player.Close()
+}
@@ -0,0 +1,86 @@
+package coinex
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/icwl/go-exchange-api/wsrecord"
+	"go.uber.org/zap"
+)
+
+func TestReplay(t *testing.T) {
+	depth, err := os.ReadFile("testdata/depth.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := newTestWSServer(t, func(method string, params json.RawMessage) []interface{} {
+		if method != "depth.subscribe" {
+			return nil
+		}
+		return []interface{}{
+			map[string]interface{}{"id": 1, "code": 0, "message": "OK"},
+			json.RawMessage(depth),
+			json.RawMessage(depth),
+		}
+	})
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	rec, err := wsrecord.NewWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger := zap.NewExample()
+	cli := NewWSClient("ws"+strings.TrimPrefix(srv.URL, "http"), logger)
+	cli.SetRecorder(rec)
+	if err := cli.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+
+	if err := cli.SubDepth([]string{"BTCUSDT"}, 50, "0", true); err != nil {
+		t.Fatal(err)
+	}
+	live := make([]*SpotDepth, 0)
+	for len(live) < 2 {
+		msg, err := cli.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if dp, ok := msg.(*SpotDepth); ok {
+			live = append(live, dp)
+		}
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	replay := NewReplay(wsrecord.NewPlayer(wsrecord.NewReader(&buf), 0), logger)
+	replayed := make([]*SpotDepth, 0)
+	for {
+		msg, err := replay.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if dp, ok := msg.(*SpotDepth); ok {
+			replayed = append(replayed, dp)
+		}
+	}
+
+	if len(replayed) != len(live) {
+		t.Fatalf("replayed %d, want %d", len(replayed), len(live))
+	}
+	for i := range live {
+		if replayed[i].Depth.Checksum != live[i].Depth.Checksum || len(replayed[i].Depth.Asks) != len(live[i].Depth.Asks) {
+			t.Fatalf("frame %d mismatch", i)
+		}
+	}
+}
@@ -0,0 +1,423 @@
+package coinex
+
+import (
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+func (s Side) Valid() bool {
+	return s == SideBuy || s == SideSell
+}
+
+func (t OrderType) Valid() bool {
+	switch t {
+	case OrderTypeLimit, OrderTypeMarket, OrderTypeMakerOnly, OrderTypeIOC, OrderTypeFOK:
+		return true
+	}
+	return false
+}
+
+func (m STPMode) Valid() bool {
+	switch m {
+	case STPModeCancelTaker, STPModeCancelMaker, STPModeCancelBoth:
+		return true
+	}
+	return false
+}
+
+// 下单参数, 零值字段不发送, 使用交易所默认值
+type SpotOrderRequest struct {
+	Market string
+	// 默认 SPOT
+	MarketType string
+	Side       Side
+	Type       OrderType
+	// 市价单的数量币种, 为报价币种时 Amount 为报价币种金额, 为空时为交易币种
+	Ccy    string
+	Amount decimal.Decimal
+	// 市价单不发送
+	Price decimal.Decimal
+	// 客户端 ID, 1 到 32 位字母, 数字, 下划线或中划线
+	ClientID string
+	// 隐藏委托, 不在深度中显示
+	IsHide bool
+	// 自成交保护模式
+	STPMode STPMode
+}
+
+// 检查参数格式, 不涉及市场规则和余额, 规则校验见 Validator
+func (r *SpotOrderRequest) Validate() error {
+	var list []Violation
+	add := func(field, reason, message string) {
+		list = append(list, Violation{Field: field, Reason: reason, Message: message})
+	}
+
+	if r.Market == "" {
+		add("market", ReasonInvalidValue, "market is required")
+	}
+	switch r.MarketType {
+	case "", MarketTypeSpot, MarketTypeMargin:
+	default:
+		add("market_type", ReasonInvalidValue, "market_type must be SPOT or MARGIN")
+	}
+	if !r.Side.Valid() {
+		add("side", ReasonInvalidValue, "side must be buy or sell")
+	}
+	if !r.Type.Valid() {
+		add("type", ReasonInvalidValue, "unknown order type "+string(r.Type))
+	}
+	if !r.Amount.IsPositive() {
+		add("amount", ReasonInvalidValue, "amount must be positive")
+	}
+	if r.Type != OrderTypeMarket && !r.Price.IsPositive() {
+		add("price", ReasonInvalidValue, "price must be positive")
+	}
+	if r.ClientID != "" {
+		if msg := checkClientID(r.ClientID); msg != "" {
+			add("client_id", ReasonInvalidClientID, msg)
+		}
+	}
+	if r.STPMode != "" && !r.STPMode.Valid() {
+		add("stp_mode", ReasonInvalidValue, "unknown stp_mode "+string(r.STPMode))
+	}
+
+	if len(list) > 0 {
+		return errors.WithStack(&ValidationError{Violations: list})
+	}
+	return nil
+}
+
+// 转换为订单, 用于 Validator 和 MarketRules.Normalize
+func (r *SpotOrderRequest) order() *SpotOrder {
+	return &SpotOrder{
+		Market:     r.Market,
+		MarketType: r.marketType(),
+		Ccy:        r.Ccy,
+		Side:       string(r.Side),
+		Type:       string(r.Type),
+		Amount:     r.Amount,
+		Price:      r.Price,
+		ClientID:   r.ClientID,
+	}
+}
+
+func (r *SpotOrderRequest) marketType() string {
+	if r.MarketType == "" {
+		return MarketTypeSpot
+	}
+	return r.MarketType
+}
+
+func (r *SpotOrderRequest) body() map[string]interface{} {
+	body := make(map[string]interface{})
+	body["market"] = r.Market
+	body["market_type"] = r.marketType()
+	body["side"] = r.Side
+	body["type"] = r.Type
+	if r.Ccy != "" {
+		body["ccy"] = r.Ccy
+	}
+	body["amount"] = r.Amount.String()
+	if r.Type != OrderTypeMarket {
+		body["price"] = r.Price.String()
+	}
+	if r.ClientID != "" {
+		body["client_id"] = r.ClientID
+	}
+	if r.IsHide {
+		body["is_hide"] = true
+	}
+	if r.STPMode != "" {
+		body["stp_mode"] = r.STPMode
+	}
+	return body
+}
+
+// 按请求所在市场的规则校验, 见 Validate
+func (v *Validator) ValidateRequest(r *SpotOrderRequest) error {
+	return v.Validate(r.order())
+}
+
+// 按请求所在市场的精度规整价格和数量, 见 SpotMarket.Normalize
+func (r *MarketRules) NormalizeRequest(req *SpotOrderRequest) error {
+	o := req.order()
+	if err := r.Normalize(o); err != nil {
+		return errors.WithStack(err)
+	}
+	req.Amount, req.Price = o.Amount, o.Price
+	return nil
+}
+
+// 改单参数, Amount 和 Price 至少设置一个, 零值表示不修改
+type SpotModifyOrderRequest struct {
+	Market string
+	// 默认 SPOT
+	MarketType string
+	OrderID    int64
+	// 新的委托数量
+	Amount decimal.Decimal
+	// 新的委托价格
+	Price decimal.Decimal
+}
+
+// 检查参数格式, 不涉及市场规则
+func (r *SpotModifyOrderRequest) Validate() error {
+	var list []Violation
+	add := func(field, reason, message string) {
+		list = append(list, Violation{Field: field, Reason: reason, Message: message})
+	}
+
+	if r.Market == "" {
+		add("market", ReasonInvalidValue, "market is required")
+	}
+	switch r.MarketType {
+	case "", MarketTypeSpot, MarketTypeMargin:
+	default:
+		add("market_type", ReasonInvalidValue, "market_type must be SPOT or MARGIN")
+	}
+	if r.OrderID <= 0 {
+		add("order_id", ReasonInvalidValue, "order_id is required")
+	}
+	if r.Amount.IsZero() && r.Price.IsZero() {
+		add("amount", ReasonInvalidValue, "amount or price is required")
+	}
+	if r.Amount.IsNegative() {
+		add("amount", ReasonInvalidValue, "amount must be positive")
+	}
+	if r.Price.IsNegative() {
+		add("price", ReasonInvalidValue, "price must be positive")
+	}
+
+	if len(list) > 0 {
+		return errors.WithStack(&ValidationError{Violations: list})
+	}
+	return nil
+}
+
+func (r *SpotModifyOrderRequest) body() map[string]interface{} {
+	body := make(map[string]interface{})
+	body["market"] = r.Market
+	body["market_type"] = MarketTypeSpot
+	if r.MarketType != "" {
+		body["market_type"] = r.MarketType
+	}
+	body["order_id"] = r.OrderID
+	if !r.Amount.IsZero() {
+		body["amount"] = r.Amount.String()
+	}
+	if !r.Price.IsZero() {
+		body["price"] = r.Price.String()
+	}
+	return body
+}
+
+// 计划委托参数, 最新成交价达到 TriggerPrice 后按其余参数下单
+type SpotStopOrderRequest struct {
+	Market string
+	// 默认 SPOT
+	MarketType string
+	Side       Side
+	Type       OrderType
+	// 市价单的数量币种, 见 SpotOrderRequest.Ccy
+	Ccy    string
+	Amount decimal.Decimal
+	// 触发价格
+	TriggerPrice decimal.Decimal
+	// 市价单不发送
+	Price decimal.Decimal
+	// 客户端 ID, 1 到 32 位字母, 数字, 下划线或中划线
+	ClientID string
+	// 隐藏委托, 不在深度中显示
+	IsHide bool
+}
+
+func (r *SpotStopOrderRequest) spot() *SpotOrderRequest {
+	return &SpotOrderRequest{
+		Market:     r.Market,
+		MarketType: r.MarketType,
+		Side:       r.Side,
+		Type:       r.Type,
+		Ccy:        r.Ccy,
+		Amount:     r.Amount,
+		Price:      r.Price,
+		ClientID:   r.ClientID,
+		IsHide:     r.IsHide,
+	}
+}
+
+// 检查参数格式, 不涉及市场规则和余额
+func (r *SpotStopOrderRequest) Validate() error {
+	var list []Violation
+	var e *ValidationError
+	if err := r.spot().Validate(); errors.As(err, &e) {
+		list = append(list, e.Violations...)
+	}
+	if !r.TriggerPrice.IsPositive() {
+		list = append(list, Violation{Field: "trigger_price", Reason: ReasonInvalidValue, Message: "trigger_price must be positive"})
+	}
+
+	if len(list) > 0 {
+		return errors.WithStack(&ValidationError{Violations: list})
+	}
+	return nil
+}
+
+func (r *SpotStopOrderRequest) body() map[string]interface{} {
+	body := r.spot().body()
+	body["trigger_price"] = r.TriggerPrice.String()
+	return body
+}
+
+// 修改计划委托参数, Amount, Price 和 TriggerPrice 至少设置一个, 零值表示不修改
+type SpotModifyStopOrderRequest struct {
+	Market string
+	// 默认 SPOT
+	MarketType string
+	StopID     int64
+	// 新的委托数量
+	Amount decimal.Decimal
+	// 新的委托价格
+	Price decimal.Decimal
+	// 新的触发价格
+	TriggerPrice decimal.Decimal
+}
+
+// 检查参数格式, 不涉及市场规则
+func (r *SpotModifyStopOrderRequest) Validate() error {
+	var list []Violation
+	add := func(field, reason, message string) {
+		list = append(list, Violation{Field: field, Reason: reason, Message: message})
+	}
+
+	if r.Market == "" {
+		add("market", ReasonInvalidValue, "market is required")
+	}
+	switch r.MarketType {
+	case "", MarketTypeSpot, MarketTypeMargin:
+	default:
+		add("market_type", ReasonInvalidValue, "market_type must be SPOT or MARGIN")
+	}
+	if r.StopID <= 0 {
+		add("stop_id", ReasonInvalidValue, "stop_id is required")
+	}
+	if r.Amount.IsZero() && r.Price.IsZero() && r.TriggerPrice.IsZero() {
+		add("amount", ReasonInvalidValue, "amount, price or trigger_price is required")
+	}
+	if r.Amount.IsNegative() {
+		add("amount", ReasonInvalidValue, "amount must be positive")
+	}
+	if r.Price.IsNegative() {
+		add("price", ReasonInvalidValue, "price must be positive")
+	}
+	if r.TriggerPrice.IsNegative() {
+		add("trigger_price", ReasonInvalidValue, "trigger_price must be positive")
+	}
+
+	if len(list) > 0 {
+		return errors.WithStack(&ValidationError{Violations: list})
+	}
+	return nil
+}
+
+func (r *SpotModifyStopOrderRequest) body() map[string]interface{} {
+	body := make(map[string]interface{})
+	body["market"] = r.Market
+	body["market_type"] = MarketTypeSpot
+	if r.MarketType != "" {
+		body["market_type"] = r.MarketType
+	}
+	body["stop_id"] = r.StopID
+	if !r.Amount.IsZero() {
+		body["amount"] = r.Amount.String()
+	}
+	if !r.Price.IsZero() {
+		body["price"] = r.Price.String()
+	}
+	if !r.TriggerPrice.IsZero() {
+		body["trigger_price"] = r.TriggerPrice.String()
+	}
+	return body
+}
+
+// 挂单和计划委托的查询条件, 零值字段不发送
+type SpotOrderQuery struct {
+	Market string
+	// 默认 SPOT
+	MarketType string
+	Side       Side
+	ClientID   string
+	// 页码, 从 1 开始
+	Page  int
+	Limit int
+}
+
+func (q *SpotOrderQuery) values() url.Values {
+	query := url.Values{}
+	if q.Market != "" {
+		query.Add("market", q.Market)
+	}
+	if q.MarketType != "" {
+		query.Add("market_type", q.MarketType)
+	} else {
+		query.Add("market_type", MarketTypeSpot)
+	}
+	if q.Side != "" {
+		query.Add("side", string(q.Side))
+	}
+	if q.ClientID != "" {
+		query.Add("client_id", q.ClientID)
+	}
+	if q.Page != 0 {
+		query.Add("page", strconv.Itoa(q.Page))
+	}
+	if q.Limit != 0 {
+		query.Add("limit", strconv.Itoa(q.Limit))
+	}
+	return query
+}
+
+// 个人成交记录查询条件, 零值字段不发送
+type SpotDealQuery struct {
+	Market string
+	// 默认 SPOT
+	MarketType string
+	Side       Side
+	// 交易所不支持按客户端 ID 查询, 在本地过滤
+	ClientID string
+	// 按成交时间过滤, 精确到毫秒
+	StartTime time.Time
+	EndTime   time.Time
+	// 页码, 从 1 开始
+	Page  int
+	Limit int
+}
+
+func (q *SpotDealQuery) values() url.Values {
+	query := url.Values{}
+	query.Add("market", q.Market)
+	if q.MarketType != "" {
+		query.Add("market_type", q.MarketType)
+	} else {
+		query.Add("market_type", MarketTypeSpot)
+	}
+	if q.Side != "" {
+		query.Add("side", string(q.Side))
+	}
+	if !q.StartTime.IsZero() {
+		query.Add("start_time", strconv.FormatInt(q.StartTime.UnixMilli(), 10))
+	}
+	if !q.EndTime.IsZero() {
+		query.Add("end_time", strconv.FormatInt(q.EndTime.UnixMilli(), 10))
+	}
+	if q.Page != 0 {
+		query.Add("page", strconv.Itoa(q.Page))
+	}
+	if q.Limit != 0 {
+		query.Add("limit", strconv.Itoa(q.Limit))
+	}
+	return query
+}
@@ -0,0 +1,75 @@
+package coinex
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+func TestSpotOrderRequest(t *testing.T) {
+	d := decimal.RequireFromString
+
+	req := &SpotOrderRequest{
+		Market: "BTCUSDT",
+		Side:   SideBuy,
+		Type:   OrderTypeMarket,
+		Ccy:    "USDT",
+		Amount: d("100"),
+	}
+	if err := req.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := json.Marshal(req.body())
+	want := `{"amount":"100","ccy":"USDT","market":"BTCUSDT","market_type":"SPOT","side":"buy","type":"market"}`
+	if string(b) != want {
+		t.Fatalf("got %s, want %s", b, want)
+	}
+
+	bad := &SpotOrderRequest{
+		Market:   "BTCUSDT",
+		Side:     "long",
+		Type:     "gtc",
+		Amount:   d("1"),
+		ClientID: "bad id",
+		STPMode:  "none",
+	}
+	var e *ValidationError
+	if err := bad.Validate(); !errors.As(err, &e) || len(e.Violations) != 5 {
+		t.Fatalf("got %v, want 5 violations", err)
+	}
+}
+
+func TestSpotStopOrderRequest(t *testing.T) {
+	d := decimal.RequireFromString
+
+	req := &SpotStopOrderRequest{
+		Market:       "BTCUSDT",
+		Side:         SideSell,
+		Type:         OrderTypeLimit,
+		Amount:       d("0.001"),
+		TriggerPrice: d("28000"),
+		Price:        d("27900"),
+		ClientID:     "stop-1",
+	}
+	if err := req.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := json.Marshal(req.body())
+	want := `{"amount":"0.001","client_id":"stop-1","market":"BTCUSDT","market_type":"SPOT","price":"27900","side":"sell","trigger_price":"28000","type":"limit"}`
+	if string(b) != want {
+		t.Fatalf("got %s, want %s", b, want)
+	}
+
+	bad := &SpotStopOrderRequest{
+		Market: "BTCUSDT",
+		Side:   SideSell,
+		Type:   OrderTypeLimit,
+		Amount: d("0.001"),
+	}
+	var e *ValidationError
+	if err := bad.Validate(); !errors.As(err, &e) || len(e.Violations) != 2 {
+		t.Fatalf("got %v, want 2 violations", err)
+	}
+}
@@ -0,0 +1,148 @@
+package coinex
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+var ErrMarketNotFound = errors.New("coinex: market not found")
+
+// 价格最小变动单位
+func (m *SpotMarket) Tick() decimal.Decimal {
+	return decimal.New(1, -m.QuoteCcyPrecision)
+}
+
+// 数量最小变动单位
+func (m *SpotMarket) Lot() decimal.Decimal {
+	return decimal.New(1, -m.BaseCcyPrecision)
+}
+
+func (m *SpotMarket) RoundPrice(price decimal.Decimal) decimal.Decimal {
+	return price.Round(m.QuoteCcyPrecision)
+}
+
+func (m *SpotMarket) FloorPrice(price decimal.Decimal) decimal.Decimal {
+	return price.RoundFloor(m.QuoteCcyPrecision)
+}
+
+func (m *SpotMarket) CeilPrice(price decimal.Decimal) decimal.Decimal {
+	return price.RoundCeil(m.QuoteCcyPrecision)
+}
+
+func (m *SpotMarket) RoundAmount(amount decimal.Decimal) decimal.Decimal {
+	return amount.Round(m.BaseCcyPrecision)
+}
+
+func (m *SpotMarket) FloorAmount(amount decimal.Decimal) decimal.Decimal {
+	return amount.RoundFloor(m.BaseCcyPrecision)
+}
+
+func (m *SpotMarket) CeilAmount(amount decimal.Decimal) decimal.Decimal {
+	return amount.RoundCeil(m.BaseCcyPrecision)
+}
+
+// 按精度规整订单价格和数量, 结果不会比原订单更差:
+// 数量向下取整, 买单价格向下取整, 卖单价格向上取整
+// 市价买单按报价币种下单时数量为报价币种金额, 按报价币种精度向下取整
+func (m *SpotMarket) Normalize(o *SpotOrder) {
+	if o.Type == OrderTypeMarket && o.Ccy != "" && o.Ccy == m.QuoteCcy {
+		o.Amount = o.Amount.RoundFloor(m.QuoteCcyPrecision)
+	} else {
+		o.Amount = m.FloorAmount(o.Amount)
+	}
+	if o.Type == OrderTypeMarket {
+		return
+	}
+	if o.Side == "sell" {
+		o.Price = m.CeilPrice(o.Price)
+	} else {
+		o.Price = m.FloorPrice(o.Price)
+	}
+}
+
+// 市场规则缓存, 首次使用时通过 SpotMarket 加载全部市场, 超过 ttl 后重新加载
+// 加载失败时继续使用上一次的结果
+type MarketRules struct {
+	cli    *HTTPClient
+	logger *zap.Logger
+
+	lock     *sync.Mutex
+	ttl      time.Duration
+	loadedAt time.Time
+	markets  map[string]*SpotMarket
+}
+
+// ttl 为 0 时只加载一次, 之后需要调用 Refresh 手动刷新
+func NewMarketRules(cli *HTTPClient, ttl time.Duration, logger *zap.Logger) *MarketRules {
+	return &MarketRules{
+		cli:    cli,
+		logger: logger,
+		lock:   new(sync.Mutex),
+		ttl:    ttl,
+	}
+}
+
+// 重新加载全部市场
+func (r *MarketRules) Refresh() error {
+	markets, err := r.cli.SpotMarket("")
+	if err != nil {
+		r.logger.Error("MarketRules.Refresh", zap.Error(err))
+		return errors.WithStack(err)
+	}
+	m := make(map[string]*SpotMarket, len(markets))
+	for _, item := range markets {
+		m[item.Market] = item
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.markets = m
+	r.loadedAt = time.Now()
+	return nil
+}
+
+func (r *MarketRules) expired() bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.markets == nil {
+		return true
+	}
+	return r.ttl > 0 && time.Since(r.loadedAt) > r.ttl
+}
+
+// 查询市场规则, 返回值为缓存的副本
+func (r *MarketRules) Market(market string) (*SpotMarket, error) {
+	if r.expired() {
+		if err := r.Refresh(); err != nil {
+			r.lock.Lock()
+			loaded := r.markets != nil
+			r.lock.Unlock()
+			if !loaded {
+				return nil, errors.WithStack(err)
+			}
+		}
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	m, ok := r.markets[market]
+	if !ok {
+		return nil, errors.Wrap(ErrMarketNotFound, market)
+	}
+	cp := *m
+	return &cp, nil
+}
+
+// 按订单所在市场的精度规整价格和数量, 见 SpotMarket.Normalize
+func (r *MarketRules) Normalize(o *SpotOrder) error {
+	m, err := r.Market(o.Market)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	m.Normalize(o)
+	return nil
+}
@@ -0,0 +1,46 @@
+package coinex
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+func TestMarketRules(t *testing.T) {
+	d := decimal.RequireFromString
+	rules := NewMarketRules(newTestHTTPClient(t), 0, zap.NewExample())
+
+	m, err := rules.Market("DOGEUSDT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.Tick().Equal(d("0.000001")) || !m.Lot().Equal(d("0.01")) || !m.CeilAmount(d("10.001")).Equal(d("10.01")) {
+		t.Fatalf("unexpected market: %+v", m)
+	}
+
+	// 只加载一次, 后续查询使用缓存
+	cases := []struct {
+		order  SpotOrder
+		amount string
+		price  string
+	}{
+		{SpotOrder{Market: "BTCUSDT", Side: "buy", Type: OrderTypeLimit, Amount: d("0.123456789"), Price: d("30000.129")}, "0.12345678", "30000.12"},
+		{SpotOrder{Market: "BTCUSDT", Side: "sell", Type: OrderTypeLimit, Amount: d("0.123456789"), Price: d("30000.121")}, "0.12345678", "30000.13"},
+		{SpotOrder{Market: "DOGEUSDT", Side: "buy", Type: OrderTypeMarket, Ccy: "USDT", Amount: d("12.3456789")}, "12.345678", "0"},
+	}
+	for _, c := range cases {
+		o := c.order
+		if err := rules.Normalize(&o); err != nil {
+			t.Fatal(err)
+		}
+		if !o.Amount.Equal(d(c.amount)) || !o.Price.Equal(d(c.price)) {
+			t.Fatalf("got %s@%s, want %s@%s", o.Amount, o.Price, c.amount, c.price)
+		}
+	}
+
+	if _, err := rules.Market("ETHUSDT"); !errors.Is(err, ErrMarketNotFound) {
+		t.Fatalf("got %v, want market not found", err)
+	}
+}
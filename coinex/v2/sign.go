@@ -1,6 +1,7 @@
 package coinex
 
 import (
+	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
 	"strings"
@@ -12,3 +13,10 @@ func Sign(method, path, body, timestamp, secret string) string {
 	hash := sha256.Sum256([]byte(preparedStr))
 	return strings.ToLower(hex.EncodeToString(hash[:]))
 }
+
+// websocket 认证签名, hmac_sha256(secret, timestamp)
+func WSSign(timestamp, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	return strings.ToLower(hex.EncodeToString(mac.Sum(nil)))
+}
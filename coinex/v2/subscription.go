@@ -0,0 +1,286 @@
+package coinex
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// 主题订阅了全部市场时不能只取消其中一个市场, 需要取消全部市场后重新订阅
+var ErrPartialUnsubscribe = errors.New("coinex: cannot unsubscribe a single market from an all-market subscription")
+
+const (
+	TopicDepth = "depth"
+	TopicDeals = "deals"
+	TopicState = "state"
+	TopicBBO   = "bbo"
+	TopicIndex = "index"
+	// 订单推送, 需要先 Login
+	TopicOrder = "order"
+)
+
+// 订阅
+type Subscription struct {
+	// 订阅主题 [depth / deals / state / bbo / index / order]
+	Topic string
+	// 市场名称, 空字符串表示全部市场
+	Market string
+	// 以下为 depth 订阅参数
+	// 深度数据条数
+	Limit int
+	// 合并粒度
+	Interval string
+	// true 为全量推送, false 为增量推送
+	IsFull bool
+}
+
+func (s *Subscription) Key() string {
+	return s.Topic + ":" + s.Market
+}
+
+func (s *Subscription) params() []interface{} {
+	return []interface{}{s.Market, s.Limit, s.Interval, s.IsFull}
+}
+
+func (s *Subscription) equal(o *Subscription) bool {
+	return *s == *o
+}
+
+// 对比当前订阅与目标订阅, 返回需要新增和取消的订阅
+// 参数变化的订阅直接重新订阅, 不需要先取消, 当前订阅列表更新为新的参数
+func Diff(current, desired []*Subscription) (sub, unsub []*Subscription) {
+	cur := make(map[string]*Subscription, len(current))
+	for _, s := range current {
+		cur[s.Key()] = s
+	}
+	want := make(map[string]*Subscription, len(desired))
+	for _, s := range desired {
+		want[s.Key()] = s
+	}
+
+	for _, s := range desired {
+		if old, ok := cur[s.Key()]; !ok || !old.equal(s) {
+			sub = append(sub, s)
+		}
+	}
+	for _, s := range current {
+		if _, ok := want[s.Key()]; !ok {
+			unsub = append(unsub, s)
+		}
+	}
+	return sub, unsub
+}
+
+// 按主题分组, 每个主题只发送一条消息
+func groupByTopic(subs []*Subscription) ([]string, map[string][]*Subscription) {
+	topics := make([]string, 0)
+	group := make(map[string][]*Subscription)
+	for _, s := range subs {
+		if _, ok := group[s.Topic]; !ok {
+			topics = append(topics, s.Topic)
+		}
+		group[s.Topic] = append(group[s.Topic], s)
+	}
+	return topics, group
+}
+
+func marketListOf(topic string, subs []*Subscription) []interface{} {
+	list := make([]interface{}, 0, len(subs))
+	for _, s := range subs {
+		// 空市场表示全部市场, 服务端要求传空数组
+		if s.Market == "" {
+			return []interface{}{}
+		}
+		if topic == TopicDepth {
+			list = append(list, s.params())
+		} else {
+			list = append(list, s.Market)
+		}
+	}
+	return list
+}
+
+// 订阅并记录到当前订阅列表
+func (c *WSClient) Subscribe(subs []*Subscription) error {
+	topics, group := groupByTopic(subs)
+	for _, topic := range topics {
+		method := topic + ".subscribe"
+		params := map[string]interface{}{"market_list": marketListOf(topic, group[topic])}
+		if err := c.SendMethod(method, params); err != nil {
+			return errors.WithStack(err)
+		}
+
+		c.slock.Lock()
+		for _, s := range group[topic] {
+			sub := *s
+			c.subs[s.Key()] = &sub
+		}
+		c.slock.Unlock()
+	}
+	return nil
+}
+
+// 取消订阅并从当前订阅列表移除
+// 主题当前订阅了全部市场时, 只取消部分市场返回 ErrPartialUnsubscribe, 不发送任何请求
+func (c *WSClient) Unsubscribe(subs []*Subscription) error {
+	topics, group := groupByTopic(subs)
+	if err := c.checkUnsubscribe(topics, group); err != nil {
+		return err
+	}
+	for _, topic := range topics {
+		method := topic + ".unsubscribe"
+		list := make([]string, 0, len(group[topic]))
+		for _, s := range group[topic] {
+			if s.Market == "" {
+				list = []string{}
+				break
+			}
+			list = append(list, s.Market)
+		}
+		params := map[string]interface{}{"market_list": list}
+		if err := c.SendMethod(method, params); err != nil {
+			return errors.WithStack(err)
+		}
+
+		c.slock.Lock()
+		for _, s := range group[topic] {
+			if s.Market != "" {
+				delete(c.subs, s.Key())
+				continue
+			}
+			// 取消全部市场
+			for key, cur := range c.subs {
+				if cur.Topic == topic {
+					delete(c.subs, key)
+				}
+			}
+		}
+		c.slock.Unlock()
+	}
+	return nil
+}
+
+func (c *WSClient) checkUnsubscribe(topics []string, group map[string][]*Subscription) error {
+	c.slock.Lock()
+	defer c.slock.Unlock()
+	for _, topic := range topics {
+		if _, ok := c.subs[(&Subscription{Topic: topic}).Key()]; !ok {
+			continue
+		}
+		all := false
+		for _, s := range group[topic] {
+			if s.Market == "" {
+				all = true
+				break
+			}
+		}
+		if !all {
+			return errors.Wrap(ErrPartialUnsubscribe, topic)
+		}
+	}
+	return nil
+}
+
+// 当前订阅列表, 按 Key 排序
+func (c *WSClient) Subscriptions() []*Subscription {
+	c.slock.Lock()
+	defer c.slock.Unlock()
+
+	subs := make([]*Subscription, 0, len(c.subs))
+	for _, s := range c.subs {
+		sub := *s
+		subs = append(subs, &sub)
+	}
+	sort.Slice(subs, func(i, j int) bool {
+		return subs[i].Key() < subs[j].Key()
+	})
+	return subs
+}
+
+func (c *WSClient) IsSubscribed(topic, market string) bool {
+	c.slock.Lock()
+	defer c.slock.Unlock()
+
+	_, ok := c.subs[(&Subscription{Topic: topic, Market: market}).Key()]
+	return ok
+}
+
+// 将当前订阅同步为目标订阅, 只发送差异部分
+func (c *WSClient) Sync(desired []*Subscription) error {
+	return c.apply(Diff(c.Subscriptions(), desired))
+}
+
+// 将指定主题的订阅市场同步为 markets, 其他主题不受影响
+// - topic 订阅主题 [deals / state / bbo / index], depth 使用 SyncDepth
+// - markets 目标市场列表, 空列表表示取消该主题全部订阅
+func (c *WSClient) SyncMarkets(topic string, markets []string) error {
+	desired := make([]*Subscription, 0, len(markets))
+	for _, market := range markets {
+		desired = append(desired, &Subscription{Topic: topic, Market: market})
+	}
+	return c.apply(Diff(c.topicSubscriptions(topic), desired))
+}
+
+// 将市场深度订阅同步为 markets
+func (c *WSClient) SyncDepth(markets []string, limit int, interval string, isFull bool) error {
+	desired := depthSubscriptions(markets, limit, interval, isFull)
+	return c.apply(Diff(c.topicSubscriptions(TopicDepth), desired))
+}
+
+func (c *WSClient) topicSubscriptions(topic string) []*Subscription {
+	subs := make([]*Subscription, 0)
+	for _, s := range c.Subscriptions() {
+		if s.Topic == topic {
+			subs = append(subs, s)
+		}
+	}
+	return subs
+}
+
+func (c *WSClient) apply(sub, unsub []*Subscription) error {
+	if len(unsub) > 0 {
+		if err := c.Unsubscribe(unsub); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	if len(sub) > 0 {
+		if err := c.Subscribe(sub); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+func depthSubscriptions(markets []string, limit int, interval string, isFull bool) []*Subscription {
+	subs := make([]*Subscription, 0, len(markets))
+	for _, market := range markets {
+		subs = append(subs, &Subscription{
+			Topic:    TopicDepth,
+			Market:   market,
+			Limit:    limit,
+			Interval: interval,
+			IsFull:   isFull,
+		})
+	}
+	return subs
+}
+
+// 与 depthSubscriptions 相同, 空列表为全部市场的订阅, 用于 SubDepth
+// Sync 中的空列表表示取消全部订阅, 不能使用该函数
+func subDepthSubscriptions(markets []string, limit int, interval string, isFull bool) []*Subscription {
+	if len(markets) == 0 {
+		return []*Subscription{{Topic: TopicDepth, Limit: limit, Interval: interval, IsFull: isFull}}
+	}
+	return depthSubscriptions(markets, limit, interval, isFull)
+}
+
+func subscriptions(topic string, markets []string) []*Subscription {
+	if len(markets) == 0 {
+		return []*Subscription{{Topic: topic}}
+	}
+	subs := make([]*Subscription, 0, len(markets))
+	for _, market := range markets {
+		subs = append(subs, &Subscription{Topic: topic, Market: market})
+	}
+	return subs
+}
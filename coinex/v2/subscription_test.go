@@ -0,0 +1,166 @@
+package coinex
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+func TestDiff(t *testing.T) {
+	current := []*Subscription{
+		{Topic: TopicDepth, Market: "BTCUSDT", Limit: 10, Interval: "0", IsFull: true},
+		{Topic: TopicDepth, Market: "ETHUSDT", Limit: 10, Interval: "0", IsFull: true},
+		{Topic: TopicBBO, Market: "BTCUSDT"},
+	}
+	desired := []*Subscription{
+		{Topic: TopicDepth, Market: "BTCUSDT", Limit: 10, Interval: "0", IsFull: true},
+		{Topic: TopicDepth, Market: "ETHUSDT", Limit: 20, Interval: "0", IsFull: true},
+		{Topic: TopicBBO, Market: "ETHUSDT"},
+	}
+
+	sub, unsub := Diff(current, desired)
+	if len(sub) != 2 || sub[0].Key() != "depth:ETHUSDT" || sub[0].Limit != 20 || sub[1].Key() != "bbo:ETHUSDT" {
+		t.Fatalf("unexpected sub: %+v", sub)
+	}
+	if len(unsub) != 1 || unsub[0].Key() != "bbo:BTCUSDT" {
+		t.Fatalf("unexpected unsub: %+v", unsub)
+	}
+}
+
+func TestWSClient_SyncMarkets(t *testing.T) {
+	sent := make(chan string, 16)
+	srv := newTestWSServer(t, func(method string, params json.RawMessage) []interface{} {
+		sent <- method + " " + string(params)
+		return nil
+	})
+	defer srv.Close()
+
+	logger := zap.NewExample()
+	cli := NewWSClient("ws"+strings.TrimPrefix(srv.URL, "http"), logger)
+	if err := cli.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+
+	if err := cli.SubBBO([]string{"BTCUSDT", "ETHUSDT"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cli.SubDepth([]string{"BTCUSDT"}, 10, "0", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := cli.SyncMarkets(TopicBBO, []string{"ETHUSDT", "SOLUSDT"}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		`bbo.subscribe {"market_list":["BTCUSDT","ETHUSDT"]}`,
+		`depth.subscribe {"market_list":[["BTCUSDT",10,"0",true]]}`,
+		`bbo.unsubscribe {"market_list":["BTCUSDT"]}`,
+		`bbo.subscribe {"market_list":["SOLUSDT"]}`,
+	}
+	for _, w := range want {
+		select {
+		case got := <-sent:
+			if got != w {
+				t.Fatalf("got %s, want %s", got, w)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timeout waiting for %s", w)
+		}
+	}
+
+	keys := make([]string, 0)
+	for _, s := range cli.Subscriptions() {
+		keys = append(keys, s.Key())
+	}
+	if strings.Join(keys, ",") != "bbo:ETHUSDT,bbo:SOLUSDT,depth:BTCUSDT" {
+		t.Fatalf("unexpected subscriptions: %v", keys)
+	}
+
+	// 空列表订阅全部市场, 与之前的行为一致
+	if err := cli.SubDepth(nil, 5, "0", false); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case got := <-sent:
+		if w := `depth.subscribe {"market_list":[]}`; got != w {
+			t.Fatalf("got %s, want %s", got, w)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for depth.subscribe")
+	}
+	if !cli.IsSubscribed(TopicDepth, "") {
+		t.Fatal("all-market depth subscription not recorded")
+	}
+}
+
+func TestWSClient_Unsubscribe(t *testing.T) {
+	sent := make(chan string, 16)
+	srv := newTestWSServer(t, func(method string, params json.RawMessage) []interface{} {
+		sent <- method + " " + string(params)
+		return nil
+	})
+	defer srv.Close()
+
+	cli := NewWSClient("ws"+strings.TrimPrefix(srv.URL, "http"), zap.NewExample())
+	if err := cli.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+
+	expect := func(w string) {
+		t.Helper()
+		select {
+		case got := <-sent:
+			if got != w {
+				t.Fatalf("got %s, want %s", got, w)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timeout waiting for %s", w)
+		}
+	}
+
+	// 参数变化时只重新订阅, 不先取消
+	if err := cli.SyncDepth([]string{"BTCUSDT"}, 10, "0", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := cli.SyncDepth([]string{"BTCUSDT"}, 20, "0", true); err != nil {
+		t.Fatal(err)
+	}
+	expect(`depth.subscribe {"market_list":[["BTCUSDT",10,"0",true]]}`)
+	expect(`depth.subscribe {"market_list":[["BTCUSDT",20,"0",true]]}`)
+	if subs := cli.Subscriptions(); len(subs) != 1 || subs[0].Limit != 20 {
+		t.Fatalf("unexpected subscriptions: %+v", subs)
+	}
+
+	// 订阅全部市场后不能只取消一个市场
+	if err := cli.SubBBO(nil); err != nil {
+		t.Fatal(err)
+	}
+	expect(`bbo.subscribe {"market_list":[]}`)
+	err := cli.Unsubscribe([]*Subscription{{Topic: TopicBBO, Market: "BTCUSDT"}})
+	if !errors.Is(err, ErrPartialUnsubscribe) {
+		t.Fatalf("got %v, want ErrPartialUnsubscribe", err)
+	}
+	if !cli.IsSubscribed(TopicBBO, "") {
+		t.Fatal("all-market subscription removed")
+	}
+
+	// 取消全部市场
+	if err := cli.Unsubscribe([]*Subscription{{Topic: TopicBBO}}); err != nil {
+		t.Fatal(err)
+	}
+	expect(`bbo.unsubscribe {"market_list":[]}`)
+	if cli.IsSubscribed(TopicBBO, "") || len(cli.Subscriptions()) != 1 {
+		t.Fatalf("unexpected subscriptions: %+v", cli.Subscriptions())
+	}
+	select {
+	case got := <-sent:
+		t.Fatalf("unexpected message: %s", got)
+	default:
+	}
+}
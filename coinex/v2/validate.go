@@ -0,0 +1,190 @@
+package coinex
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+// 下单前校验不通过的原因
+const (
+	ReasonInvalidValue        = "invalid_value"        // 参数缺失或无法解析
+	ReasonPrecision           = "precision"            // 价格或数量超过精度
+	ReasonAmountTooSmall      = "amount_too_small"     // 低于最小交易量
+	ReasonInvalidClientID     = "invalid_client_id"    // client_id 不符合格式要求
+	ReasonInsufficientBalance = "insufficient_balance" // 可用余额不足
+)
+
+// 一条校验不通过的原因
+type Violation struct {
+	// 订单字段, 如 amount, price, client_id
+	Field   string
+	Reason  string
+	Message string
+}
+
+// 下单前校验不通过, 包含全部原因
+type ValidationError struct {
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		parts = append(parts, v.Field+": "+v.Reason+" ("+v.Message+")")
+	}
+	return "coinex: order rejected by validator: " + strings.Join(parts, "; ")
+}
+
+// 是否包含指定原因
+func (e *ValidationError) Has(reason string) bool {
+	for _, v := range e.Violations {
+		if v.Reason == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// 按市场规则和可用余额在本地校验订单, 可以通过 HTTPClient.SetValidator 在 SpotOrder 前自动执行
+type Validator struct {
+	rules *MarketRules
+	// 查询可用余额, 为 nil 时不检查余额
+	trader SpotTrader
+}
+
+// trader 为 nil 时不检查余额
+func NewValidator(rules *MarketRules, trader SpotTrader) *Validator {
+	return &Validator{
+		rules:  rules,
+		trader: trader,
+	}
+}
+
+// 校验订单, 不通过时返回 *ValidationError, 查询市场或余额失败时返回对应错误
+// 市价买单的 ccy 为报价币种时 amount 为报价币种金额
+func (v *Validator) Validate(o *SpotOrder) error {
+	m, err := v.rules.Market(o.Market)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	var list []Violation
+	add := func(field, reason, message string) {
+		list = append(list, Violation{Field: field, Reason: reason, Message: message})
+	}
+
+	if o.ClientID != "" {
+		if msg := checkClientID(o.ClientID); msg != "" {
+			add("client_id", ReasonInvalidClientID, msg)
+		}
+	}
+
+	buy := o.Side == "buy"
+	if o.Side != "buy" && o.Side != "sell" {
+		add("side", ReasonInvalidValue, "side must be buy or sell")
+	}
+	market := false
+	switch o.Type {
+	case OrderTypeMarket:
+		market = true
+	case OrderTypeLimit, OrderTypeMakerOnly, OrderTypeIOC, OrderTypeFOK:
+	default:
+		add("type", ReasonInvalidValue, "unknown order type "+o.Type)
+	}
+
+	// 按报价币种金额下单的市价单
+	quoteAmount := market && o.Ccy != "" && o.Ccy == m.QuoteCcy
+	amountPrecision := m.BaseCcyPrecision
+	if quoteAmount {
+		amountPrecision = m.QuoteCcyPrecision
+	}
+	switch {
+	case !o.Amount.IsPositive():
+		add("amount", ReasonInvalidValue, "amount must be positive")
+	case !o.Amount.Equal(o.Amount.Truncate(amountPrecision)):
+		add("amount", ReasonPrecision, "amount precision is "+decimal.NewFromInt32(amountPrecision).String())
+	case !quoteAmount && o.Amount.LessThan(m.MinAmount):
+		add("amount", ReasonAmountTooSmall, "min amount is "+m.MinAmount.String())
+	}
+	if !market {
+		switch {
+		case !o.Price.IsPositive():
+			add("price", ReasonInvalidValue, "price must be positive")
+		case !o.Price.Equal(o.Price.Truncate(m.QuoteCcyPrecision)):
+			add("price", ReasonPrecision, "price precision is "+decimal.NewFromInt32(m.QuoteCcyPrecision).String())
+		}
+	}
+
+	// 其它校验通过后才查询余额, 避免多余的请求
+	// 按交易币种数量下的市价买单和按报价币种金额下的市价卖单无法估算所需余额, 不检查余额
+	if v.trader != nil && len(list) == 0 && (o.MarketType == "" || o.MarketType == MarketTypeSpot) && !(market && buy != quoteAmount) {
+		ccy, need := m.BaseCcy, o.Amount
+		switch {
+		case quoteAmount:
+			ccy = m.QuoteCcy
+		case buy:
+			ccy, need = m.QuoteCcy, o.Amount.Mul(o.Price)
+		}
+		balances, err := v.trader.SpotBalance()
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		available := decimal.Zero
+		for _, b := range balances {
+			if b.Ccy == ccy {
+				available = available.Add(b.Available)
+			}
+		}
+		if available.LessThan(need) {
+			add("amount", ReasonInsufficientBalance, ccy+" available "+available.String()+", need "+need.String())
+		}
+	}
+
+	if len(list) > 0 {
+		return errors.WithStack(&ValidationError{Violations: list})
+	}
+	return nil
+}
+
+// 按 SpotOrder 的参数校验, 数量和价格无法解析时作为无效参数
+func (v *Validator) validateArgs(market, marketType, side, type_, ccy, amount, price, clientId string) error {
+	o := &SpotOrder{
+		Market:     market,
+		MarketType: marketType,
+		Ccy:        ccy,
+		Side:       side,
+		Type:       type_,
+		ClientID:   clientId,
+	}
+	var list []Violation
+	var err error
+	if o.Amount, err = decimal.NewFromString(amount); err != nil {
+		list = append(list, Violation{Field: "amount", Reason: ReasonInvalidValue, Message: err.Error()})
+	}
+	if type_ != OrderTypeMarket {
+		if o.Price, err = decimal.NewFromString(price); err != nil {
+			list = append(list, Violation{Field: "price", Reason: ReasonInvalidValue, Message: err.Error()})
+		}
+	}
+	if len(list) > 0 {
+		return errors.WithStack(&ValidationError{Violations: list})
+	}
+	return v.Validate(o)
+}
+
+// client_id 长度为 1 到 32 字节, 只能包含字母, 数字, 下划线或中划线
+func checkClientID(id string) string {
+	if len(id) > 32 {
+		return "client_id must be at most 32 bytes"
+	}
+	for i := 0; i < len(id); i++ {
+		ch := id[i]
+		if ch >= '0' && ch <= '9' || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch == '_' || ch == '-' {
+			continue
+		}
+		return "client_id may only contain letters, digits, _ or -"
+	}
+	return ""
+}
@@ -0,0 +1,60 @@
+package coinex
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+func TestValidator(t *testing.T) {
+	cli := newTestHTTPClient(t)
+	v := NewValidator(NewMarketRules(cli, 0, zap.NewExample()), cli)
+	cli.SetValidator(v)
+
+	_, err := cli.SpotOrder("BTCUSDT", MarketTypeSpot, "buy", OrderTypeLimit, "", "0.00001", "30000.001", "bad id")
+	var e *ValidationError
+	if !errors.As(err, &e) {
+		t.Fatalf("got %v, want validation error", err)
+	}
+	for _, reason := range []string{ReasonInvalidClientID, ReasonAmountTooSmall, ReasonPrecision} {
+		if !e.Has(reason) {
+			t.Fatalf("missing %s in %v", reason, e)
+		}
+	}
+
+	// 按报价币种金额下的市价买单检查报价币种余额
+	_, err = cli.SpotOrder("BTCUSDT", MarketTypeSpot, "buy", OrderTypeMarket, "USDT", "100.01", "", "")
+	if !errors.As(err, &e) || len(e.Violations) != 1 || !e.Has(ReasonInsufficientBalance) {
+		t.Fatalf("got %v, want insufficient balance", err)
+	}
+
+	// 按报价币种金额下的市价卖单需要的是交易币种, 无法估算数量, 不按报价币种余额拒绝
+	err = v.Validate(&SpotOrder{Market: "BTCUSDT", MarketType: MarketTypeSpot, Side: "sell", Type: OrderTypeMarket, Ccy: "USDT", Amount: decimal.RequireFromString("100.01")})
+	if err != nil {
+		t.Fatalf("market sell in quote: %v", err)
+	}
+
+	// 批量下单逐个校验, 字段带订单序号
+	_, err = cli.SpotBatchOrder([]*SpotOrderRequest{
+		{Market: "BTCUSDT", Side: SideSell, Type: OrderTypeLimit, Amount: decimal.RequireFromString("0.5"), Price: decimal.RequireFromString("30000"), ClientID: "c-a"},
+		{Market: "BTCUSDT", Side: SideSell, Type: OrderTypeLimit, Amount: decimal.RequireFromString("0.00001"), Price: decimal.RequireFromString("30000"), ClientID: "c-b"},
+	})
+	if !errors.As(err, &e) || len(e.Violations) == 0 || !e.Has(ReasonAmountTooSmall) {
+		t.Fatalf("got %v, want amount too small", err)
+	}
+	for _, item := range e.Violations {
+		if item.Field != "orders[1].amount" {
+			t.Fatalf("unexpected violation: %+v", item)
+		}
+	}
+
+	o, err := cli.SpotOrder("BTCUSDT", MarketTypeSpot, "sell", OrderTypeLimit, "", "0.5", "30000", "c-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.ClientID != "c-1" {
+		t.Fatalf("unexpected order: %+v", o)
+	}
+}
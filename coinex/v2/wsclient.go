@@ -2,40 +2,80 @@ package coinex
 
 import (
 	"bytes"
-	"compress/gzip"
 	"encoding/json"
-	"io"
+	"net"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/icwl/go-exchange-api/wsrecord"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
 )
 
 type WSClient struct {
 	url    string
-	cli    *websocket.Conn
 	stop   chan interface{}
 	lock   *sync.Mutex
 	wait   *sync.WaitGroup
 	logger *zap.Logger
+
+	// 当前连接, Read 无锁读取, 修改和写入时持有 lock
+	cli atomic.Pointer[websocket.Conn]
+
+	// 当前订阅
+	subs  map[string]*Subscription
+	slock *sync.Mutex
+
+	// 认证凭证, 重连后重新认证
+	key    string
+	secret string
+
+	// 请求 id
+	seq int64
+	hb  *heartbeat
+
+	// Read 复用的帧缓冲区和解压缓冲区
+	frame  *bytes.Buffer
+	reader *bytes.Reader
+	buf    *bytes.Buffer
+	// 深度推送解析为定点数
+	fixedPoint bool
+	// 帧录制, 为 nil 时不录制
+	recorder *wsrecord.Writer
 }
 
 func NewWSClient(url string, logger *zap.Logger) *WSClient {
 	ws := &WSClient{
 		url:    url,
-		cli:    nil,
 		stop:   nil,
 		lock:   new(sync.Mutex),
 		wait:   new(sync.WaitGroup),
 		logger: logger,
+		subs:   make(map[string]*Subscription),
+		slock:  new(sync.Mutex),
+		hb:     &heartbeat{timeout: int64(120 * time.Second)},
+		frame:  new(bytes.Buffer),
+		reader: bytes.NewReader(nil),
+		buf:    new(bytes.Buffer),
 	}
 
 	return ws
 }
 
 func (c *WSClient) Connect() error {
+	if err := c.dial(); err != nil {
+		return err
+	}
+
+	c.stop = make(chan interface{}, 1)
+
+	return nil
+}
+
+func (c *WSClient) dial() error {
 	var (
 		logger = c.logger
 	)
@@ -47,105 +87,163 @@ func (c *WSClient) Connect() error {
 
 	logger.Info("connect websocket", zap.String("url", c.url))
 
-	c.cli = cli
-	c.stop = make(chan interface{}, 1)
+	// websocket 协议层 pong, payload 为 ping 发送时间
+	cli.SetPongHandler(func(appData string) error {
+		sentAt, _ := strconv.ParseInt(appData, 10, 64)
+		c.pong(sentAt)
+		return cli.SetReadDeadline(time.Now().Add(c.pongTimeout()))
+	})
+	atomic.StoreInt64(&c.hb.lastPong, time.Now().UnixNano())
+
+	c.lock.Lock()
+	c.cli.Store(cli)
+	c.lock.Unlock()
 
 	return nil
 }
 
+// 可以重复调用
 func (c *WSClient) Close() error {
 	c.lock.Lock()
-	defer c.lock.Unlock()
 
-	if c.cli == nil {
+	if c.closed() {
+		c.lock.Unlock()
 		return nil
 	}
 
 	close(c.stop)
-	if err := c.cli.Close(); err != nil {
-		if err.Error() != "tls: use of closed connection" {
-			return errors.WithStack(err)
-		}
+	var err error
+	if cli := c.cli.Swap(nil); cli != nil {
+		err = cli.Close()
 	}
+	c.lock.Unlock()
 
-	c.logger.Info("关闭WS成功")
-
-	c.cli = nil
 	c.wait.Wait()
 
+	if err != nil && !errors.Is(err, net.ErrClosed) && err.Error() != "tls: use of closed connection" {
+		return errors.WithStack(err)
+	}
+	c.logger.Info("关闭WS成功")
+
 	return nil
 }
 
+// 未连接或已 Close, 调用方需持有 c.lock
+func (c *WSClient) closed() bool {
+	if c.stop == nil {
+		return true
+	}
+	select {
+	case <-c.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// 定时发送心跳, 超过 pong 超时时间未收到 pong 时标记连接失效, 由 Read 重连
 func (c *WSClient) Ping(interval time.Duration) error {
+	// 与 Close 互斥, 避免 Close 之后 Add
+	c.lock.Lock()
+	if c.closed() {
+		c.lock.Unlock()
+		return nil
+	}
 	c.wait.Add(1)
+	c.lock.Unlock()
 	defer c.wait.Done()
 
 	var (
-		method = "server.ping"
-		params = struct{}{}
 		logger = c.logger
 	)
 
 	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
 	for {
 		select {
 		case <-c.stop:
 			return nil
 		case <-ticker.C:
-			if err := c.SendMethod(method, params); err != nil {
+			if atomic.LoadInt32(&c.hb.stale) == 1 || c.checkStale() {
+				continue
+			}
+			if err := c.sendPing(); err != nil {
 				logger.Error("Send.Ping", zap.Error(err))
-				return errors.WithStack(err)
+				c.markStale("ping failed")
 			}
 		}
 	}
 }
 
+// 读取推送消息, 连接失效时自动重连并恢复订阅, 返回 *StaleEvent
+// 内部复用读缓冲区, 不能并发调用
 func (c *WSClient) Read() (interface{}, error) {
-	if err := c.cli.SetReadDeadline(time.Now().Add(120 * time.Second)); err != nil {
+	cli := c.cli.Load()
+	if cli == nil {
+		// 连接已被标记失效并关闭, 直接重连
+		if atomic.LoadInt32(&c.hb.stale) == 1 {
+			event, err := c.reconnect()
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			return event, nil
+		}
+		return nil, errors.New("websocket not connected")
+	}
+
+	if err := cli.SetReadDeadline(time.Now().Add(c.pongTimeout())); err != nil {
 		return nil, errors.WithStack(err)
 	}
 
-	_, msg, err := c.cli.ReadMessage()
+	frame, err := c.readFrame(cli)
 	if err != nil {
-		return nil, errors.WithStack(err)
+		// 读超时同样视为连接失效
+		if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			c.markStale("read timeout")
+		}
+		if atomic.LoadInt32(&c.hb.stale) == 0 {
+			return nil, errors.WithStack(err)
+		}
+		event, err := c.reconnect()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return event, nil
 	}
 
-	msg, err = GzipDecode(msg)
+	at := time.Now()
+	msg, err := c.inflate(frame)
+	c.record(at, frame, msg)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
 
-	var raw struct {
-		Method string          `json:"method"`
-		Data   json.RawMessage `json:"data"`
-		ID     interface{}     `json:"id"`
-	}
+	return c.decode(msg)
+}
 
-	if err := json.Unmarshal(msg, &raw); err != nil {
-		return nil, errors.WithStack(err)
+// 读取一帧到复用缓冲区, 返回的切片在下一次调用前有效
+func (c *WSClient) readFrame(cli *websocket.Conn) ([]byte, error) {
+	_, r, err := cli.NextReader()
+	if err != nil {
+		return nil, err
 	}
-
-	switch raw.Method {
-	case "depth.update":
-		var dp *SpotDepth
-		if err := json.Unmarshal(raw.Data, &dp); err != nil {
-			err = errors.Wrap(err, string(raw.Data))
-			return nil, errors.WithStack(err)
-		}
-		return dp, nil
+	c.frame.Reset()
+	if _, err := c.frame.ReadFrom(r); err != nil {
+		return nil, err
 	}
-	return nil, nil
+	return c.frame.Bytes(), nil
 }
 
 func (c *WSClient) Send(msg []byte) error {
 	c.lock.Lock()
 	defer c.lock.Unlock()
 
-	if c.cli == nil {
+	cli := c.cli.Load()
+	if cli == nil {
 		return nil
 	}
 
-	if err := c.cli.WriteMessage(websocket.TextMessage, msg); err != nil {
+	if err := cli.WriteMessage(websocket.TextMessage, msg); err != nil {
 		c.logger.Error("WriteMessage", zap.Error(err), zap.String("msg", string(msg)))
 		return errors.WithStack(err)
 	}
@@ -154,8 +252,12 @@ func (c *WSClient) Send(msg []byte) error {
 }
 
 func (c *WSClient) SendMethod(method string, params interface{}) error {
+	return c.sendMethod(atomic.AddInt64(&c.seq, 1), method, params)
+}
+
+func (c *WSClient) sendMethod(id int64, method string, params interface{}) error {
 	msg, err := json.Marshal(map[string]interface{}{
-		"id":     1,
+		"id":     id,
 		"method": method,
 		"params": params,
 	})
@@ -166,23 +268,100 @@ func (c *WSClient) SendMethod(method string, params interface{}) error {
 	return c.Send(msg)
 }
 
+// 私有推送认证, 认证后才能订阅订单推送, 重连后自动重新认证
+func (c *WSClient) Login(key, secret string) error {
+	timestamp := time.Now().UnixMilli()
+	err := c.SendMethod("server.sign", map[string]interface{}{
+		"access_id":  key,
+		"signed_str": WSSign(strconv.FormatInt(timestamp, 10), secret),
+		"timestamp":  timestamp,
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	c.lock.Lock()
+	c.key, c.secret = key, secret
+	c.lock.Unlock()
+	return nil
+}
+
+func (c *WSClient) credentials() (string, string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.key, c.secret
+}
+
 // 市场深度订阅
+// - markets 市场列表, 空列表表示订阅全部市场
 func (c *WSClient) SubDepth(markets []string, limit int, interval string, isFull bool) error {
-	method := "depth.subscribe"
-	list := make([][]interface{}, 0, len(markets))
-	for _, market := range markets {
-		list = append(list, []interface{}{market, limit, interval, isFull})
-	}
-	params := map[string]interface{}{"market_list": list}
-	return c.SendMethod(method, params)
+	return c.Subscribe(subDepthSubscriptions(markets, limit, interval, isFull))
+}
+
+// 取消市场深度订阅
+// - markets 市场列表, 空列表表示取消全部市场
+func (c *WSClient) UnsubDepth(markets []string) error {
+	return c.Unsubscribe(subscriptions(TopicDepth, markets))
+}
+
+// 最新成交订阅
+// - markets 市场列表, 空列表表示订阅全部市场
+func (c *WSClient) SubDeals(markets []string) error {
+	return c.Subscribe(subscriptions(TopicDeals, markets))
+}
+
+// 取消最新成交订阅
+// - markets 市场列表, 空列表表示取消全部市场
+func (c *WSClient) UnsubDeals(markets []string) error {
+	return c.Unsubscribe(subscriptions(TopicDeals, markets))
+}
+
+// 市场状态订阅
+// - markets 市场列表, 空列表表示订阅全部市场
+func (c *WSClient) SubState(markets []string) error {
+	return c.Subscribe(subscriptions(TopicState, markets))
+}
+
+// 取消市场状态订阅
+func (c *WSClient) UnsubState(markets []string) error {
+	return c.Unsubscribe(subscriptions(TopicState, markets))
+}
+
+// 最优挂单订阅
+func (c *WSClient) SubBBO(markets []string) error {
+	return c.Subscribe(subscriptions(TopicBBO, markets))
+}
+
+// 取消最优挂单订阅
+func (c *WSClient) UnsubBBO(markets []string) error {
+	return c.Unsubscribe(subscriptions(TopicBBO, markets))
+}
+
+// 指数价格订阅
+func (c *WSClient) SubIndex(markets []string) error {
+	return c.Subscribe(subscriptions(TopicIndex, markets))
+}
+
+// 取消指数价格订阅
+func (c *WSClient) UnsubIndex(markets []string) error {
+	return c.Unsubscribe(subscriptions(TopicIndex, markets))
+}
+
+// 订单推送订阅, 需要先 Login
+// - markets 市场列表, 空列表表示订阅全部市场
+func (c *WSClient) SubOrder(markets []string) error {
+	return c.Subscribe(subscriptions(TopicOrder, markets))
+}
+
+// 取消订单推送订阅
+func (c *WSClient) UnsubOrder(markets []string) error {
+	return c.Unsubscribe(subscriptions(TopicOrder, markets))
 }
 
 func GzipDecode(in []byte) ([]byte, error) {
-	reader, err := gzip.NewReader(bytes.NewReader(in))
-	if err != nil {
+	var buf bytes.Buffer
+	if err := gunzip(&buf, bytes.NewReader(in)); err != nil {
 		return nil, err
 	}
-	defer reader.Close()
-
-	return io.ReadAll(reader)
+	return buf.Bytes(), nil
 }
@@ -1,9 +1,18 @@
 package coinex
 
 import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/gorilla/websocket"
+	"github.com/shopspring/decimal"
 	"go.uber.org/zap"
 )
 
@@ -45,3 +54,187 @@ func TestWSClient_SubDepth(t *testing.T) {
 		}
 	}
 }
+
+// 本地模拟 websocket 服务, 每条订阅请求回复 handle 返回的推送, 推送使用 gzip 压缩
+func newTestWSServer(t *testing.T, handle func(method string, params json.RawMessage) []interface{}) *httptest.Server {
+	upgrader := websocket.Upgrader{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		for {
+			var req struct {
+				Method string          `json:"method"`
+				Params json.RawMessage `json:"params"`
+			}
+			if err := conn.ReadJSON(&req); err != nil {
+				return
+			}
+			for _, push := range handle(req.Method, req.Params) {
+				if err := writeGzipJSON(conn, push); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}
+	}))
+}
+
+func writeGzipJSON(conn *websocket.Conn, v interface{}) error {
+	msg, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, _ = zw.Write(msg)
+	_ = zw.Close()
+	return conn.WriteMessage(websocket.BinaryMessage, buf.Bytes())
+}
+
+func TestWSClient_SubBBO(t *testing.T) {
+	srv := newTestWSServer(t, func(method string, params json.RawMessage) []interface{} {
+		if method != "bbo.subscribe" {
+			return nil
+		}
+		return []interface{}{
+			map[string]interface{}{"id": 1, "code": 0, "message": "OK"},
+			map[string]interface{}{
+				"method": "bbo.update",
+				"data": map[string]interface{}{
+					"market":         "BTCUSDT",
+					"updated_at":     1700000000000,
+					"best_bid_price": "30000.1",
+					"best_bid_size":  "0.5",
+					"best_ask_price": "30000.2",
+					"best_ask_size":  "1.2",
+				},
+				"id": nil,
+			},
+		}
+	})
+	defer srv.Close()
+
+	logger := zap.NewExample()
+	cli := NewWSClient("ws"+strings.TrimPrefix(srv.URL, "http"), logger)
+	if err := cli.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+
+	if err := cli.SubBBO([]string{"BTCUSDT"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for {
+		msg, err := cli.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		bbo, ok := msg.(*SpotBBO)
+		if !ok {
+			continue
+		}
+		if bbo.Market != "BTCUSDT" || !bbo.BestAskPrice.Equal(decimal.RequireFromString("30000.2")) {
+			t.Fatalf("unexpected bbo: %+v", bbo)
+		}
+		t.Logf("最优挂单 %s 买一: %s@%s 卖一: %s@%s", bbo.Market,
+			bbo.BestBidSize, bbo.BestBidPrice, bbo.BestAskSize, bbo.BestAskPrice)
+		return
+	}
+}
+
+func TestWSSign(t *testing.T) {
+	if got := WSSign("1700000000000", "secret"); got != "4fe2ce12fd6bec5e0648b23bfbff158ba5fc18b3a214b4c207314671448a95e1" {
+		t.Fatalf("unexpected sign: %s", got)
+	}
+}
+
+func TestWSClient_Login(t *testing.T) {
+	type request struct {
+		method string
+		params json.RawMessage
+	}
+	sent := make(chan request, 16)
+	srv := newTestWSServer(t, func(method string, params json.RawMessage) []interface{} {
+		sent <- request{method, params}
+		if method != "order.subscribe" {
+			return nil
+		}
+		return []interface{}{
+			map[string]interface{}{
+				"method": "order.update",
+				"data": map[string]interface{}{
+					"event": "put",
+					"order": map[string]interface{}{"order_id": 1, "market": "BTCUSDT", "side": "buy", "status": "open"},
+				},
+				"id": nil,
+			},
+		}
+	})
+	defer srv.Close()
+
+	cli := NewWSClient("ws"+strings.TrimPrefix(srv.URL, "http"), zap.NewNop())
+	if err := cli.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+
+	if err := cli.Login("key", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cli.SubOrder([]string{"BTCUSDT"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for {
+		msg, err := cli.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if event, ok := msg.(*SpotOrderEvent); ok {
+			if event.Event != "put" || event.Order.Market != "BTCUSDT" || event.Order.OrderID != 1 {
+				t.Fatalf("unexpected order event: %+v", event)
+			}
+			break
+		}
+	}
+
+	// 重连后先重新认证再恢复订阅
+	cli.markStale("test")
+	msg, err := cli.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := msg.(*StaleEvent); !ok {
+		t.Fatalf("got %T, want *StaleEvent", msg)
+	}
+
+	for i := 0; i < 2; i++ {
+		var sign, sub request
+		for _, r := range []*request{&sign, &sub} {
+			select {
+			case *r = <-sent:
+			case <-time.After(time.Second):
+				t.Fatal("timeout waiting for request")
+			}
+		}
+		if sign.method != "server.sign" || sub.method != "order.subscribe" || string(sub.params) != `{"market_list":["BTCUSDT"]}` {
+			t.Fatalf("unexpected requests: %s %s %s", sign.method, sub.method, sub.params)
+		}
+		var params struct {
+			AccessID  string `json:"access_id"`
+			SignedStr string `json:"signed_str"`
+			Timestamp int64  `json:"timestamp"`
+		}
+		if err := json.Unmarshal(sign.params, &params); err != nil {
+			t.Fatal(err)
+		}
+		if params.AccessID != "key" || params.SignedStr != WSSign(strconv.FormatInt(params.Timestamp, 10), "secret") {
+			t.Fatalf("unexpected sign params: %s", sign.params)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package coinex
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+func TestWSPool(t *testing.T) {
+	var (
+		lock sync.Mutex
+		// 市场 -> 服务端连接
+		conns = make(map[string]*websocket.Conn)
+	)
+
+	// 每个 depth.subscribe 的市场推送一条全量深度
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		for {
+			var req struct {
+				Method string          `json:"method"`
+				Params json.RawMessage `json:"params"`
+			}
+			if err := conn.ReadJSON(&req); err != nil {
+				return
+			}
+			if req.Method != "depth.subscribe" {
+				continue
+			}
+			var params struct {
+				MarketList [][]interface{} `json:"market_list"`
+			}
+			if err := json.Unmarshal(req.Params, &params); err != nil {
+				t.Error(err)
+				return
+			}
+			for _, item := range params.MarketList {
+				lock.Lock()
+				conns[item[0].(string)] = conn
+				lock.Unlock()
+				push := map[string]interface{}{
+					"method": "depth.update",
+					"data": map[string]interface{}{
+						"market":  item[0],
+						"is_full": true,
+						"depth": map[string]interface{}{
+							"asks": [][]string{{"30001", "1"}},
+							"bids": [][]string{{"30000", "1"}},
+						},
+					},
+				}
+				lock.Lock()
+				err := writeGzipJSON(conn, push)
+				lock.Unlock()
+				if err != nil {
+					return
+				}
+			}
+		}
+	}))
+	defer srv.Close()
+
+	logger := zap.NewExample()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+	if _, err := NewWSPool(url, 3, 0, logger); err == nil {
+		t.Fatal("expected error for zero limit")
+	}
+	pool, err := NewWSPool(url, 3, 3, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.SetHeartbeat(time.Second, 5*time.Second)
+	if err := pool.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	markets := []string{"BTCUSDT", "ETHUSDT", "SOLUSDT", "DOGEUSDT"}
+	if err := pool.SubDepth(markets, 10, "0", true); err != nil {
+		t.Fatal(err)
+	}
+	if got := pool.Load(); got[0] != 2 || got[1] != 1 || got[2] != 1 {
+		t.Fatalf("unexpected load: %v", got)
+	}
+
+	// 等待 want 中每个市场至少一条推送, 忽略其他市场
+	expect := func(want ...string) {
+		pending := make(map[string]bool)
+		for _, m := range want {
+			pending[m] = true
+		}
+		timeout := time.After(2 * time.Second)
+		for len(pending) > 0 {
+			select {
+			case msg := <-pool.Events():
+				if dp, ok := msg.(*SpotDepth); ok {
+					delete(pending, dp.Market)
+				}
+			case <-timeout:
+				t.Fatalf("missing %v", pending)
+			}
+		}
+	}
+	expect(markets...)
+
+	// 容量不足
+	more := []string{"A", "B", "C", "D", "E", "F"}
+	if err := pool.SubDepth(more, 10, "0", true); errors.Cause(err) != ErrPoolFull {
+		t.Fatalf("got %v, want ErrPoolFull", err)
+	}
+	if n := len(pool.Subscriptions()); n != 9 {
+		t.Fatalf("got %d subscriptions, want 9", n)
+	}
+	if err := pool.UnsubDepth(more); err != nil {
+		t.Fatal(err)
+	}
+
+	// 断开 BTCUSDT 所在连接, 其订阅转移到其他连接
+	lock.Lock()
+	_ = conns["BTCUSDT"].Close()
+	lock.Unlock()
+
+	expect("BTCUSDT")
+	total := 0
+	for _, n := range pool.Load() {
+		if n > 0 {
+			total += n
+		}
+		if n > 3 {
+			t.Fatalf("unexpected load after rebalance: %v", pool.Load())
+		}
+	}
+	if total != 4 {
+		t.Fatalf("unexpected load after rebalance: %v", pool.Load())
+	}
+
+	// Close 后重新订阅已分配的订阅不再使用已关闭的连接
+	if err := pool.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.SubDepth(markets, 10, "0", true); errors.Cause(err) != ErrPoolFull {
+		t.Fatalf("got %v, want ErrPoolFull", err)
+	}
+}
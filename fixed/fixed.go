@@ -0,0 +1,259 @@
+// 定点数, 用于高频解析深度价格和数量, 避免 decimal.Decimal 的内存分配
+package fixed
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// 小数位数
+	Decimals = 8
+	Scale    = 100000000
+)
+
+var (
+	ErrSyntax = errors.New("fixed: invalid syntax")
+	// 超出 int64 范围或小数位数超过 Decimals, 深度推送遇到该错误时回退为 decimal 解析
+	ErrRange = errors.New("fixed: value out of range")
+)
+
+// 定点数, 值为 实际值 * Scale
+type Fixed int64
+
+func Parse(b []byte) (Fixed, error) {
+	if len(b) == 0 {
+		return 0, ErrSyntax
+	}
+
+	var (
+		i        int
+		neg      bool
+		v        uint64
+		digits   bool
+		fraction = -1
+	)
+	switch b[0] {
+	case '-':
+		neg = true
+		i++
+	case '+':
+		i++
+	}
+
+	for ; i < len(b); i++ {
+		ch := b[i]
+		if ch == '.' {
+			if fraction >= 0 {
+				return 0, ErrSyntax
+			}
+			fraction = 0
+			continue
+		}
+		if ch < '0' || ch > '9' {
+			return 0, ErrSyntax
+		}
+		digits = true
+		if fraction >= Decimals {
+			// 超出精度的部分必须为 0
+			if ch != '0' {
+				return 0, ErrRange
+			}
+			continue
+		}
+		if v > (math.MaxInt64-9)/10 {
+			return 0, ErrRange
+		}
+		v = v*10 + uint64(ch-'0')
+		if fraction >= 0 {
+			fraction++
+		}
+	}
+	if !digits {
+		return 0, ErrSyntax
+	}
+
+	if fraction < 0 {
+		fraction = 0
+	}
+	for ; fraction < Decimals; fraction++ {
+		if v > math.MaxInt64/10 {
+			return 0, ErrRange
+		}
+		v *= 10
+	}
+
+	if neg {
+		return Fixed(-int64(v)), nil
+	}
+	return Fixed(v), nil
+}
+
+func ParseString(s string) (Fixed, error) {
+	return Parse([]byte(s))
+}
+
+func FromDecimal(d decimal.Decimal) (Fixed, error) {
+	scaled := d.Shift(Decimals)
+	if !scaled.Equal(scaled.Truncate(0)) {
+		return 0, ErrRange
+	}
+	if !scaled.BigInt().IsInt64() {
+		return 0, ErrRange
+	}
+	return Fixed(scaled.IntPart()), nil
+}
+
+func (f Fixed) Decimal() decimal.Decimal {
+	return decimal.New(int64(f), -Decimals)
+}
+
+func (f Fixed) Float64() float64 {
+	return float64(f) / Scale
+}
+
+func (f Fixed) IsZero() bool {
+	return f == 0
+}
+
+// 去掉末尾 0 的十进制字符串
+func (f Fixed) String() string {
+	return string(f.Append(nil))
+}
+
+func (f Fixed) Append(dst []byte) []byte {
+	v := int64(f)
+	if v < 0 {
+		dst = append(dst, '-')
+	}
+	u := uint64(v)
+	if v < 0 {
+		u = uint64(-v)
+	}
+	dst = strconv.AppendUint(dst, u/Scale, 10)
+
+	frac := u % Scale
+	if frac == 0 {
+		return dst
+	}
+	var buf [Decimals]byte
+	n := Decimals
+	for i := Decimals - 1; i >= 0; i-- {
+		buf[i] = byte('0' + frac%10)
+		frac /= 10
+	}
+	for n > 0 && buf[n-1] == '0' {
+		n--
+	}
+	dst = append(dst, '.')
+	return append(dst, buf[:n]...)
+}
+
+// 支持字符串和数字
+func (f *Fixed) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		return nil
+	}
+	if len(b) >= 2 && b[0] == '"' && b[len(b)-1] == '"' {
+		b = b[1 : len(b)-1]
+	}
+	v, err := Parse(b)
+	if err != nil {
+		return errors.Wrap(err, string(b))
+	}
+	*f = v
+	return nil
+}
+
+// 序列化为字符串, 与交易所格式一致
+func (f Fixed) MarshalJSON() ([]byte, error) {
+	dst := make([]byte, 0, 24)
+	dst = append(dst, '"')
+	dst = f.Append(dst)
+	return append(dst, '"'), nil
+}
+
+// 解析深度档位 [["价格","数量"],...], 结果追加到 dst, 可复用 dst 减少分配
+func ParseLevels(b []byte, dst [][2]Fixed) ([][2]Fixed, error) {
+	i := skipSpace(b, 0)
+	if i >= len(b) || b[i] != '[' {
+		return dst, ErrSyntax
+	}
+	i = skipSpace(b, i+1)
+	if i < len(b) && b[i] == ']' {
+		return dst, nil
+	}
+
+	for {
+		if i >= len(b) || b[i] != '[' {
+			return dst, ErrSyntax
+		}
+		i++
+
+		var level [2]Fixed
+		for k := 0; k < 2; k++ {
+			i = skipSpace(b, i)
+			start, end, next, err := scalar(b, i)
+			if err != nil {
+				return dst, err
+			}
+			if level[k], err = Parse(b[start:end]); err != nil {
+				return dst, err
+			}
+			i = skipSpace(b, next)
+			if k == 0 {
+				if i >= len(b) || b[i] != ',' {
+					return dst, ErrSyntax
+				}
+				i++
+			}
+		}
+		if i >= len(b) || b[i] != ']' {
+			return dst, ErrSyntax
+		}
+		dst = append(dst, level)
+
+		i = skipSpace(b, i+1)
+		if i >= len(b) {
+			return dst, ErrSyntax
+		}
+		switch b[i] {
+		case ',':
+			i = skipSpace(b, i+1)
+		case ']':
+			return dst, nil
+		default:
+			return dst, ErrSyntax
+		}
+	}
+}
+
+// 字符串或数字的值区间, 以及之后的位置
+func scalar(b []byte, i int) (start, end, next int, err error) {
+	if i >= len(b) {
+		return 0, 0, 0, ErrSyntax
+	}
+	if b[i] == '"' {
+		for j := i + 1; j < len(b); j++ {
+			if b[j] == '"' {
+				return i + 1, j, j + 1, nil
+			}
+		}
+		return 0, 0, 0, ErrSyntax
+	}
+	j := i
+	for j < len(b) && b[j] != ',' && b[j] != ']' && b[j] != ' ' {
+		j++
+	}
+	return i, j, j, nil
+}
+
+func skipSpace(b []byte, i int) int {
+	for i < len(b) && (b[i] == ' ' || b[i] == '\t' || b[i] == '\n' || b[i] == '\r') {
+		i++
+	}
+	return i
+}
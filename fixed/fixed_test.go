@@ -0,0 +1,109 @@
+package fixed
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		in   string
+		want Fixed
+		err  error
+	}{
+		{"0", 0, nil},
+		{"1", Scale, nil},
+		{"-1.5", -150000000, nil},
+		{"30000.12345678", 3000012345678, nil},
+		{"0.00000001", 1, nil},
+		{"0.000000010", 1, nil},
+		{".5", 50000000, nil},
+		{"0.000000001", 0, ErrRange},
+		{"99999999999", 0, ErrRange},
+		{"", 0, ErrSyntax},
+		{"-", 0, ErrSyntax},
+		{"1.2.3", 0, ErrSyntax},
+		{"1e8", 0, ErrSyntax},
+	}
+	for _, c := range cases {
+		got, err := ParseString(c.in)
+		if err != c.err {
+			t.Fatalf("%q: got err %v, want %v", c.in, err, c.err)
+		}
+		if got != c.want {
+			t.Fatalf("%q: got %d, want %d", c.in, got, c.want)
+		}
+	}
+}
+
+func TestFixed_String(t *testing.T) {
+	for _, s := range []string{"0", "1", "-1.5", "30000.12345678", "0.00000001", "-0.1"} {
+		f, err := ParseString(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.String() != s {
+			t.Fatalf("got %s, want %s", f.String(), s)
+		}
+		if !f.Decimal().Equal(decimal.RequireFromString(s)) {
+			t.Fatalf("%s: decimal %s", s, f.Decimal())
+		}
+		d, err := FromDecimal(decimal.RequireFromString(s))
+		if err != nil || d != f {
+			t.Fatalf("%s: from decimal %d %v", s, d, err)
+		}
+	}
+}
+
+func TestFixed_JSON(t *testing.T) {
+	var v struct {
+		A Fixed `json:"a"`
+		B Fixed `json:"b"`
+	}
+	if err := json.Unmarshal([]byte(`{"a":"1.25","b":3}`), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.A != 125000000 || v.B != 3*Scale {
+		t.Fatalf("unexpected %+v", v)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"a":"1.25","b":"3"}` {
+		t.Fatalf("unexpected %s", b)
+	}
+}
+
+func TestParseLevels(t *testing.T) {
+	levels, err := ParseLevels([]byte(` [["30000.1", "0.5"],[30000.2,1]] `), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(levels) != 2 || levels[0][0].String() != "30000.1" || levels[1][1] != Scale {
+		t.Fatalf("unexpected %v", levels)
+	}
+
+	levels, err = ParseLevels([]byte(`[]`), levels[:0])
+	if err != nil || len(levels) != 0 {
+		t.Fatalf("unexpected %v %v", levels, err)
+	}
+
+	for _, in := range []string{`[["1"]]`, `[["1","2"]`, `{}`, `[["1","x"]]`} {
+		if _, err := ParseLevels([]byte(in), nil); err == nil {
+			t.Fatalf("%s: expected error", in)
+		}
+	}
+}
+
+func BenchmarkParse(b *testing.B) {
+	in := []byte("30000.12345678")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := Parse(in); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package gate
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// 倒计时撤单的最短时间
+const minCountdown = 5 * time.Second
+
+var (
+	ErrCountdownTooShort = errors.New("gate: countdown must be at least 5 seconds")
+	ErrIntervalTooLong   = errors.New("gate: refresh interval must be shorter than countdown")
+	ErrDeadManStarted    = errors.New("gate: dead man switch already started")
+)
+
+// 设置倒计时撤单, 超过 timeout 未再次设置时交易所撤销全部挂单, timeout 为 0 时取消倒计时
+// pair 为空时对全部交易对生效, 返回预计撤单时间
+func (c *HTTPClient) CountdownCancelAll(timeout time.Duration, pair string) (time.Time, error) {
+	method := http.MethodPost
+	path := "/api/v4/spot/countdown_cancel_all"
+	if timeout != 0 && timeout < minCountdown {
+		return time.Time{}, errors.WithStack(ErrCountdownTooShort)
+	}
+	body := make(map[string]interface{})
+	body["timeout"] = int64(timeout / time.Second)
+	if pair != "" {
+		body["currency_pair"] = pair
+	}
+
+	respBody, err := c.Request(method, path, nil, body, true)
+	if err != nil {
+		c.logger.Error(method+" "+path, zap.Error(err))
+		return time.Time{}, errors.WithStack(err)
+	}
+
+	var reply struct {
+		TriggerTime int64 `json:"triggerTime"`
+	}
+	if err := json.Unmarshal(respBody, &reply); err != nil {
+		c.logger.Error(method+" "+path, zap.String("reply", string(respBody)), zap.Error(err))
+		err := ErrResponseBody(respBody)
+		return time.Time{}, errors.WithStack(err)
+	}
+
+	if reply.TriggerTime == 0 {
+		return time.Time{}, nil
+	}
+	return time.UnixMilli(reply.TriggerTime), nil
+}
+
+// 进程失效保护, 由后台 goroutine 定时刷新交易所的倒计时撤单
+// 进程退出或无法访问交易所时倒计时到期, 交易所撤销全部挂单
+type DeadManSwitch struct {
+	cli      *HTTPClient
+	pair     string
+	timeout  time.Duration
+	interval time.Duration
+	logger   *zap.Logger
+
+	lock      *sync.Mutex
+	triggerAt time.Time
+
+	// 串行化 Start 和 Stop
+	runLock *sync.Mutex
+	running bool
+	errors  chan error
+	stop    chan interface{}
+	wait    *sync.WaitGroup
+}
+
+// - pair 为空时对全部交易对生效
+// - timeout 倒计时时间, 不小于 5 秒
+// - interval 刷新间隔, 为 0 时为 timeout 的 1/3, 需要小于 timeout
+func (c *HTTPClient) DeadManSwitch(pair string, timeout, interval time.Duration) *DeadManSwitch {
+	if interval <= 0 {
+		interval = timeout / 3
+	}
+	return &DeadManSwitch{
+		cli:      c,
+		pair:     pair,
+		timeout:  timeout,
+		interval: interval,
+		logger:   c.logger,
+		lock:     new(sync.Mutex),
+		runLock:  new(sync.Mutex),
+		errors:   make(chan error, 16),
+		wait:     new(sync.WaitGroup),
+	}
+}
+
+// 设置倒计时并启动刷新, 首次设置失败时返回错误, 不启动刷新
+// 运行中再次调用返回 ErrDeadManStarted, Stop 后可以重新 Start
+func (d *DeadManSwitch) Start() error {
+	if d.timeout < minCountdown {
+		return errors.WithStack(ErrCountdownTooShort)
+	}
+	if d.interval >= d.timeout {
+		return errors.WithStack(ErrIntervalTooLong)
+	}
+	d.runLock.Lock()
+	defer d.runLock.Unlock()
+	if d.running {
+		return errors.WithStack(ErrDeadManStarted)
+	}
+	if err := d.refresh(); err != nil {
+		return errors.WithStack(err)
+	}
+
+	// 上一次 Stop 已关闭错误通道
+	if d.stop != nil {
+		d.errors = make(chan error, 16)
+	}
+	d.running = true
+	d.stop = make(chan interface{})
+	d.wait.Add(1)
+	go d.run(d.stop, d.errors)
+	return nil
+}
+
+// 停止刷新并取消倒计时, 用于正常退出, 挂单不会被撤销
+func (d *DeadManSwitch) Stop() error {
+	d.runLock.Lock()
+	defer d.runLock.Unlock()
+	if !d.running {
+		return nil
+	}
+	d.running = false
+	close(d.stop)
+	d.wait.Wait()
+	close(d.errors)
+
+	if _, err := d.cli.CountdownCancelAll(0, d.pair); err != nil {
+		d.logger.Error("DeadManSwitch.Stop", zap.Error(err))
+		return errors.WithStack(err)
+	}
+	d.lock.Lock()
+	d.triggerAt = time.Time{}
+	d.lock.Unlock()
+	return nil
+}
+
+// 刷新失败的错误, 刷新成功前倒计时不会延长, 超过 TriggerAt 后挂单已被撤销
+// 未及时读取时丢弃, Stop 后关闭, 重新 Start 后需要再次调用 Errors 获取新的通道
+func (d *DeadManSwitch) Errors() <-chan error {
+	d.runLock.Lock()
+	defer d.runLock.Unlock()
+	return d.errors
+}
+
+// 最近一次刷新成功后的预计撤单时间
+func (d *DeadManSwitch) TriggerAt() time.Time {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.triggerAt
+}
+
+func (d *DeadManSwitch) run(stop chan interface{}, errs chan error) {
+	defer d.wait.Done()
+
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := d.refresh(); err != nil {
+				d.logger.Error("DeadManSwitch.refresh", zap.Time("trigger_at", d.TriggerAt()), zap.Error(err))
+				select {
+				case errs <- err:
+				default:
+				}
+			}
+		}
+	}
+}
+
+func (d *DeadManSwitch) refresh() error {
+	triggerAt, err := d.cli.CountdownCancelAll(d.timeout, d.pair)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if triggerAt.IsZero() {
+		triggerAt = time.Now().Add(d.timeout)
+	}
+	d.lock.Lock()
+	d.triggerAt = triggerAt
+	d.lock.Unlock()
+	return nil
+}
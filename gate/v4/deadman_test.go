@@ -0,0 +1,115 @@
+package gate
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+func TestDeadManSwitch(t *testing.T) {
+	var (
+		lock     sync.Mutex
+		timeouts []int64
+		fail     bool
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v4/spot/countdown_cancel_all" {
+			http.NotFound(w, r)
+			return
+		}
+		var body struct {
+			Timeout      int64  `json:"timeout"`
+			CurrencyPair string `json:"currency_pair"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+
+		lock.Lock()
+		defer lock.Unlock()
+		timeouts = append(timeouts, body.Timeout)
+		if fail && body.Timeout != 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(`{"label":"SERVER_ERROR","message":"internal error"}`))
+			return
+		}
+		triggerTime := int64(0)
+		if body.Timeout != 0 {
+			triggerTime = time.Now().Add(time.Duration(body.Timeout) * time.Second).UnixMilli()
+		}
+		_, _ = w.Write([]byte(`{"triggerTime":` + strconv.FormatInt(triggerTime, 10) + `}`))
+	}))
+	defer srv.Close()
+
+	cli := NewHTTPClient(srv.URL, "key", "secret", zap.NewNop())
+	if err := cli.DeadManSwitch("BTC_USDT", time.Second, 0).Start(); !errors.Is(err, ErrCountdownTooShort) {
+		t.Fatalf("expected countdown too short: %v", err)
+	}
+	if err := cli.DeadManSwitch("BTC_USDT", 5*time.Second, 5*time.Second).Start(); !errors.Is(err, ErrIntervalTooLong) {
+		t.Fatalf("expected interval too long: %v", err)
+	}
+
+	d := cli.DeadManSwitch("BTC_USDT", 5*time.Second, 10*time.Millisecond)
+	if err := d.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if until := time.Until(d.TriggerAt()); until < 4*time.Second || until > 6*time.Second {
+		t.Fatalf("unexpected trigger at: %v", d.TriggerAt())
+	}
+	if err := d.Start(); !errors.Is(err, ErrDeadManStarted) {
+		t.Fatalf("expected already started: %v", err)
+	}
+
+	// 刷新失败时从 Errors 返回
+	time.Sleep(50 * time.Millisecond)
+	lock.Lock()
+	fail = true
+	lock.Unlock()
+	select {
+	case err := <-d.Errors():
+		t.Logf("刷新失败 : %v", err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("refresh error not reported")
+	}
+
+	// 正常退出时取消倒计时
+	if err := d.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if !d.TriggerAt().IsZero() {
+		t.Fatalf("countdown not disarmed: %v", d.TriggerAt())
+	}
+
+	if err := d.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	lock.Lock()
+	if len(timeouts) < 3 || timeouts[0] != 5 || timeouts[len(timeouts)-1] != 0 {
+		lock.Unlock()
+		t.Fatalf("unexpected timeouts: %v", timeouts)
+	}
+	fail = false
+	lock.Unlock()
+
+	// Stop 后可以重新 Start, 错误通道重新创建
+	if err := d.Start(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err, ok := <-d.Errors():
+		t.Fatalf("unexpected error after restart: %v %v", err, ok)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if err := d.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := <-d.Errors(); ok {
+		t.Fatal("errors not closed after stop")
+	}
+}
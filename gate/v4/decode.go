@@ -0,0 +1,163 @@
+package gate
+
+import (
+	"encoding/json"
+	"strings"
+	"sync/atomic"
+
+	"github.com/icwl/go-exchange-api/fixed"
+	"github.com/icwl/go-exchange-api/internal/jsonscan"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+// 订单簿推送, 价格和数量为定点数, 由 SetFixedPoint 开启
+type OrderBookFixed struct {
+	Pair string
+	// [[卖方价格, 卖方数量],...]
+	Asks [][2]fixed.Fixed `json:"asks"`
+	// [[买方价格, 买方数量],...]
+	Bids [][2]fixed.Fixed `json:"bids"`
+}
+
+// 开启后订单簿推送解析为 *OrderBookFixed, 需要在 Read 之前调用
+// 价格或数量超出 fixed.Decimals 位小数或 int64 范围的帧仍解析为 *OrderBook
+func (c *WSClient) SetFixedPoint(enable bool) {
+	c.fixedPoint = enable
+}
+
+// 解析一条消息, 只扫描一次顶层字段, result 按频道直接解析为对应类型
+func (c *WSClient) decode(msg []byte) (interface{}, error) {
+	var channel, event, result []byte
+	var isResponse bool
+	err := jsonscan.Object(msg, func(key, value []byte) bool {
+		switch string(key) {
+		case "channel":
+			channel = jsonscan.Unquote(value)
+		case "event":
+			event = jsonscan.Unquote(value)
+		case "result":
+			result = value
+		case "request_id":
+			isResponse = len(jsonscan.Unquote(value)) > 0 && !jsonscan.IsNull(value)
+		}
+		return true
+	})
+	if err != nil {
+		err = errors.Wrap(err, string(msg))
+		return nil, errors.WithStack(err)
+	}
+
+	// spot.ping 响应
+	if string(channel) == "spot.pong" {
+		c.pong(atomic.SwapInt64(&c.hb.pingAt, 0))
+		return nil, nil
+	}
+
+	// websocket api 响应
+	if isResponse {
+		var resp *WSResponse
+		if err := unmarshal(msg, &resp); err != nil {
+			return nil, err
+		}
+		c.reply(resp)
+		return nil, nil
+	}
+
+	if string(event) != "update" {
+		return nil, nil
+	}
+
+	switch string(channel) {
+	case ChannelOrderBook:
+		if c.fixedPoint {
+			ob, err := decodeOrderBookFixed(result)
+			if err == nil {
+				return ob, nil
+			}
+			// 超出定点数精度或范围, 或定点数不支持的写法 (如科学计数法) 的帧回退为 *OrderBook
+			if cause := errors.Cause(err); cause != fixed.ErrRange && cause != fixed.ErrSyntax {
+				err = errors.Wrap(err, string(result))
+				return nil, errors.WithStack(err)
+			}
+		}
+		var dp struct {
+			T            int64                `json:"t"`
+			LastUpdateId int64                `json:"lastUpdateId"`
+			S            string               `json:"s"`
+			Bids         [][2]decimal.Decimal `json:"bids"`
+			Asks         [][2]decimal.Decimal `json:"asks"`
+		}
+		if err := unmarshal(result, &dp); err != nil {
+			return nil, err
+		}
+		return &OrderBook{
+			Pair: dp.S,
+			Asks: dp.Asks,
+			Bids: dp.Bids,
+		}, nil
+	case ChannelTickers:
+		var ticker *Ticker
+		if err := unmarshal(result, &ticker); err != nil {
+			return nil, err
+		}
+		return ticker, nil
+	case ChannelTrades:
+		var trade *Trade
+		if err := unmarshal(result, &trade); err != nil {
+			return nil, err
+		}
+		return trade, nil
+	case ChannelCandlesticks:
+		var candle *Candlestick
+		if err := unmarshal(result, &candle); err != nil {
+			return nil, err
+		}
+		// n 格式为 1m_BTC_USDT
+		if i := strings.Index(candle.Name, "_"); i > 0 {
+			candle.Interval = candle.Name[:i]
+			candle.Pair = candle.Name[i+1:]
+		}
+		return candle, nil
+	case ChannelBookTicker:
+		var ticker *BookTicker
+		if err := unmarshal(result, &ticker); err != nil {
+			return nil, err
+		}
+		return ticker, nil
+	}
+	return nil, nil
+}
+
+func unmarshal(data []byte, v interface{}) error {
+	if err := json.Unmarshal(data, v); err != nil {
+		err = errors.Wrap(err, string(data))
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+func decodeOrderBookFixed(result []byte) (*OrderBookFixed, error) {
+	var (
+		ob  = new(OrderBookFixed)
+		err error
+	)
+	scanErr := jsonscan.Object(result, func(key, value []byte) bool {
+		switch string(key) {
+		case "s":
+			ob.Pair = string(jsonscan.Unquote(value))
+		case "asks":
+			ob.Asks, err = fixed.ParseLevels(value, nil)
+		case "bids":
+			ob.Bids, err = fixed.ParseLevels(value, nil)
+		}
+		return err == nil
+	})
+	if scanErr != nil {
+		return nil, scanErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ob, nil
+}
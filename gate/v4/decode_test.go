@@ -0,0 +1,133 @@
+package gate
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+func loadFrame(t testing.TB, name string) []byte {
+	msg, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func TestWSClient_decode(t *testing.T) {
+	cli := NewWSClient(WSURL, zap.NewNop())
+	frame := loadFrame(t, "order_book.json")
+
+	v, err := cli.decode(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ob := v.(*OrderBook)
+
+	cli.SetFixedPoint(true)
+	v, err = cli.decode(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fp := v.(*OrderBookFixed)
+
+	if fp.Pair != ob.Pair || len(fp.Asks) != len(ob.Asks) || len(fp.Bids) != len(ob.Bids) {
+		t.Fatalf("mismatch: %+v", fp)
+	}
+	for i, level := range ob.Asks {
+		if !fp.Asks[i][0].Decimal().Equal(level[0]) || !fp.Asks[i][1].Decimal().Equal(level[1]) {
+			t.Fatalf("ask %d mismatch", i)
+		}
+	}
+	for i, level := range ob.Bids {
+		if !fp.Bids[i][0].Decimal().Equal(level[0]) || !fp.Bids[i][1].Decimal().Equal(level[1]) {
+			t.Fatalf("bid %d mismatch", i)
+		}
+	}
+
+	// 超出定点数精度的帧回退为 *OrderBook
+	v, err = cli.decode([]byte(`{"time":1700000000,"channel":"spot.order_book","event":"update","result":{"t":1700000000123,"s":"PEPE_USDT","bids":[["0.0000012345678","100"]],"asks":[]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ob, ok := v.(*OrderBook); !ok || ob.Pair != "PEPE_USDT" || !ob.Bids[0][0].Equal(decimal.RequireFromString("0.0000012345678")) {
+		t.Fatalf("unexpected fallback: %+v", v)
+	}
+
+	// 科学计数法同样回退
+	v, err = cli.decode([]byte(`{"time":1700000000,"channel":"spot.order_book","event":"update","result":{"t":1700000000123,"s":"PEPE_USDT","bids":[["1e-8","100"]],"asks":[]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ob, ok := v.(*OrderBook); !ok || !ob.Bids[0][0].Equal(decimal.RequireFromString("0.00000001")) {
+		t.Fatalf("unexpected fallback: %+v", v)
+	}
+
+	v, err = cli.decode(loadFrame(t, "trades.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trade := v.(*Trade); trade.CurrencyPair != "BTC_USDT" || !trade.Price.Equal(decimal.RequireFromString("30000.15")) {
+		t.Fatalf("unexpected trade: %+v", trade)
+	}
+}
+
+func benchmarkDecode(b *testing.B, name string, fixedPoint bool) {
+	cli := NewWSClient(WSURL, zap.NewNop())
+	cli.SetFixedPoint(fixedPoint)
+	frame := loadFrame(b, name)
+
+	b.SetBytes(int64(len(frame)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := cli.decode(frame); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// 改造前的解析方式, 作为对比基准
+func benchmarkDecodeBaseline(b *testing.B, name string) {
+	frame := loadFrame(b, name)
+
+	b.SetBytes(int64(len(frame)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var raw struct {
+			Time      int             `json:"time"`
+			Channel   string          `json:"channel"`
+			Event     string          `json:"event"`
+			Result    json.RawMessage `json:"result"`
+			RequestID string          `json:"request_id"`
+		}
+		if err := json.Unmarshal(frame, &raw); err != nil {
+			b.Fatal(err)
+		}
+		var v interface{}
+		switch raw.Channel {
+		case ChannelOrderBook:
+			v = new(OrderBook)
+		case ChannelTrades:
+			v = new(Trade)
+		}
+		if err := json.Unmarshal(raw.Result, v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecode_OrderBook(b *testing.B) {
+	b.Run("baseline", func(b *testing.B) { benchmarkDecodeBaseline(b, "order_book.json") })
+	b.Run("decimal", func(b *testing.B) { benchmarkDecode(b, "order_book.json", false) })
+	b.Run("fixed", func(b *testing.B) { benchmarkDecode(b, "order_book.json", true) })
+}
+
+func BenchmarkDecode_Trades(b *testing.B) {
+	b.Run("baseline", func(b *testing.B) { benchmarkDecodeBaseline(b, "trades.json") })
+	b.Run("decimal", func(b *testing.B) { benchmarkDecode(b, "trades.json", false) })
+}
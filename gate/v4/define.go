@@ -1,6 +1,8 @@
 package gate
 
 import (
+	"encoding/json"
+
 	"github.com/shopspring/decimal"
 )
 
@@ -25,13 +27,56 @@ const (
 	CandleGroupSecTwoDays        = 172800 // 2天
 	CandleGroupSecOneWeek        = 604800 // 1周
 
-	OrderTypeLimit = "limit"
+	SideBuy  = "buy"
+	SideSell = "sell"
+
+	OrderTypeLimit  = "limit"
+	OrderTypeMarket = "market"
+
+	TimeInForceGTC = "gtc" // 一直有效直至取消
+	TimeInForceIOC = "ioc" // 立即成交或者取消, 只吃单不挂单
+	TimeInForcePOC = "poc" // 被动委托, 只挂单不吃单
+	TimeInForceFOK = "fok" // 全部成交或者全部取消
 
 	OrderStatusOpen      = "open"
 	OrderStatusClosed    = "closed"
 	OrderStatusCancelled = "cancelled"
 
-	AccountSpot = "spot"
+	AccountSpot        = "spot"
+	AccountMargin      = "margin"
+	AccountCrossMargin = "cross_margin"
+	AccountUnified     = "unified"
+
+	STPActCancelNewest = "cn" // 取消新订单
+	STPActCancelOldest = "co" // 取消旧订单
+	STPActCancelBoth   = "cb" // 新旧订单都取消
+	STPActNone         = "-"  // 不使用 STP
+
+	ActionModeACK    = "ACK"    // 只返回关键字段
+	ActionModeResult = "RESULT" // 不返回成交明细
+	ActionModeFull   = "FULL"   // 返回全部字段
+
+	TriggerRuleGTE = ">=" // 市场价格大于等于触发价时触发
+	TriggerRuleLTE = "<=" // 市场价格小于等于触发价时触发
+
+	PriceOrderAccountNormal = "normal" // 价格触发订单的现货账户
+
+	PriceOrderStatusOpen      = "open"      // 等待触发
+	PriceOrderStatusCancelled = "cancelled" // 已撤销
+	PriceOrderStatusFinish    = "finish"    // 已触发并下单
+	PriceOrderStatusFailed    = "failed"    // 触发后下单失败
+	PriceOrderStatusExpired   = "expired"   // 已过期
+
+	RoleMaker = "maker"
+	RoleTaker = "taker"
+)
+
+// 以下类型用于 OrderRequest, 对应的常量为无类型常量, 同时可以用于字符串参数
+type (
+	Side        string
+	OrderType   string
+	TimeInForce string
+	STPAct      string
 )
 
 type Currency struct {
@@ -135,7 +180,7 @@ type Order struct {
 	//- ioc: ImmediateOrCancelled，立即成交或者取消，只吃单不挂单
 	//- poc: PendingOrCancelled，被动委托，只挂单不吃单
 	TimeInForce string `json:"time_in_force"`
-	// 冰山下单显示的数量，不指定或传 0 都默认为普通下单。如果需要全部冰山，设置为 -1
+	// 冰山下单显示的数量，不指定或传 0 都默认为普通下单。现货不支持全部隐藏
 	Iceberg decimal.Decimal `json:"iceberg"`
 	// 交易货币未成交数量
 	Left decimal.Decimal `json:"left"`
@@ -157,6 +202,134 @@ type Order struct {
 	RebatedFee decimal.Decimal `json:"rebated_fee"`
 	// 返还手续费计价单位
 	RebatedFeeCurrency string `json:"rebated_fee_currency"`
+	// 订单所属的 STP 用户组 ID
+	StpID int64 `json:"stp_id"`
+	// 自成交保护策略
+	StpAct string `json:"stp_act"`
+	// 订单结束方式, 如 filled, cancelled, ioc, stp
+	FinishAs string `json:"finish_as"`
+	// 最近一次改单时的自定义信息
+	AmendText string `json:"amend_text"`
+}
+
+// 批量下单中单个订单的结果, 失败时 Succeeded 为 false, Text 为请求中的 text
+type BatchOrderResult struct {
+	Order
+	// 是否下单成功
+	Succeeded bool `json:"succeeded"`
+	// 失败时的错误标识
+	Label string `json:"label"`
+	// 失败时的错误信息
+	Message string `json:"message"`
+}
+
+// 批量撤单中的单个订单
+type CancelOrderItem struct {
+	CurrencyPair string `json:"currency_pair"`
+	// 订单 ID 或者下单时的 text, text 在订单未结束时有效, 订单结束后只短时间内可用
+	ID string `json:"id"`
+	// 账户类型, 为空时为现货账户
+	Account string `json:"account,omitempty"`
+}
+
+// 批量撤单中单个订单的结果
+type BatchCancelResult struct {
+	CurrencyPair string `json:"currency_pair"`
+	ID           string `json:"id"`
+	Text         string `json:"text"`
+	Account      string `json:"account"`
+	// 是否撤单成功
+	Succeeded bool `json:"succeeded"`
+	// 失败时的错误标识
+	Label string `json:"label"`
+	// 失败时的错误信息
+	Message string `json:"message"`
+}
+
+// 价格触发条件
+type PriceTrigger struct {
+	// 触发价格
+	Price decimal.Decimal `json:"price"`
+	// 触发规则, >= 或 <=
+	Rule string `json:"rule"`
+	// 最长等待触发时间, 秒, 超时后订单过期
+	Expiration int64 `json:"expiration"`
+}
+
+// 价格触发后的下单参数
+type PriceOrderPut struct {
+	// limit 或 market
+	Type string `json:"type"`
+	Side string `json:"side"`
+	// 挂单价格, 市价单忽略
+	Price decimal.Decimal `json:"price"`
+	// 交易数量, 市价买单为计价货币金额
+	Amount decimal.Decimal `json:"amount"`
+	// 账户类型, normal 为现货账户
+	Account string `json:"account"`
+	// gtc 或 ioc
+	TimeInForce string `json:"time_in_force"`
+	// 杠杆或全仓杠杆账户余额不足时是否自动借入
+	AutoBorrow bool `json:"auto_borrow"`
+	// 全仓杠杆账户订单结束后是否自动还款
+	AutoRepay bool `json:"auto_repay"`
+	// 订单来源, 如 api, web, app
+	Text string `json:"text"`
+}
+
+// 价格触发订单
+type PriceOrder struct {
+	ID      int64         `json:"id"`
+	User    int64         `json:"user"`
+	Market  string        `json:"market"`
+	Trigger PriceTrigger  `json:"trigger"`
+	Put     PriceOrderPut `json:"put"`
+	// 创建时间, 秒
+	Ctime int64 `json:"ctime"`
+	// 结束时间, 秒
+	Ftime int64 `json:"ftime"`
+	// 触发后下的订单 ID
+	FiredOrderID int64 `json:"fired_order_id"`
+	// 订单状态, 见 PriceOrderStatus
+	Status string `json:"status"`
+	// 结束原因
+	Reason string `json:"reason"`
+}
+
+// 个人成交记录
+type MyTrade struct {
+	// 成交 ID
+	ID string `json:"id"`
+	// 成交时间, 秒级
+	CreateTime int64 `json:"create_time,string"`
+	// 成交时间, 毫秒精度
+	CreateTimeMs decimal.Decimal `json:"create_time_ms"`
+	// 交易对
+	CurrencyPair string `json:"currency_pair"`
+	// 本方订单方向 [buy / sell]
+	Side string `json:"side"`
+	// 成交角色 [maker / taker]
+	Role string `json:"role"`
+	// 成交数量
+	Amount decimal.Decimal `json:"amount"`
+	// 成交价格
+	Price decimal.Decimal `json:"price"`
+	// 关联的订单 ID
+	OrderID string `json:"order_id"`
+	// 成交扣除的手续费
+	Fee decimal.Decimal `json:"fee"`
+	// 手续费计价单位
+	FeeCurrency string `json:"fee_currency"`
+	// 手续费抵扣使用的点卡数量
+	PointFee decimal.Decimal `json:"point_fee"`
+	// 手续费抵扣使用的 GT 数量
+	GtFee decimal.Decimal `json:"gt_fee"`
+	// 订单最近一次改单时的自定义信息
+	AmendText string `json:"amend_text"`
+	// 单个交易对内连续递增的成交序号
+	SequenceID string `json:"sequence_id"`
+	// 订单的自定义信息
+	Text string `json:"text"`
 }
 
 type Address struct {
@@ -202,3 +375,107 @@ type CurrencyChain struct {
 	Decimal string `json:"decimal"`
 	IsTag   int    `json:"is_tag"`
 }
+
+// websocket api 响应
+type WSResponse struct {
+	// 请求 ID, 与请求中的 req_id 对应
+	RequestID string `json:"request_id"`
+	// true 表示下单请求的确认回执, 订单结果在后续响应中返回
+	Ack    bool `json:"ack"`
+	Header struct {
+		ResponseTime string `json:"response_time"`
+		// 状态码, 200 表示成功
+		Status   string `json:"status"`
+		Channel  string `json:"channel"`
+		Event    string `json:"event"`
+		ClientID string `json:"client_id"`
+	} `json:"header"`
+	Data struct {
+		Result json.RawMessage `json:"result"`
+		Errs   *ErrResponse    `json:"errs"`
+	} `json:"data"`
+}
+
+// 行情推送
+type Ticker struct {
+	// 交易对
+	CurrencyPair string `json:"currency_pair"`
+	// 最新成交价
+	Last decimal.Decimal `json:"last"`
+	// 卖一价
+	LowestAsk decimal.Decimal `json:"lowest_ask"`
+	// 买一价
+	HighestBid decimal.Decimal `json:"highest_bid"`
+	// 24 小时涨跌百分比
+	ChangePercentage decimal.Decimal `json:"change_percentage"`
+	// 24 小时交易货币成交量
+	BaseVolume decimal.Decimal `json:"base_volume"`
+	// 24 小时计价货币成交量
+	QuoteVolume decimal.Decimal `json:"quote_volume"`
+	// 24 小时最高价
+	High24h decimal.Decimal `json:"high_24h"`
+	// 24 小时最低价
+	Low24h decimal.Decimal `json:"low_24h"`
+}
+
+// 逐笔成交推送
+type Trade struct {
+	// 成交 ID
+	ID int64 `json:"id"`
+	// 成交时间, 秒级
+	CreateTime int64 `json:"create_time"`
+	// 成交时间, 毫秒精度
+	CreateTimeMs decimal.Decimal `json:"create_time_ms"`
+	// taker 方向 [buy / sell]
+	Side string `json:"side"`
+	// 交易对
+	CurrencyPair string `json:"currency_pair"`
+	// 成交数量
+	Amount decimal.Decimal `json:"amount"`
+	// 成交价格
+	Price decimal.Decimal `json:"price"`
+}
+
+// K 线推送
+type Candlestick struct {
+	// 交易对, 由 Name 解析
+	Pair string `json:"-"`
+	// K 线周期, 由 Name 解析, 如 1m
+	Interval string `json:"-"`
+	// 开始时间, 秒级
+	Time int64 `json:"t,string"`
+	// 计价货币成交额
+	Volume decimal.Decimal `json:"v"`
+	// 收盘价
+	Close decimal.Decimal `json:"c"`
+	// 最高价
+	High decimal.Decimal `json:"h"`
+	// 最低价
+	Low decimal.Decimal `json:"l"`
+	// 开盘价
+	Open decimal.Decimal `json:"o"`
+	// 订阅名称, 格式为 <interval>_<cp>
+	Name string `json:"n"`
+	// 交易货币成交量
+	Amount decimal.Decimal `json:"a"`
+	// 是否已收盘
+	WindowClosed bool `json:"w"`
+}
+
+// 最优挂单推送
+type BookTicker struct {
+	// 时间戳, 毫秒
+	Time int64 `json:"t"`
+	// 深度更新 ID
+	UpdateID int64 `json:"u"`
+	// 交易对
+	Pair string `json:"s"`
+	// 买一价
+	BidPrice decimal.Decimal `json:"b"`
+	// 买一量
+	BidAmount decimal.Decimal `json:"B"`
+	// 卖一价
+	AskPrice decimal.Decimal `json:"a"`
+	// 卖一量
+	AskAmount decimal.Decimal `json:"A"`
+}
@@ -0,0 +1,301 @@
+package gatetest
+
+import (
+	"sort"
+
+	gate "github.com/icwl/go-exchange-api/gate/v4"
+	"github.com/shopspring/decimal"
+)
+
+// 账户
+type account struct {
+	key    string
+	secret string
+	// 币种 -> 余额, Locked 为挂单冻结
+	balances map[string]*gate.Account
+	// 全部订单, 包括已完成订单
+	orders []*order
+}
+
+func (a *account) balance(currency string) *gate.Account {
+	b, ok := a.balances[currency]
+	if !ok {
+		b = &gate.Account{Currency: currency}
+		a.balances[currency] = b
+	}
+	return b
+}
+
+// 按订单 ID 或自定义 text 查找
+func (a *account) find(pair, id string) *order {
+	for _, o := range a.orders {
+		if o.CurrencyPair == pair && (o.ID == id || o.Text != "" && o.Text == id) {
+			return o
+		}
+	}
+	return nil
+}
+
+type order struct {
+	*gate.Order
+	owner *account
+	// 限价买单按委托价冻结的计价货币, 成交价优于委托价时退回差额
+	frozen decimal.Decimal
+}
+
+func (o *order) finished() bool {
+	return o.Status != gate.OrderStatusOpen
+}
+
+// 市价买单的 amount 为计价货币金额
+func (o *order) quoteAmount() bool {
+	return o.Type == gate.OrderTypeMarket && o.Side == "buy"
+}
+
+func (o *order) snapshot() *gate.Order {
+	cp := *o.Order
+	return &cp
+}
+
+// 单个交易对的订单簿, 价格优先时间优先
+type book struct {
+	pair *gate.CurrencyPair
+	// 卖单价格升序, 买单价格降序, 同价格按时间排序
+	asks []*order
+	bids []*order
+	last decimal.Decimal
+	// 订单簿每次变化递增
+	updateID int64
+}
+
+func (b *book) side(side string) *[]*order {
+	if side == "buy" {
+		return &b.bids
+	}
+	return &b.asks
+}
+
+// 与 taker 方向相反的挂单
+func (b *book) opposite(side string) *[]*order {
+	if side == "buy" {
+		return &b.asks
+	}
+	return &b.bids
+}
+
+func crosses(taker *order, maker *order) bool {
+	if taker.Type == gate.OrderTypeMarket {
+		return true
+	}
+	if taker.Side == "buy" {
+		return taker.Price.GreaterThanOrEqual(maker.Price)
+	}
+	return taker.Price.LessThanOrEqual(maker.Price)
+}
+
+func (b *book) insert(o *order) {
+	list := b.side(o.Side)
+	i := sort.Search(len(*list), func(i int) bool {
+		if o.Side == "buy" {
+			return (*list)[i].Price.LessThan(o.Price)
+		}
+		return (*list)[i].Price.GreaterThan(o.Price)
+	})
+	*list = append(*list, nil)
+	copy((*list)[i+1:], (*list)[i:])
+	(*list)[i] = o
+}
+
+func (b *book) remove(o *order) bool {
+	list := b.side(o.Side)
+	for i, item := range *list {
+		if item == o {
+			*list = append((*list)[:i], (*list)[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// 可立即成交的交易货币数量
+func (b *book) available(taker *order) decimal.Decimal {
+	total := decimal.Zero
+	for _, maker := range *b.opposite(taker.Side) {
+		if !crosses(taker, maker) {
+			break
+		}
+		total = total.Add(maker.Left)
+	}
+	return total
+}
+
+// 按价格合并的深度
+func (b *book) levels(list []*order, limit int) [][2]decimal.Decimal {
+	levels := make([][2]decimal.Decimal, 0)
+	for _, o := range list {
+		n := len(levels)
+		if n > 0 && levels[n-1][0].Equal(o.Price) {
+			levels[n-1][1] = levels[n-1][1].Add(o.Left)
+			continue
+		}
+		if limit > 0 && n >= limit {
+			break
+		}
+		levels = append(levels, [2]decimal.Decimal{o.Price, o.Left})
+	}
+	return levels
+}
+
+// 撮合结果, 用于推送
+type result struct {
+	trades []*gate.Trade
+}
+
+// 撮合 taker, 成交价为 maker 价格
+// 市价买单的 Left 为剩余计价货币金额, 不足以买入最小精度时停止撮合
+func (b *book) match(taker *order, res *result, now int64, nextTradeID func() int64) {
+	opposite := b.opposite(taker.Side)
+
+	for len(*opposite) > 0 && taker.Left.IsPositive() {
+		maker := (*opposite)[0]
+		if !crosses(taker, maker) {
+			break
+		}
+
+		price := maker.Price
+		qty := maker.Left
+		if taker.quoteAmount() {
+			qty = decimal.Min(qty, taker.Left.Div(price).Truncate(b.pair.AmountPrecision))
+			if !qty.IsPositive() {
+				break
+			}
+		} else {
+			qty = decimal.Min(qty, taker.Left)
+		}
+
+		buyer, seller := taker, maker
+		if taker.Side == "sell" {
+			buyer, seller = maker, taker
+		}
+		b.fill(buyer, seller, qty, price, now)
+
+		b.last = price
+		res.trades = append(res.trades, &gate.Trade{
+			ID:           nextTradeID(),
+			CreateTime:   now / 1000,
+			CreateTimeMs: decimal.NewFromInt(now),
+			Side:         taker.Side,
+			CurrencyPair: b.pair.ID,
+			Amount:       qty,
+			Price:        price,
+		})
+
+		if maker.Left.IsZero() {
+			maker.Status = gate.OrderStatusClosed
+			*opposite = (*opposite)[1:]
+		}
+	}
+}
+
+// 结算一笔成交, 买方手续费扣交易货币, 卖方手续费扣计价货币
+// 费率为交易对的 fee, 单位为百分之一
+func (b *book) fill(buyer, seller *order, qty, price decimal.Decimal, now int64) {
+	base, quote := b.pair.Base, b.pair.Quote
+	value := qty.Mul(price)
+	rate := b.pair.Fee.Div(decimal.NewFromInt(100))
+
+	// 买方: 扣除计价货币, 收到交易货币减手续费
+	bq := buyer.owner.balance(quote)
+	if buyer.Type == gate.OrderTypeMarket {
+		bq.Available = bq.Available.Sub(value)
+	} else {
+		reserved := qty.Mul(buyer.Price)
+		bq.Locked = bq.Locked.Sub(reserved)
+		buyer.frozen = buyer.frozen.Sub(reserved)
+		bq.Available = bq.Available.Add(reserved.Sub(value))
+	}
+	buyFee := qty.Mul(rate)
+	bb := buyer.owner.balance(base)
+	bb.Available = bb.Available.Add(qty.Sub(buyFee))
+	buyer.Fee = buyer.Fee.Add(buyFee)
+
+	// 卖方: 扣除冻结的交易货币, 收到计价货币减手续费
+	sb := seller.owner.balance(base)
+	if seller.Type == gate.OrderTypeMarket {
+		sb.Available = sb.Available.Sub(qty)
+	} else {
+		sb.Locked = sb.Locked.Sub(qty)
+	}
+	sellFee := value.Mul(rate)
+	sq := seller.owner.balance(quote)
+	sq.Available = sq.Available.Add(value.Sub(sellFee))
+	seller.Fee = seller.Fee.Add(sellFee)
+
+	for _, o := range []*order{buyer, seller} {
+		if o.quoteAmount() {
+			o.Left = o.Left.Sub(value)
+		} else {
+			o.Left = o.Left.Sub(qty)
+		}
+		o.FilledTotal = o.FilledTotal.Add(value)
+		o.FillPrice = o.FilledTotal
+		o.UpdateTime = now / 1000
+		o.UpdateTimeMs = now
+	}
+}
+
+// 撤单并退回冻结余额
+func (b *book) cancel(o *order, now int64) {
+	b.remove(o)
+	b.release(o)
+	o.Status = gate.OrderStatusCancelled
+	o.UpdateTime = now / 1000
+	o.UpdateTimeMs = now
+}
+
+func (b *book) release(o *order) {
+	if o.Type == gate.OrderTypeMarket {
+		return
+	}
+	if o.Side == "buy" {
+		q := o.owner.balance(b.pair.Quote)
+		q.Locked = q.Locked.Sub(o.frozen)
+		q.Available = q.Available.Add(o.frozen)
+		o.frozen = decimal.Zero
+		return
+	}
+	base := o.owner.balance(b.pair.Base)
+	base.Locked = base.Locked.Sub(o.Left)
+	base.Available = base.Available.Add(o.Left)
+}
+
+// 冻结限价单余额, 市价单按成交逐笔扣除
+func (b *book) lock(o *order) error {
+	switch {
+	case o.Type == gate.OrderTypeMarket:
+		currency := b.pair.Base
+		if o.Side == "buy" {
+			currency = b.pair.Quote
+		}
+		if o.owner.balance(currency).Available.LessThan(o.Left) {
+			return newAPIError(LabelBalanceNotEnough, "balance not enough")
+		}
+	case o.Side == "buy":
+		q := o.owner.balance(b.pair.Quote)
+		cost := o.Left.Mul(o.Price)
+		if q.Available.LessThan(cost) {
+			return newAPIError(LabelBalanceNotEnough, "balance not enough")
+		}
+		q.Available = q.Available.Sub(cost)
+		q.Locked = q.Locked.Add(cost)
+		o.frozen = cost
+	default:
+		base := o.owner.balance(b.pair.Base)
+		if base.Available.LessThan(o.Left) {
+			return newAPIError(LabelBalanceNotEnough, "balance not enough")
+		}
+		base.Available = base.Available.Sub(o.Left)
+		base.Locked = base.Locked.Add(o.Left)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package gatetest
+
+import (
+	"time"
+)
+
+// 故障注入配置, 受 Server.lock 保护
+type faults struct {
+	// 每个 REST 响应和 websocket 消息发送前的延迟
+	latency time.Duration
+	// 剩余需要返回 429 的 REST 请求数
+	rateLimited int
+	// 剩余需要返回截断 json 的 REST 响应和 websocket 消息数
+	malformed int
+}
+
+// 设置每个 REST 响应和 websocket 消息的延迟, 0 表示不延迟
+func (s *Server) SetLatency(latency time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.faults.latency = latency
+}
+
+// 接下来 n 个 REST 请求返回 429 TOO_MANY_REQUESTS, 不执行请求内容
+func (s *Server) RateLimitNext(n int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.faults.rateLimited = n
+}
+
+// 接下来 n 个 REST 响应或 websocket 消息只发送前半部分 json
+func (s *Server) MalformNext(n int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.faults.malformed = n
+}
+
+// 不发送 close 帧直接断开全部 websocket 连接, 返回断开的连接数
+func (s *Server) DropConnections() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	n := 0
+	for sess := range s.sessions {
+		_ = sess.conn.UnderlyingConn().Close()
+		sess.close()
+		delete(s.sessions, sess)
+		n++
+	}
+	return n
+}
+
+func (s *Server) latency() time.Duration {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.faults.latency
+}
+
+// 消耗一次 429 注入
+func (s *Server) rateLimited() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.faults.rateLimited <= 0 {
+		return false
+	}
+	s.faults.rateLimited--
+	return true
+}
+
+// 按注入配置截断消息, 调用方持有 s.lock
+func (s *Server) malform(msg []byte) []byte {
+	if s.faults.malformed <= 0 {
+		return msg
+	}
+	s.faults.malformed--
+	return msg[:len(msg)/2]
+}
@@ -0,0 +1,425 @@
+package gatetest
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	gate "github.com/icwl/go-exchange-api/gate/v4"
+	"github.com/shopspring/decimal"
+)
+
+// - id 路径中的订单 ID, 其他接口为空
+type handler func(a *account, id string, query url.Values, body map[string]interface{}) (interface{}, error)
+
+type route struct {
+	auth   bool
+	handle handler
+	// 成功时的状态码, 默认 200
+	status int
+}
+
+// 订单 ID 在路径中的接口
+const ordersPrefix = "/api/v4/spot/orders/"
+
+func (s *Server) routes() map[string]route {
+	return map[string]route{
+		"GET /api/v4/spot/currencies":        {false, s.handleCurrencies, 0},
+		"GET /api/v4/spot/currency_pairs":    {false, s.handleCurrencyPairs, 0},
+		"GET /api/v4/spot/order_book":        {false, s.handleOrderBook, 0},
+		"GET /api/v4/spot/accounts":          {true, s.handleAccounts, 0},
+		"GET /api/v4/spot/open_orders":       {true, s.handleOpenOrders, 0},
+		"POST /api/v4/spot/orders":           {true, s.handleNewOrder, http.StatusCreated},
+		"GET /api/v4/spot/orders":            {true, s.handleListOrders, 0},
+		"GET " + ordersPrefix + "{id}":       {true, s.handleGetOrder, 0},
+		"DELETE " + ordersPrefix + "{id}":    {true, s.handleCancelOrder, 0},
+		"PATCH " + ordersPrefix + "{id}":     {true, s.handleAmendOrder, 0},
+		"GET /api/v4/wallet/deposit_address": {true, s.handleDepositAddress, 0},
+		"GET /api/v4/wallet/currency_chains": {false, s.handleCurrencyChains, 0},
+		"POST /api/v4/withdrawals":           {true, s.handleWithdrawal, 0},
+	}
+}
+
+func (s *Server) serveREST(w http.ResponseWriter, r *http.Request) {
+	if d := s.latency(); d > 0 {
+		time.Sleep(d)
+	}
+	if s.rateLimited() {
+		s.writeReply(w, 0, nil, newAPIError(LabelTooManyRequests, "Request Rate limit Exceeded"))
+		return
+	}
+
+	path, id := r.URL.Path, ""
+	if strings.HasPrefix(path, ordersPrefix) {
+		path, id = ordersPrefix+"{id}", strings.TrimPrefix(path, ordersPrefix)
+	}
+	rt, ok := s.routes()[r.Method+" "+path]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	reqBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var a *account
+	if rt.auth {
+		a, err = s.authenticate(r, reqBody)
+		if err != nil {
+			s.writeReply(w, 0, nil, err)
+			return
+		}
+	}
+
+	body := make(map[string]interface{})
+	if len(reqBody) > 0 {
+		d := json.NewDecoder(strings.NewReader(string(reqBody)))
+		d.UseNumber()
+		if err := d.Decode(&body); err != nil {
+			s.writeReply(w, 0, nil, newAPIError(LabelInvalidParam, "invalid json body"))
+			return
+		}
+	}
+
+	data, err := rt.handle(a, id, r.URL.Query(), stringParams(body))
+	s.writeReply(w, rt.status, data, err)
+}
+
+// 校验签名, 与 gate.Sign 一致, 时间戳与服务器时间相差不能超过 60 秒
+func (s *Server) authenticate(r *http.Request, body []byte) (*account, error) {
+	a, ok := s.account(r.Header.Get("KEY"))
+	if !ok {
+		return nil, newAPIError(LabelInvalidKey, "invalid key")
+	}
+	timestamp := r.Header.Get("Timestamp")
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || math.Abs(float64(time.Now().Unix()-ts)) > 60 {
+		return nil, newAPIError(LabelRequestExpired, "request timestamp expired")
+	}
+	want := gate.Sign(r.Method, r.URL.Path, r.URL.RawQuery, body, timestamp, a.secret)
+	if r.Header.Get("SIGN") != want {
+		return nil, newAPIError(LabelInvalidSignature, "signature mismatch")
+	}
+	return a, nil
+}
+
+// 成功时直接返回 data, 失败时返回 label 和 message
+func (s *Server) writeReply(w http.ResponseWriter, status int, data interface{}, err error) {
+	if status == 0 {
+		status = http.StatusOK
+	}
+	var v interface{} = data
+	if err != nil {
+		e, ok := err.(*apiError)
+		if !ok {
+			e = &apiError{status: http.StatusInternalServerError, label: "SERVER_ERROR", message: err.Error()}
+		}
+		status = e.status
+		v = map[string]string{"label": e.label, "message": e.message}
+	}
+	msg, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	s.lock.Lock()
+	msg = s.malform(msg)
+	s.lock.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(msg)
+}
+
+// 分页参数, 默认第 1 页, 每页 100 条
+func paging(query url.Values) (page, limit int) {
+	page, _ = strconv.Atoi(query.Get("page"))
+	limit, _ = strconv.Atoi(query.Get("limit"))
+	if page <= 0 {
+		page = 1
+	}
+	if limit <= 0 {
+		limit = 100
+	}
+	return page, limit
+}
+
+func pageOf(list []*gate.Order, page, limit int) []*gate.Order {
+	start := (page - 1) * limit
+	if start > len(list) {
+		start = len(list)
+	}
+	end := start + limit
+	if end > len(list) {
+		end = len(list)
+	}
+	return list[start:end]
+}
+
+// 全部交易对涉及的币种
+func (s *Server) currencies() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	set := make(map[string]bool)
+	for _, b := range s.books {
+		set[b.pair.Base] = true
+		set[b.pair.Quote] = true
+	}
+	list := make([]string, 0, len(set))
+	for currency := range set {
+		list = append(list, currency)
+	}
+	sort.Strings(list)
+	return list
+}
+
+func (s *Server) handleCurrencies(_ *account, _ string, _ url.Values, _ map[string]interface{}) (interface{}, error) {
+	list := make([]*gate.Currency, 0)
+	for _, currency := range s.currencies() {
+		list = append(list, &gate.Currency{Currency: currency, Chain: currency})
+	}
+	return list, nil
+}
+
+func (s *Server) handleCurrencyPairs(_ *account, _ string, _ url.Values, _ map[string]interface{}) (interface{}, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	pairs := make([]*gate.CurrencyPair, 0, len(s.books))
+	for _, b := range s.books {
+		p := *b.pair
+		pairs = append(pairs, &p)
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].ID < pairs[j].ID })
+	return pairs, nil
+}
+
+func (s *Server) handleOrderBook(_ *account, _ string, query url.Values, _ map[string]interface{}) (interface{}, error) {
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	if limit <= 0 {
+		limit = 10
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	b, ok := s.books[query.Get("currency_pair")]
+	if !ok {
+		return nil, newAPIError(LabelInvalidPair, "invalid currency pair")
+	}
+	now := s.now()
+	return map[string]interface{}{
+		"id":      b.updateID,
+		"current": now,
+		"update":  now,
+		"asks":    b.levels(b.asks, limit),
+		"bids":    b.levels(b.bids, limit),
+	}, nil
+}
+
+func (s *Server) handleAccounts(a *account, _ string, query url.Values, _ map[string]interface{}) (interface{}, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	list := make([]*gate.Account, 0, len(a.balances))
+	for _, b := range a.balances {
+		if c := query.Get("currency"); c != "" && b.Currency != c {
+			continue
+		}
+		cp := *b
+		list = append(list, &cp)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Currency < list[j].Currency })
+	return list, nil
+}
+
+// 按交易对分组的挂单, 交易对按名称排序, 挂单按创建时间倒序
+func (s *Server) handleOpenOrders(a *account, _ string, query url.Values, _ map[string]interface{}) (interface{}, error) {
+	page, limit := paging(query)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	groups := make(map[string][]*gate.Order)
+	for i := len(a.orders) - 1; i >= 0; i-- {
+		if o := a.orders[i]; !o.finished() {
+			groups[o.CurrencyPair] = append(groups[o.CurrencyPair], o.snapshot())
+		}
+	}
+	pairs := make([]string, 0, len(groups))
+	for pair := range groups {
+		pairs = append(pairs, pair)
+	}
+	sort.Strings(pairs)
+
+	type group struct {
+		CurrencyPair string        `json:"currency_pair"`
+		Total        int           `json:"total"`
+		Orders       []*gate.Order `json:"orders"`
+	}
+	list := make([]*group, 0, len(pairs))
+	for _, pair := range pairs {
+		orders := groups[pair]
+		list = append(list, &group{CurrencyPair: pair, Total: len(orders), Orders: pageOf(orders, page, limit)})
+	}
+	return list, nil
+}
+
+func (s *Server) handleNewOrder(a *account, _ string, _ url.Values, body map[string]interface{}) (interface{}, error) {
+	return s.place(a, body)
+}
+
+// 按状态查询单个交易对的订单, 按创建时间倒序分页
+// - status open / finished
+func (s *Server) handleListOrders(a *account, _ string, query url.Values, _ map[string]interface{}) (interface{}, error) {
+	status := query.Get("status")
+	if status != "open" && status != "finished" {
+		return nil, newAPIError(LabelInvalidParam, "invalid status")
+	}
+	if side := query.Get("side"); side != "" && side != "buy" && side != "sell" {
+		return nil, newAPIError(LabelInvalidParam, "invalid side")
+	}
+	page, limit := paging(query)
+	pair := query.Get("currency_pair")
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, ok := s.books[pair]; !ok {
+		return nil, newAPIError(LabelInvalidPair, "invalid currency pair")
+	}
+	list := make([]*gate.Order, 0)
+	for i := len(a.orders) - 1; i >= 0; i-- {
+		o := a.orders[i]
+		if o.CurrencyPair != pair || o.finished() != (status == "finished") {
+			continue
+		}
+		if side := query.Get("side"); side != "" && o.Side != side {
+			continue
+		}
+		list = append(list, o.snapshot())
+	}
+	return pageOf(list, page, limit), nil
+}
+
+func (s *Server) handleGetOrder(a *account, id string, query url.Values, _ map[string]interface{}) (interface{}, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	o := a.find(query.Get("currency_pair"), id)
+	if o == nil {
+		return nil, newAPIError(LabelOrderNotFound, "order not found")
+	}
+	return o.snapshot(), nil
+}
+
+func (s *Server) handleCancelOrder(a *account, id string, query url.Values, _ map[string]interface{}) (interface{}, error) {
+	return s.cancel(a, query.Get("currency_pair"), id)
+}
+
+func (s *Server) handleAmendOrder(a *account, id string, query url.Values, body map[string]interface{}) (interface{}, error) {
+	pair := stringParam(body, "currency_pair")
+	if pair == "" {
+		pair = query.Get("currency_pair")
+	}
+	return s.amend(a, pair, id, stringParam(body, "amount"), stringParam(body, "price"), stringParam(body, "amend_text"))
+}
+
+func (s *Server) knownCurrency(currency string) bool {
+	for _, c := range s.currencies() {
+		if c == currency {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) handleDepositAddress(a *account, _ string, query url.Values, _ map[string]interface{}) (interface{}, error) {
+	currency := query.Get("currency")
+	if !s.knownCurrency(currency) {
+		return nil, newAPIError(LabelInvalidCurrency, "invalid currency")
+	}
+	addr := &gate.Address{
+		Currency: currency,
+		Address:  "fake-" + strings.ToLower(currency) + "-" + a.key,
+	}
+	addr.MultiChainAddresses = append(addr.MultiChainAddresses, struct {
+		Chain        string `json:"chain"`
+		Address      string `json:"address"`
+		PaymentId    string `json:"payment_id"`
+		PaymentName  string `json:"payment_name"`
+		ObtainFailed int    `json:"obtain_failed"`
+	}{Chain: currency, Address: addr.Address})
+	return addr, nil
+}
+
+func (s *Server) handleCurrencyChains(_ *account, _ string, query url.Values, _ map[string]interface{}) (interface{}, error) {
+	currency := query.Get("currency")
+	if !s.knownCurrency(currency) {
+		return nil, newAPIError(LabelInvalidCurrency, "invalid currency")
+	}
+	return []*gate.CurrencyChain{{
+		Chain:   currency,
+		NameCn:  currency,
+		NameEn:  currency,
+		Decimal: "8",
+	}}, nil
+}
+
+// 提现直接扣除可用余额
+func (s *Server) handleWithdrawal(a *account, _ string, _ url.Values, body map[string]interface{}) (interface{}, error) {
+	amount, err := decimal.NewFromString(stringParam(body, "amount"))
+	if err != nil || !amount.IsPositive() {
+		return nil, newAPIError(LabelInvalidParam, "invalid amount")
+	}
+	currency := stringParam(body, "currency")
+	if !s.knownCurrency(currency) {
+		return nil, newAPIError(LabelInvalidCurrency, "invalid currency")
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	b := a.balance(currency)
+	if b.Available.LessThan(amount) {
+		return nil, newAPIError(LabelBalanceNotEnough, "balance not enough")
+	}
+	b.Available = b.Available.Sub(amount)
+
+	s.orderID++
+	return &gate.Withdrawal{
+		ID:        "w" + strconv.FormatInt(s.orderID, 10),
+		Timestamp: s.now() / 1000,
+		Currency:  currency,
+		Address:   stringParam(body, "address"),
+		Amount:    amount,
+		Memo:      stringParam(body, "memo"),
+		Status:    "REQUEST",
+		Chain:     stringParam(body, "chain"),
+	}, nil
+}
+
+// 数字参数转为字符串
+func stringParams(body map[string]interface{}) map[string]interface{} {
+	params := make(map[string]interface{}, len(body))
+	for k, v := range body {
+		switch v := v.(type) {
+		case json.Number:
+			params[k] = v.String()
+		case string:
+			params[k] = v
+		}
+	}
+	return params
+}
@@ -0,0 +1,481 @@
+// 本地模拟的 Gate v4 交易所, 用于集成测试
+//
+// REST 接口校验 HMAC-SHA512 SIGN 签名, 维护账户余额和简单的撮合引擎
+// websocket 接口支持 spot.order_book, spot.trades, spot.tickers, spot.book_ticker 推送和下单相关的 websocket api
+// 订单 ID、成交 ID 按顺序生成, 时间可由 SetClock 固定, 便于断言
+// 支持注入延迟、429 限频、断开连接和错误的 json, 用于测试重试和重连逻辑
+package gatetest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	gate "github.com/icwl/go-exchange-api/gate/v4"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+// 错误标签
+const (
+	LabelInvalidParam       = "INVALID_PARAM_VALUE"
+	LabelInvalidKey         = "INVALID_KEY"
+	LabelInvalidSignature   = "INVALID_SIGNATURE"
+	LabelRequestExpired     = "REQUEST_EXPIRED"
+	LabelInvalidCurrency    = "INVALID_CURRENCY"
+	LabelInvalidPair        = "INVALID_CURRENCY_PAIR"
+	LabelInvalidPrecision   = "INVALID_PRECISION"
+	LabelBalanceNotEnough   = "BALANCE_NOT_ENOUGH"
+	LabelPOCFillImmediately = "POC_FILL_IMMEDIATELY"
+	LabelOrderNotFound      = "ORDER_NOT_FOUND"
+	LabelOrderClosed        = "ORDER_CLOSED"
+	LabelTooManyRequests    = "TOO_MANY_REQUESTS"
+)
+
+type Server struct {
+	srv *httptest.Server
+
+	lock     *sync.Mutex
+	clock    func() time.Time
+	accounts map[string]*account
+	books    map[string]*book
+	orderID  int64
+	tradeID  int64
+
+	sessions map[*session]bool
+	faults   faults
+}
+
+// 启动服务, 默认包含 BTC_USDT 和 ETH_USDT 交易对
+func NewServer() *Server {
+	s := &Server{
+		lock:     new(sync.Mutex),
+		clock:    time.Now,
+		accounts: make(map[string]*account),
+		books:    make(map[string]*book),
+		orderID:  100000000000,
+		tradeID:  1000000000,
+		sessions: make(map[*session]bool),
+	}
+	for _, p := range []*gate.CurrencyPair{
+		{
+			ID:              "BTC_USDT",
+			Base:            "BTC",
+			Quote:           "USDT",
+			Fee:             decimal.RequireFromString("0.2"),
+			MinBaseAmount:   decimal.RequireFromString("0.0001"),
+			MinQuoteAmount:  decimal.RequireFromString("1"),
+			AmountPrecision: 4,
+			Precision:       2,
+			TradeStatus:     "tradable",
+		},
+		{
+			ID:              "ETH_USDT",
+			Base:            "ETH",
+			Quote:           "USDT",
+			Fee:             decimal.RequireFromString("0.2"),
+			MinBaseAmount:   decimal.RequireFromString("0.001"),
+			MinQuoteAmount:  decimal.RequireFromString("1"),
+			AmountPrecision: 4,
+			Precision:       2,
+			TradeStatus:     "tradable",
+		},
+	} {
+		s.AddCurrencyPair(p)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws/v4/", s.serveWS)
+	mux.HandleFunc("/api/v4/", s.serveREST)
+	s.srv = httptest.NewServer(mux)
+	return s
+}
+
+func (s *Server) Close() {
+	s.lock.Lock()
+	for sess := range s.sessions {
+		sess.close()
+	}
+	s.lock.Unlock()
+	s.srv.Close()
+}
+
+// REST 地址, 用于 gate.NewHTTPClient
+func (s *Server) URL() string {
+	return s.srv.URL
+}
+
+// websocket 地址, 用于 gate.NewWSClient
+func (s *Server) WSURL() string {
+	return "ws" + strings.TrimPrefix(s.srv.URL, "http")
+}
+
+// 设置订单和成交时间的时钟, 默认 time.Now
+func (s *Server) SetClock(clock func() time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.clock = clock
+}
+
+// 当前毫秒时间, 调用方持有 s.lock
+func (s *Server) now() int64 {
+	return s.clock().UnixMilli()
+}
+
+// 添加或替换交易对
+func (s *Server) AddCurrencyPair(p *gate.CurrencyPair) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	cp := *p
+	if b, ok := s.books[p.ID]; ok {
+		b.pair = &cp
+		return
+	}
+	s.books[p.ID] = &book{pair: &cp}
+}
+
+// 添加 api 账户
+func (s *Server) AddAccount(key, secret string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.accounts[key] = &account{
+		key:      key,
+		secret:   secret,
+		balances: make(map[string]*gate.Account),
+	}
+}
+
+// 设置可用余额
+func (s *Server) SetBalance(key, currency string, available decimal.Decimal) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	a, ok := s.accounts[key]
+	if !ok {
+		return errors.Errorf("account %s not found", key)
+	}
+	a.balance(currency).Available = available
+	return nil
+}
+
+// 账户余额
+func (s *Server) Balance(key, currency string) (*gate.Account, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	a, ok := s.accounts[key]
+	if !ok {
+		return nil, errors.Errorf("account %s not found", key)
+	}
+	b := *a.balance(currency)
+	return &b, nil
+}
+
+// 直接下单, 不经过签名校验, 用于准备订单簿
+func (s *Server) PlaceOrder(key, pair, side, amount, price string) (*gate.Order, error) {
+	a, ok := s.account(key)
+	if !ok {
+		return nil, errors.Errorf("account %s not found", key)
+	}
+	return s.place(a, map[string]interface{}{
+		"currency_pair": pair,
+		"side":          side,
+		"amount":        amount,
+		"price":         price,
+	})
+}
+
+// 订单簿
+func (s *Server) OrderBook(pair string, limit int) (*gate.OrderBook, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	b, ok := s.books[pair]
+	if !ok {
+		return nil, errors.Errorf("currency pair %s not found", pair)
+	}
+	return &gate.OrderBook{
+		Pair: pair,
+		Asks: b.levels(b.asks, limit),
+		Bids: b.levels(b.bids, limit),
+	}, nil
+}
+
+func (s *Server) account(key string) (*account, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	a, ok := s.accounts[key]
+	return a, ok
+}
+
+// 接口错误, 以 label 和 message 返回
+type apiError struct {
+	status  int
+	label   string
+	message string
+}
+
+func (e *apiError) Error() string {
+	return e.label + ": " + e.message
+}
+
+func newAPIError(label, message string) error {
+	status := http.StatusBadRequest
+	switch label {
+	case LabelInvalidKey, LabelInvalidSignature, LabelRequestExpired:
+		status = http.StatusUnauthorized
+	case LabelOrderNotFound:
+		status = http.StatusNotFound
+	case LabelTooManyRequests:
+		status = http.StatusTooManyRequests
+	}
+	return &apiError{status: status, label: label, message: message}
+}
+
+// 小数位数不超过精度
+func precise(d decimal.Decimal, precision int32) bool {
+	return d.Equal(d.Truncate(precision))
+}
+
+// 下单, 撮合后推送订单簿和成交
+func (s *Server) place(a *account, params map[string]interface{}) (*gate.Order, error) {
+	var (
+		pair    = stringParam(params, "currency_pair")
+		side    = stringParam(params, "side")
+		type_   = stringParam(params, "type")
+		account = stringParam(params, "account")
+		tif     = stringParam(params, "time_in_force")
+		text    = stringParam(params, "text")
+	)
+	if type_ == "" {
+		type_ = gate.OrderTypeLimit
+	}
+	if account == "" {
+		account = gate.AccountSpot
+	}
+	if tif == "" && type_ == gate.OrderTypeMarket {
+		tif = gate.TimeInForceIOC
+	} else if tif == "" {
+		tif = gate.TimeInForceGTC
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	b, ok := s.books[pair]
+	if !ok {
+		return nil, newAPIError(LabelInvalidPair, "invalid currency pair")
+	}
+	if side != "buy" && side != "sell" {
+		return nil, newAPIError(LabelInvalidParam, "invalid side")
+	}
+	if account != gate.AccountSpot {
+		return nil, newAPIError(LabelInvalidParam, "invalid account")
+	}
+	if text != "" && !strings.HasPrefix(text, "t-") {
+		return nil, newAPIError(LabelInvalidParam, "text must start with t-")
+	}
+	switch {
+	case type_ == gate.OrderTypeLimit && tif != gate.TimeInForceGTC && tif != gate.TimeInForceIOC &&
+		tif != gate.TimeInForcePOC && tif != gate.TimeInForceFOK:
+		return nil, newAPIError(LabelInvalidParam, "invalid time_in_force")
+	case type_ == gate.OrderTypeMarket && tif != gate.TimeInForceIOC && tif != gate.TimeInForceFOK:
+		return nil, newAPIError(LabelInvalidParam, "market order requires ioc or fok")
+	case type_ != gate.OrderTypeLimit && type_ != gate.OrderTypeMarket:
+		return nil, newAPIError(LabelInvalidParam, "invalid type")
+	}
+
+	o := &order{
+		Order: &gate.Order{
+			Text:         text,
+			CurrencyPair: pair,
+			Type:         type_,
+			Account:      account,
+			Side:         side,
+			TimeInForce:  tif,
+			FeeCurrency:  b.pair.Quote,
+		},
+		owner: a,
+	}
+	if side == "buy" {
+		o.FeeCurrency = b.pair.Base
+	}
+	if err := s.parseAmount(b, o, stringParam(params, "amount"), stringParam(params, "price")); err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	s.orderID++
+	o.ID = strconv.FormatInt(s.orderID, 10)
+	o.CreateTime, o.CreateTimeMs = now/1000, now
+	o.UpdateTime, o.UpdateTimeMs = now/1000, now
+	o.Status = gate.OrderStatusOpen
+
+	if err := b.lock(o); err != nil {
+		return nil, err
+	}
+	if err := s.execute(b, o); err != nil {
+		return nil, err
+	}
+	a.orders = append(a.orders, o)
+	return o.snapshot(), nil
+}
+
+// 校验数量和价格, 市价单不需要价格
+func (s *Server) parseAmount(b *book, o *order, amount, price string) error {
+	var err error
+	o.Amount, err = decimal.NewFromString(amount)
+	if err != nil || !o.Amount.IsPositive() {
+		return newAPIError(LabelInvalidParam, "invalid amount")
+	}
+	if o.Type == gate.OrderTypeLimit {
+		o.Price, err = decimal.NewFromString(price)
+		if err != nil || !o.Price.IsPositive() {
+			return newAPIError(LabelInvalidParam, "invalid price")
+		}
+		if !precise(o.Price, b.pair.Precision) {
+			return newAPIError(LabelInvalidPrecision, "invalid price precision")
+		}
+	}
+	if o.quoteAmount() {
+		if !precise(o.Amount, b.pair.Precision) {
+			return newAPIError(LabelInvalidPrecision, "invalid amount precision")
+		}
+		if o.Amount.LessThan(b.pair.MinQuoteAmount) {
+			return newAPIError(LabelInvalidParam, "order amount too small")
+		}
+	} else {
+		if !precise(o.Amount, b.pair.AmountPrecision) {
+			return newAPIError(LabelInvalidPrecision, "invalid amount precision")
+		}
+		if o.Amount.LessThan(b.pair.MinBaseAmount) ||
+			o.Type == gate.OrderTypeLimit && o.Amount.Mul(o.Price).LessThan(b.pair.MinQuoteAmount) {
+			return newAPIError(LabelInvalidParam, "order amount too small")
+		}
+	}
+	o.Left = o.Amount
+	return nil
+}
+
+// 按 time_in_force 撮合已冻结余额的订单, 未成交部分挂单或撤销
+func (s *Server) execute(b *book, o *order) error {
+	now := s.now()
+	res := new(result)
+	switch {
+	case o.TimeInForce == gate.TimeInForcePOC && b.available(o).IsPositive():
+		b.release(o)
+		return newAPIError(LabelPOCFillImmediately, "order would be filled immediately")
+	case o.TimeInForce == gate.TimeInForceFOK && !o.quoteAmount() && b.available(o).LessThan(o.Left):
+		// 无法全部成交, 直接撤销
+	default:
+		b.match(o, res, now, func() int64 {
+			s.tradeID++
+			return s.tradeID
+		})
+	}
+
+	switch {
+	case o.Left.IsZero():
+		o.Status = gate.OrderStatusClosed
+		b.release(o)
+	case o.TimeInForce == gate.TimeInForceGTC || o.TimeInForce == gate.TimeInForcePOC:
+		b.insert(o)
+	case o.quoteAmount() && o.FilledTotal.IsPositive():
+		// 市价买单剩余金额不足以买入最小数量
+		o.Status = gate.OrderStatusClosed
+	default:
+		b.cancel(o, now)
+	}
+
+	s.publish(b, res)
+	return nil
+}
+
+func (s *Server) cancel(a *account, pair, id string) (*gate.Order, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	b, ok := s.books[pair]
+	if !ok {
+		return nil, newAPIError(LabelInvalidPair, "invalid currency pair")
+	}
+	o := a.find(pair, id)
+	if o == nil {
+		return nil, newAPIError(LabelOrderNotFound, "order not found")
+	}
+	if o.finished() {
+		return nil, newAPIError(LabelOrderClosed, "order finished")
+	}
+
+	b.cancel(o, s.now())
+	s.publish(b, new(result))
+	return o.snapshot(), nil
+}
+
+// 修改挂单的数量或价格, 改价后重新排队并按新价格撮合
+func (s *Server) amend(a *account, pair, id, amount, price, amendText string) (*gate.Order, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	b, ok := s.books[pair]
+	if !ok {
+		return nil, newAPIError(LabelInvalidPair, "invalid currency pair")
+	}
+	o := a.find(pair, id)
+	if o == nil {
+		return nil, newAPIError(LabelOrderNotFound, "order not found")
+	}
+	if o.finished() {
+		return nil, newAPIError(LabelOrderClosed, "order finished")
+	}
+	if amount == "" && price == "" {
+		return nil, newAPIError(LabelInvalidParam, "amount or price required")
+	}
+
+	prev := *o.Order
+	if amount == "" {
+		amount = o.Amount.String()
+	}
+	if price == "" {
+		price = o.Price.String()
+	}
+	filled := o.Amount.Sub(o.Left)
+	b.remove(o)
+	b.release(o)
+	restore := func() {
+		*o.Order = prev
+		_ = b.lock(o)
+		b.insert(o)
+	}
+	if err := s.parseAmount(b, o, amount, price); err != nil {
+		restore()
+		return nil, err
+	}
+	if !o.Amount.GreaterThan(filled) {
+		restore()
+		return nil, newAPIError(LabelInvalidParam, "amount less than filled")
+	}
+	o.Left = o.Amount.Sub(filled)
+	if err := b.lock(o); err != nil {
+		restore()
+		return nil, err
+	}
+	o.AmendText = amendText
+	now := s.now()
+	o.UpdateTime, o.UpdateTimeMs = now/1000, now
+	if err := s.execute(b, o); err != nil {
+		restore()
+		return nil, err
+	}
+	return o.snapshot(), nil
+}
+
+func stringParam(params map[string]interface{}, key string) string {
+	v, _ := params[key].(string)
+	return v
+}
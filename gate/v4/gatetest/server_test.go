@@ -0,0 +1,205 @@
+package gatetest
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	gate "github.com/icwl/go-exchange-api/gate/v4"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+func d(s string) decimal.Decimal {
+	return decimal.RequireFromString(s)
+}
+
+func TestServer_OrderLifecycle(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	srv.SetClock(func() time.Time { return time.UnixMilli(1700000000123) })
+
+	logger := zap.NewExample()
+	srv.AddAccount("maker", "maker-secret")
+	srv.AddAccount("taker", "taker-secret")
+	_ = srv.SetBalance("maker", "BTC", d("1"))
+	_ = srv.SetBalance("taker", "USDT", d("100000"))
+
+	maker := gate.NewHTTPClient(srv.URL(), "maker", "maker-secret", logger)
+	taker := gate.NewHTTPClient(srv.URL(), "taker", "taker-secret", logger)
+
+	ask, err := maker.NewOrder("t-ask", "BTC_USDT", gate.OrderTypeLimit, gate.AccountSpot, "sell", "1", "30000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ask.ID != "100000000001" || ask.CreateTimeMs != 1700000000123 {
+		t.Fatalf("unexpected order: %+v", ask)
+	}
+
+	ws := gate.NewWSClient(srv.WSURL(), logger)
+	if err := ws.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	events := make(chan interface{}, 16)
+	go func() {
+		for {
+			msg, err := ws.Read()
+			if err != nil {
+				return
+			}
+			if msg != nil {
+				events <- msg
+			}
+		}
+	}()
+	if err := ws.SubOrderBook("BTC_USDT", "10", "100ms"); err != nil {
+		t.Fatal(err)
+	}
+
+	next := func(match func(*gate.OrderBook) bool) {
+		t.Helper()
+		timeout := time.After(2 * time.Second)
+		for {
+			select {
+			case msg := <-events:
+				if ob, ok := msg.(*gate.OrderBook); ok && match(ob) {
+					return
+				}
+			case <-timeout:
+				t.Fatal("push not received")
+			}
+		}
+	}
+	next(func(ob *gate.OrderBook) bool {
+		return len(ob.Asks) == 1 && ob.Asks[0][1].Equal(d("1"))
+	})
+
+	// websocket api 下单, 以 maker 价格成交
+	if err := ws.Login("taker", "taker-secret"); err != nil {
+		t.Fatal(err)
+	}
+	bid, err := ws.NewOrder("t-bid", "BTC_USDT", gate.OrderTypeLimit, gate.AccountSpot, "buy", "0.4", "30100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bid.Status != gate.OrderStatusClosed || !bid.FilledTotal.Equal(d("12000")) || !bid.Fee.Equal(d("0.0008")) {
+		t.Fatalf("unexpected order: %+v", bid)
+	}
+	next(func(ob *gate.OrderBook) bool {
+		return len(ob.Asks) == 1 && ob.Asks[0][1].Equal(d("0.6"))
+	})
+
+	accounts, err := taker.Accounts("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range accounts {
+		want := map[string]string{"BTC": "0.3992", "USDT": "88000"}[a.Currency]
+		if !a.Available.Equal(d(want)) || !a.Locked.IsZero() {
+			t.Fatalf("unexpected balance: %+v", a)
+		}
+	}
+
+	// 按 text 撤销剩余挂单, 退回冻结余额
+	cancelled, err := maker.CancelOrder("t-ask", "BTC_USDT", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cancelled.Status != gate.OrderStatusCancelled || !cancelled.Left.Equal(d("0.6")) {
+		t.Fatalf("unexpected order: %+v", cancelled)
+	}
+	btc, _ := srv.Balance("maker", "BTC")
+	usdt, _ := srv.Balance("maker", "USDT")
+	if !btc.Available.Equal(d("0.6")) || !btc.Locked.IsZero() || !usdt.Available.Equal(d("11976")) {
+		t.Fatalf("unexpected maker balance: %+v %+v", btc, usdt)
+	}
+}
+
+func TestServer_Reject(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	logger := zap.NewExample()
+	srv.AddAccount("key", "secret")
+	_ = srv.SetBalance("key", "USDT", d("100"))
+	_ = srv.SetBalance("key", "BTC", d("1"))
+	if _, err := srv.PlaceOrder("key", "BTC_USDT", "buy", "0.001", "30000"); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		cli   *gate.HTTPClient
+		side  string
+		price string
+		label string
+	}{
+		{gate.NewHTTPClient(srv.URL(), "key", "wrong", logger), "buy", "30000", LabelInvalidSignature},
+		{gate.NewHTTPClient(srv.URL(), "key", "secret", logger), "buy", "30000", LabelBalanceNotEnough},
+		{gate.NewHTTPClient(srv.URL(), "key", "secret", logger), "sell", "30000.001", LabelInvalidPrecision},
+	}
+	for _, c := range cases {
+		_, err := c.cli.NewOrder("", "BTC_USDT", gate.OrderTypeLimit, "", c.side, "1", c.price)
+		var e *gate.ErrResponse
+		if !errors.As(err, &e) || e.Label != c.label {
+			t.Fatalf("got %v, want label %s", err, c.label)
+		}
+	}
+	if b, _ := srv.Balance("key", "BTC"); !b.Available.Equal(d("1")) {
+		t.Fatalf("balance not released: %+v", b)
+	}
+}
+
+func TestServer_Faults(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	logger := zap.NewExample()
+	cli := gate.NewHTTPClient(srv.URL(), "", "", logger)
+
+	// 429 只影响接下来的请求
+	srv.RateLimitNext(1)
+	_, err := cli.CurrencyPairs()
+	var e *gate.ErrResponse
+	if !errors.As(err, &e) || e.Label != LabelTooManyRequests {
+		t.Fatalf("got %v, want rate limited", err)
+	}
+	if _, err := cli.CurrencyPairs(); err != nil {
+		t.Fatal(err)
+	}
+
+	srv.MalformNext(1)
+	_, err = cli.CurrencyPairs()
+	var body gate.ErrResponseBody
+	if !errors.As(err, &body) {
+		t.Fatalf("got %v, want malformed body", err)
+	}
+
+	// 延迟超过客户端超时
+	srv.SetLatency(200 * time.Millisecond)
+	cli.SetHTTPClient(&http.Client{Timeout: 50 * time.Millisecond})
+	if _, err := cli.CurrencyPairs(); err == nil {
+		t.Fatal("want timeout")
+	}
+	srv.SetLatency(0)
+
+	// 断开连接后 Read 返回错误
+	ws := gate.NewWSClient(srv.WSURL(), logger)
+	if err := ws.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	if err := ws.SubTrades([]string{"BTC_USDT"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ws.Read(); err != nil {
+		t.Fatal(err)
+	}
+	if n := srv.DropConnections(); n != 1 {
+		t.Fatalf("dropped %d connections, want 1", n)
+	}
+	if _, err := ws.Read(); err == nil {
+		t.Fatal("want read error after drop")
+	}
+}
@@ -0,0 +1,328 @@
+package gatetest
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+	gate "github.com/icwl/go-exchange-api/gate/v4"
+)
+
+// websocket 连接
+type session struct {
+	conn *websocket.Conn
+	send chan []byte
+
+	// 以下字段受 Server.lock 保护
+	closed  bool
+	account *account
+	// 频道 -> 交易对 -> 订单簿档位, 其他频道档位为 0
+	subs map[string]map[string]int
+}
+
+func (sess *session) close() {
+	if !sess.closed {
+		sess.closed = true
+		close(sess.send)
+	}
+}
+
+func (sess *session) subscribed(channel, pair string) (int, bool) {
+	level, ok := sess.subs[channel][pair]
+	return level, ok
+}
+
+// 推送消息, 发送队列已满时断开连接, 调用方持有 Server.lock
+func (s *Server) push(sess *session, v interface{}) {
+	if sess.closed {
+		return
+	}
+	msg, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	select {
+	case sess.send <- s.malform(msg):
+	default:
+		sess.close()
+	}
+}
+
+func (s *Server) writeLoop(sess *session) {
+	for msg := range sess.send {
+		if d := s.latency(); d > 0 {
+			time.Sleep(d)
+		}
+		if err := sess.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+			break
+		}
+	}
+	_ = sess.conn.Close()
+}
+
+type wsRequest struct {
+	Time    int64           `json:"time"`
+	Channel string          `json:"channel"`
+	Event   string          `json:"event"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+var upgrader = websocket.Upgrader{}
+
+func (s *Server) serveWS(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	sess := &session{
+		conn: conn,
+		send: make(chan []byte, 1024),
+		subs: make(map[string]map[string]int),
+	}
+	s.lock.Lock()
+	s.sessions[sess] = true
+	s.lock.Unlock()
+
+	go s.writeLoop(sess)
+	defer func() {
+		s.lock.Lock()
+		delete(s.sessions, sess)
+		sess.close()
+		s.lock.Unlock()
+	}()
+
+	for {
+		var req *wsRequest
+		if err := conn.ReadJSON(&req); err != nil {
+			return
+		}
+		s.handleWS(sess, req)
+	}
+}
+
+// 频道推送, 调用方持有 s.lock
+func (s *Server) channelMessage(channel, event string, result interface{}) map[string]interface{} {
+	now := s.now()
+	return map[string]interface{}{
+		"time":    now / 1000,
+		"time_ms": now,
+		"channel": channel,
+		"event":   event,
+		"result":  result,
+	}
+}
+
+func (s *Server) handleWS(sess *session, req *wsRequest) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	switch req.Event {
+	case "subscribe", "unsubscribe":
+		var payload []string
+		if err := json.Unmarshal(req.Payload, &payload); err != nil || len(payload) == 0 {
+			msg := s.channelMessage(req.Channel, req.Event, nil)
+			msg["error"] = map[string]interface{}{"code": 2, "message": "invalid payload"}
+			s.push(sess, msg)
+			return
+		}
+		if req.Event == "subscribe" {
+			s.subscribe(sess, req.Channel, payload)
+		} else {
+			s.unsubscribe(sess, req.Channel, payload)
+		}
+		s.push(sess, s.channelMessage(req.Channel, req.Event, map[string]interface{}{"status": "success"}))
+		if req.Event == "subscribe" && req.Channel == gate.ChannelOrderBook {
+			if b, ok := s.books[payload[0]]; ok {
+				s.push(sess, s.orderBookMessage(b, sess.subs[gate.ChannelOrderBook][payload[0]]))
+			}
+		}
+	case "api":
+		s.handleAPI(sess, req)
+	default:
+		if req.Channel == "spot.ping" {
+			s.push(sess, s.channelMessage("spot.pong", "", nil))
+		}
+	}
+}
+
+// 订阅频道, payload 格式同 gate.Subscription
+func (s *Server) subscribe(sess *session, channel string, payload []string) {
+	pairs, ok := sess.subs[channel]
+	if !ok {
+		pairs = make(map[string]int)
+		sess.subs[channel] = pairs
+	}
+	switch channel {
+	case gate.ChannelOrderBook:
+		level, _ := strconv.Atoi(payload[1%len(payload)])
+		pairs[payload[0]] = level
+	case gate.ChannelCandlesticks:
+		// 接受订阅, 不推送 K 线
+		pairs[payload[len(payload)-1]] = 0
+	default:
+		for _, pair := range payload {
+			pairs[pair] = 0
+		}
+	}
+}
+
+func (s *Server) unsubscribe(sess *session, channel string, payload []string) {
+	switch channel {
+	case gate.ChannelOrderBook:
+		delete(sess.subs[channel], payload[0])
+	case gate.ChannelCandlesticks:
+		delete(sess.subs[channel], payload[len(payload)-1])
+	default:
+		for _, pair := range payload {
+			delete(sess.subs[channel], pair)
+		}
+	}
+}
+
+type apiPayload struct {
+	ReqID     string                 `json:"req_id"`
+	APIKey    string                 `json:"api_key"`
+	Signature string                 `json:"signature"`
+	Timestamp string                 `json:"timestamp"`
+	ReqParam  map[string]interface{} `json:"req_param"`
+}
+
+// websocket api, 下单先返回确认回执再返回订单
+func (s *Server) handleAPI(sess *session, req *wsRequest) {
+	var payload apiPayload
+	_ = json.Unmarshal(req.Payload, &payload)
+	params := stringParams(payload.ReqParam)
+
+	reply := func(ack bool, result interface{}, err error) {
+		status := "200"
+		data := map[string]interface{}{"result": result}
+		if err != nil {
+			e, _ := err.(*apiError)
+			status = strconv.Itoa(e.status)
+			data = map[string]interface{}{"errs": map[string]string{"label": e.label, "message": e.message}}
+		}
+		s.push(sess, map[string]interface{}{
+			"request_id": payload.ReqID,
+			"ack":        ack,
+			"header": map[string]interface{}{
+				"response_time": strconv.FormatInt(s.now(), 10),
+				"status":        status,
+				"channel":       req.Channel,
+				"event":         "api",
+			},
+			"data": data,
+		})
+	}
+
+	if req.Channel == "spot.login" {
+		a, ok := s.accounts[payload.APIKey]
+		if !ok {
+			reply(false, nil, newAPIError(LabelInvalidKey, "invalid key"))
+			return
+		}
+		if gate.WSSign(req.Channel, "", payload.Timestamp, a.secret) != payload.Signature {
+			reply(false, nil, newAPIError(LabelInvalidSignature, "signature mismatch"))
+			return
+		}
+		sess.account = a
+		reply(false, map[string]interface{}{"api_key": a.key, "uid": a.key}, nil)
+		return
+	}
+	if sess.account == nil {
+		reply(false, nil, newAPIError(LabelInvalidKey, "login required"))
+		return
+	}
+
+	// place, cancel 和 amend 需要释放 s.lock
+	var (
+		a     = sess.account
+		order *gate.Order
+		err   error
+	)
+	s.lock.Unlock()
+	switch req.Channel {
+	case "spot.order_place":
+		order, err = s.place(a, params)
+	case "spot.order_cancel":
+		order, err = s.cancel(a, stringParam(params, "currency_pair"), stringParam(params, "order_id"))
+	case "spot.order_amend":
+		order, err = s.amend(a, stringParam(params, "currency_pair"), stringParam(params, "order_id"),
+			stringParam(params, "amount"), stringParam(params, "price"), stringParam(params, "amend_text"))
+	case "spot.order_status":
+		s.lock.Lock()
+		if o := a.find(stringParam(params, "currency_pair"), stringParam(params, "order_id")); o != nil {
+			order = o.snapshot()
+		} else {
+			err = newAPIError(LabelOrderNotFound, "order not found")
+		}
+		s.lock.Unlock()
+	default:
+		err = newAPIError(LabelInvalidParam, "channel not supported")
+	}
+	s.lock.Lock()
+
+	if req.Channel == "spot.order_place" {
+		reply(true, map[string]interface{}{"req_id": payload.ReqID}, nil)
+	}
+	if err != nil {
+		reply(false, nil, err)
+		return
+	}
+	reply(false, order, nil)
+}
+
+func (s *Server) orderBookMessage(b *book, level int) map[string]interface{} {
+	return s.channelMessage(gate.ChannelOrderBook, "update", map[string]interface{}{
+		"t":            s.now(),
+		"lastUpdateId": b.updateID,
+		"s":            b.pair.ID,
+		"bids":         b.levels(b.bids, level),
+		"asks":         b.levels(b.asks, level),
+	})
+}
+
+// 推送撮合结果, 调用方持有 s.lock
+func (s *Server) publish(b *book, res *result) {
+	b.updateID++
+	pair := b.pair.ID
+
+	var bid, ask [2]interface{}
+	if len(b.bids) > 0 {
+		bid = [2]interface{}{b.bids[0].Price, b.bids[0].Left}
+	}
+	if len(b.asks) > 0 {
+		ask = [2]interface{}{b.asks[0].Price, b.asks[0].Left}
+	}
+
+	for sess := range s.sessions {
+		if level, ok := sess.subscribed(gate.ChannelOrderBook, pair); ok {
+			s.push(sess, s.orderBookMessage(b, level))
+		}
+		if _, ok := sess.subscribed(gate.ChannelBookTicker, pair); ok {
+			s.push(sess, s.channelMessage(gate.ChannelBookTicker, "update", map[string]interface{}{
+				"t": s.now(), "u": b.updateID, "s": pair,
+				"b": bid[0], "B": bid[1], "a": ask[0], "A": ask[1],
+			}))
+		}
+		if len(res.trades) == 0 {
+			continue
+		}
+		if _, ok := sess.subscribed(gate.ChannelTrades, pair); ok {
+			for _, trade := range res.trades {
+				s.push(sess, s.channelMessage(gate.ChannelTrades, "update", trade))
+			}
+		}
+		if _, ok := sess.subscribed(gate.ChannelTickers, pair); ok {
+			ticker := &gate.Ticker{CurrencyPair: pair, Last: b.last}
+			if len(b.bids) > 0 {
+				ticker.HighestBid = b.bids[0].Price
+			}
+			if len(b.asks) > 0 {
+				ticker.LowestAsk = b.asks[0].Price
+			}
+			s.push(sess, s.channelMessage(gate.ChannelTickers, "update", ticker))
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package gate
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+func TestWSClient_Latency(t *testing.T) {
+	srv := newTestWSServer(t, func(req *testWSRequest) []interface{} {
+		if req.Channel != "spot.ping" {
+			return nil
+		}
+		return []interface{}{map[string]interface{}{
+			"time":    time.Now().Unix(),
+			"channel": "spot.pong",
+			"event":   "",
+			"result":  nil,
+		}}
+	})
+	defer srv.Close()
+
+	logger := zap.NewExample()
+	cli := NewWSClient("ws"+strings.TrimPrefix(srv.URL, "http"), logger)
+	if err := cli.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+
+	go cli.Ping(20 * time.Millisecond)
+	go func() {
+		for {
+			if _, err := cli.Read(); err != nil {
+				return
+			}
+		}
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for cli.Latency().Samples < 3 {
+		if time.Now().After(deadline) {
+			t.Fatal("no pong received")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	l := cli.Latency()
+	if l.Min > l.Max || l.Last <= 0 {
+		t.Fatalf("unexpected latency: %+v", l)
+	}
+	t.Logf("延迟 : %+v", l)
+}
+
+func TestWSClient_Stale(t *testing.T) {
+	var (
+		conns int32
+		subs  = make(chan string, 4)
+	)
+
+	// 第一个连接不回复任何 ping, 模拟连接假死
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		n := atomic.AddInt32(&conns, 1)
+		if n == 1 {
+			conn.SetPingHandler(func(string) error { return nil })
+		}
+		for {
+			var req *testWSRequest
+			if err := conn.ReadJSON(&req); err != nil {
+				return
+			}
+			if req.Event == "subscribe" {
+				subs <- req.Channel + " " + string(req.Payload)
+			}
+		}
+	}))
+	defer srv.Close()
+
+	logger := zap.NewExample()
+	cli := NewWSClient("ws"+strings.TrimPrefix(srv.URL, "http"), logger)
+	cli.SetPongTimeout(200 * time.Millisecond)
+	if err := cli.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+
+	if err := cli.SubOrderBook("BTC_USDT", "10", "100ms"); err != nil {
+		t.Fatal(err)
+	}
+	<-subs
+
+	go cli.Ping(50 * time.Millisecond)
+
+	msg, err := cli.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	event, ok := msg.(*StaleEvent)
+	if !ok {
+		t.Fatalf("got %T, want *StaleEvent", msg)
+	}
+	t.Logf("连接失效 : %+v", event)
+
+	// 重连后恢复订阅
+	select {
+	case got := <-subs:
+		if got != `spot.order_book ["BTC_USDT","10","100ms"]` {
+			t.Fatalf("unexpected resubscribe: %s", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("subscription not restored")
+	}
+	if n := atomic.LoadInt32(&conns); n != 2 {
+		t.Fatalf("got %d connections, want 2", n)
+	}
+}
+
+func TestWSClient_CloseAfterStale(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+
+	cli := NewWSClient("ws"+strings.TrimPrefix(srv.URL, "http"), zap.NewNop())
+	if err := cli.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	go cli.Ping(10 * time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+
+	// 失效后未重连时 Close 仍然停止心跳, 重复 Close 不报错
+	cli.markStale("test")
+	for i := 0; i < 2; i++ {
+		if err := cli.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := cli.Read(); err == nil {
+		t.Fatal("expected error after close")
+	}
+}
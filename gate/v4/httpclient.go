@@ -21,6 +21,8 @@ type HTTPClient struct {
 	secret string
 	cli    *http.Client
 	logger *zap.Logger
+	// 设置后 NewOrder 发送前先在本地校验
+	validator *Validator
 }
 
 func NewHTTPClient(url, key, secret string, logger *zap.Logger) *HTTPClient {
@@ -33,7 +35,25 @@ func NewHTTPClient(url, key, secret string, logger *zap.Logger) *HTTPClient {
 	}
 }
 
+// 替换默认的 http.Client, 如设置代理, 超时或录制回放
+func (c *HTTPClient) SetHTTPClient(cli *http.Client) {
+	c.cli = cli
+}
+
+// 设置下单前的本地校验, 校验不通过时 NewOrder, PlaceOrder 和 BatchOrders 返回 *ValidationError 而不发送请求, 为 nil 时关闭
+func (c *HTTPClient) SetValidator(v *Validator) {
+	c.validator = v
+}
+
 func (c *HTTPClient) Request(method, path string, query url.Values, body map[string]interface{}, auth bool) ([]byte, error) {
+	if body == nil {
+		return c.request(method, path, query, nil, auth)
+	}
+	return c.request(method, path, query, body, auth)
+}
+
+// body 为任意可以 json 序列化的值, 批量接口的请求体为数组
+func (c *HTTPClient) request(method, path string, query url.Values, body interface{}, auth bool) ([]byte, error) {
 	var (
 		rawQuery = query.Encode()
 		reqBody  []byte
@@ -205,6 +225,15 @@ func (c *HTTPClient) Accounts(currency string) ([]*Account, error) {
 
 // 查询所有挂单
 func (c *HTTPClient) OpenOrders(page, limit int, account string) ([]*Order, error) {
+	orders, _, err := c.openOrders(page, limit, account)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return orders, nil
+}
+
+// 分页按交易对进行, 任一交易对还有剩余挂单时返回 true
+func (c *HTTPClient) openOrders(page, limit int, account string) ([]*Order, bool, error) {
 	method := http.MethodGet
 	path := "/api/v4/spot/open_orders"
 	query := url.Values{}
@@ -220,7 +249,7 @@ func (c *HTTPClient) OpenOrders(page, limit int, account string) ([]*Order, erro
 	respBody, err := c.Request(method, path, query, nil, true)
 	if err != nil {
 		c.logger.Error(path, zap.Error(err))
-		return nil, errors.WithStack(err)
+		return nil, false, errors.WithStack(err)
 	}
 
 	var reply []struct {
@@ -231,19 +260,29 @@ func (c *HTTPClient) OpenOrders(page, limit int, account string) ([]*Order, erro
 	if err := json.Unmarshal(respBody, &reply); err != nil {
 		c.logger.Error(path, zap.String("reply", string(respBody)), zap.Error(err))
 		err := ErrResponseBody(respBody)
-		return nil, errors.WithStack(err)
+		return nil, false, errors.WithStack(err)
 	}
 
 	orders := make([]*Order, 0, len(reply))
+	hasNext := false
 	for _, item := range reply {
 		orders = append(orders, item.Orders...)
+		if page > 0 && limit > 0 && page*limit < item.Total {
+			hasNext = true
+		}
 	}
-	return orders, nil
+	return orders, hasNext, nil
 }
 
 func (c *HTTPClient) NewOrder(text, pair, type_, account, side, amount, price string) (*Order, error) {
 	method := http.MethodPost
 	path := "/api/v4/spot/orders"
+	if c.validator != nil {
+		if err := c.validator.validateArgs(text, pair, type_, account, side, amount, price); err != nil {
+			c.logger.Error(method+" "+path, zap.Error(err))
+			return nil, errors.WithStack(err)
+		}
+	}
 	body := make(map[string]interface{})
 	if text != "" {
 		body["text"] = text
@@ -275,6 +314,154 @@ func (c *HTTPClient) NewOrder(text, pair, type_, account, side, amount, price st
 	return reply, nil
 }
 
+// 使用 OrderRequest 下单, 先检查参数格式, 设置了 Validator 时再按交易对规则校验
+func (c *HTTPClient) PlaceOrder(req *OrderRequest) (*Order, error) {
+	method := http.MethodPost
+	path := "/api/v4/spot/orders"
+	if err := req.Validate(); err != nil {
+		c.logger.Error(method+" "+path, zap.Error(err))
+		return nil, errors.WithStack(err)
+	}
+	if c.validator != nil {
+		if err := c.validator.ValidateRequest(req); err != nil {
+			c.logger.Error(method+" "+path, zap.Error(err))
+			return nil, errors.WithStack(err)
+		}
+	}
+
+	respBody, err := c.Request(method, path, nil, req.body(), true)
+	if err != nil {
+		c.logger.Error(method+" "+path, zap.Error(err))
+		return nil, errors.WithStack(err)
+	}
+
+	var reply *Order
+	if err := json.Unmarshal(respBody, &reply); err != nil {
+		c.logger.Error(method+" "+path, zap.String("reply", string(respBody)), zap.Error(err))
+		err := ErrResponseBody(respBody)
+		return nil, errors.WithStack(err)
+	}
+
+	return reply, nil
+}
+
+const (
+	// 每次批量下单的最大订单数
+	batchOrderLimit = 10
+	// 每次批量撤单的最大订单数
+	batchCancelLimit = 20
+)
+
+// 失败时返回对应的 *ErrResponse
+func (r *BatchOrderResult) Err() error {
+	if r.Succeeded {
+		return nil
+	}
+	return &ErrResponse{Label: r.Label, Message: r.Message}
+}
+
+// 失败时返回对应的 *ErrResponse
+func (r *BatchCancelResult) Err() error {
+	if r.Succeeded {
+		return nil
+	}
+	return &ErrResponse{Label: r.Label, Message: r.Message}
+}
+
+// 批量下单, 结果与请求一一对应, 单个订单失败不影响其它订单
+// 每个订单必须设置 text, 用于对账; 超过 10 个时分多次请求, 中途请求失败时返回已完成部分的结果和错误
+// 设置了 Validator 时逐个校验, 余额按单个订单检查, 不累计同一批次的其它订单
+func (c *HTTPClient) BatchOrders(reqs []*OrderRequest) ([]*BatchOrderResult, error) {
+	method := http.MethodPost
+	path := "/api/v4/spot/batch_orders"
+
+	var list []Violation
+	for i, req := range reqs {
+		prefix := "orders[" + strconv.Itoa(i) + "]."
+		if req.Text == "" {
+			list = append(list, Violation{Field: prefix + "text", Reason: ReasonInvalidText, Message: "text is required in batch orders"})
+		}
+		err := req.Validate()
+		if err == nil && c.validator != nil {
+			err = c.validator.ValidateRequest(req)
+		}
+		var e *ValidationError
+		if errors.As(err, &e) {
+			for _, v := range e.Violations {
+				v.Field = prefix + v.Field
+				list = append(list, v)
+			}
+		} else if err != nil {
+			c.logger.Error(method+" "+path, zap.Error(err))
+			return nil, errors.WithStack(err)
+		}
+	}
+	if len(list) > 0 {
+		err := &ValidationError{Violations: list}
+		c.logger.Error(method+" "+path, zap.Error(err))
+		return nil, errors.WithStack(err)
+	}
+
+	results := make([]*BatchOrderResult, 0, len(reqs))
+	for start := 0; start < len(reqs); start += batchOrderLimit {
+		end := start + batchOrderLimit
+		if end > len(reqs) {
+			end = len(reqs)
+		}
+		body := make([]map[string]interface{}, 0, end-start)
+		for _, req := range reqs[start:end] {
+			body = append(body, req.body())
+		}
+
+		respBody, err := c.request(method, path, nil, body, true)
+		if err != nil {
+			c.logger.Error(method+" "+path, zap.Error(err))
+			return results, errors.WithStack(err)
+		}
+
+		var reply []*BatchOrderResult
+		if err := json.Unmarshal(respBody, &reply); err != nil {
+			c.logger.Error(method+" "+path, zap.String("reply", string(respBody)), zap.Error(err))
+			err := ErrResponseBody(respBody)
+			return results, errors.WithStack(err)
+		}
+		results = append(results, reply...)
+	}
+
+	return results, nil
+}
+
+// 批量撤单, 结果与请求一一对应, 单个订单失败不影响其它订单
+// 超过 20 个时分多次请求, 中途请求失败时返回已完成部分的结果和错误
+func (c *HTTPClient) CancelBatchOrders(items []*CancelOrderItem) ([]*BatchCancelResult, error) {
+	method := http.MethodPost
+	path := "/api/v4/spot/cancel_batch_orders"
+
+	results := make([]*BatchCancelResult, 0, len(items))
+	for start := 0; start < len(items); start += batchCancelLimit {
+		end := start + batchCancelLimit
+		if end > len(items) {
+			end = len(items)
+		}
+
+		respBody, err := c.request(method, path, nil, items[start:end], true)
+		if err != nil {
+			c.logger.Error(method+" "+path, zap.Error(err))
+			return results, errors.WithStack(err)
+		}
+
+		var reply []*BatchCancelResult
+		if err := json.Unmarshal(respBody, &reply); err != nil {
+			c.logger.Error(method+" "+path, zap.String("reply", string(respBody)), zap.Error(err))
+			err := ErrResponseBody(respBody)
+			return results, errors.WithStack(err)
+		}
+		results = append(results, reply...)
+	}
+
+	return results, nil
+}
+
 func (c *HTTPClient) CancelOrder(orderId, pair, account string) (*Order, error) {
 	method := http.MethodDelete
 	path := fmt.Sprintf("/api/v4/spot/orders/%s", orderId)
@@ -300,6 +487,74 @@ func (c *HTTPClient) CancelOrder(orderId, pair, account string) (*Order, error)
 	return reply, nil
 }
 
+// 按下单时的 text 撤单, text 在订单未结束时有效, 订单结束后只短时间内可用
+func (c *HTTPClient) CancelOrderByText(text, pair, account string) (*Order, error) {
+	return c.CancelOrder(url.PathEscape(text), pair, account)
+}
+
+// 撤销交易对的全部挂单, pair 为空时撤销全部交易对, side 为空时撤销买卖双方
+// 返回每个订单的撤单结果, Succeeded 为 true 的订单已撤销
+func (c *HTTPClient) CancelOrders(pair, side, account string) ([]*BatchOrderResult, error) {
+	method := http.MethodDelete
+	path := "/api/v4/spot/orders"
+	query := url.Values{}
+	if pair != "" {
+		query.Add("currency_pair", pair)
+	}
+	if side != "" {
+		query.Add("side", side)
+	}
+	if account != "" {
+		query.Add("account", account)
+	}
+
+	respBody, err := c.Request(method, path, query, nil, true)
+	if err != nil {
+		c.logger.Error(method+" "+path, zap.Error(err))
+		return nil, errors.WithStack(err)
+	}
+
+	var reply []*BatchOrderResult
+	if err := json.Unmarshal(respBody, &reply); err != nil {
+		c.logger.Error(method+" "+path, zap.String("reply", string(respBody)), zap.Error(err))
+		err := ErrResponseBody(respBody)
+		return nil, errors.WithStack(err)
+	}
+
+	return reply, nil
+}
+
+// 修改挂单的数量或价格, 返回修改后的订单
+// 修改价格或增加数量会失去原有的排队位置
+func (c *HTTPClient) AmendOrder(req *AmendOrderRequest) (*Order, error) {
+	method := http.MethodPatch
+	path := fmt.Sprintf("/api/v4/spot/orders/%s", url.PathEscape(req.ID))
+	if err := req.Validate(); err != nil {
+		c.logger.Error(method+" "+path, zap.Error(err))
+		return nil, errors.WithStack(err)
+	}
+	query := url.Values{}
+	query.Add("currency_pair", req.CurrencyPair)
+	if req.Account != "" {
+		query.Add("account", req.Account)
+	}
+
+	respBody, err := c.Request(method, path, query, req.body(), true)
+	if err != nil {
+		c.logger.Error(method+" "+path, zap.Error(err))
+		return nil, errors.WithStack(err)
+	}
+
+	var reply *Order
+	if err := json.Unmarshal(respBody, &reply); err != nil {
+		c.logger.Error(method+" "+path, zap.String("reply", string(respBody)), zap.Error(err))
+		err := ErrResponseBody(respBody)
+		return nil, errors.WithStack(err)
+	}
+
+	return reply, nil
+}
+
 func (c *HTTPClient) GetOrder(orderId, pair, account string) (*Order, error) {
 	method := http.MethodGet
 	path := fmt.Sprintf("/api/v4/spot/orders/%s", orderId)
@@ -324,6 +579,159 @@ func (c *HTTPClient) GetOrder(orderId, pair, account string) (*Order, error) {
 	return reply, nil
 }
 
+// 查询已结束的订单, 包括已成交和已撤销的订单, 按结束时间倒序
+func (c *HTTPClient) FinishedOrders(q *OrderQuery) ([]*Order, error) {
+	method := http.MethodGet
+	path := "/api/v4/spot/orders"
+	query := q.values()
+	query.Add("status", "finished")
+
+	respBody, err := c.Request(method, path, query, nil, true)
+	if err != nil {
+		c.logger.Error(method+" "+path, zap.Error(err))
+		return nil, errors.WithStack(err)
+	}
+
+	var reply []*Order
+	if err := json.Unmarshal(respBody, &reply); err != nil {
+		c.logger.Error(method+" "+path, zap.String("reply", string(respBody)), zap.Error(err))
+		err := ErrResponseBody(respBody)
+		return nil, errors.WithStack(err)
+	}
+
+	return reply, nil
+}
+
+// 查询个人成交记录, 每笔成交包含手续费和成交角色
+func (c *HTTPClient) MyTrades(q *TradeQuery) ([]*MyTrade, error) {
+	method := http.MethodGet
+	path := "/api/v4/spot/my_trades"
+
+	respBody, err := c.Request(method, path, q.values(), nil, true)
+	if err != nil {
+		c.logger.Error(method+" "+path, zap.Error(err))
+		return nil, errors.WithStack(err)
+	}
+
+	var reply []*MyTrade
+	if err := json.Unmarshal(respBody, &reply); err != nil {
+		c.logger.Error(method+" "+path, zap.String("reply", string(respBody)), zap.Error(err))
+		err := ErrResponseBody(respBody)
+		return nil, errors.WithStack(err)
+	}
+
+	return reply, nil
+}
+
+// 创建价格触发订单, 返回触发订单 ID
+func (c *HTTPClient) PlacePriceOrder(req *PriceOrderRequest) (int64, error) {
+	method := http.MethodPost
+	path := "/api/v4/spot/price_orders"
+	if err := req.Validate(); err != nil {
+		c.logger.Error(method+" "+path, zap.Error(err))
+		return 0, errors.WithStack(err)
+	}
+
+	respBody, err := c.Request(method, path, nil, req.body(), true)
+	if err != nil {
+		c.logger.Error(method+" "+path, zap.Error(err))
+		return 0, errors.WithStack(err)
+	}
+
+	var reply struct {
+		ID int64 `json:"id"`
+	}
+	if err := json.Unmarshal(respBody, &reply); err != nil {
+		c.logger.Error(method+" "+path, zap.String("reply", string(respBody)), zap.Error(err))
+		err := ErrResponseBody(respBody)
+		return 0, errors.WithStack(err)
+	}
+
+	return reply.ID, nil
+}
+
+// 查询价格触发订单列表, status 为 open 或 finished, market 和 account 为空时不过滤
+func (c *HTTPClient) PriceOrders(status, market, account string, limit, offset int) ([]*PriceOrder, error) {
+	method := http.MethodGet
+	path := "/api/v4/spot/price_orders"
+	query := url.Values{}
+	query.Add("status", status)
+	if market != "" {
+		query.Add("market", market)
+	}
+	if account != "" {
+		query.Add("account", account)
+	}
+	if limit != 0 {
+		query.Add("limit", strconv.Itoa(limit))
+	}
+	if offset != 0 {
+		query.Add("offset", strconv.Itoa(offset))
+	}
+
+	return c.priceOrders(method, path, query)
+}
+
+// 查询单个价格触发订单
+func (c *HTTPClient) GetPriceOrder(id int64) (*PriceOrder, error) {
+	return c.priceOrder(http.MethodGet, id)
+}
+
+// 撤销单个价格触发订单, 返回撤销后的订单
+func (c *HTTPClient) CancelPriceOrder(id int64) (*PriceOrder, error) {
+	return c.priceOrder(http.MethodDelete, id)
+}
+
+// 撤销全部等待触发的价格触发订单, market 和 account 为空时不过滤, 返回被撤销的订单
+func (c *HTTPClient) CancelPriceOrders(market, account string) ([]*PriceOrder, error) {
+	method := http.MethodDelete
+	path := "/api/v4/spot/price_orders"
+	query := url.Values{}
+	if market != "" {
+		query.Add("market", market)
+	}
+	if account != "" {
+		query.Add("account", account)
+	}
+
+	return c.priceOrders(method, path, query)
+}
+
+func (c *HTTPClient) priceOrder(method string, id int64) (*PriceOrder, error) {
+	path := fmt.Sprintf("/api/v4/spot/price_orders/%d", id)
+	respBody, err := c.Request(method, path, nil, nil, true)
+	if err != nil {
+		c.logger.Error(method+" "+path, zap.Error(err))
+		return nil, errors.WithStack(err)
+	}
+
+	var reply *PriceOrder
+	if err := json.Unmarshal(respBody, &reply); err != nil {
+		c.logger.Error(method+" "+path, zap.String("reply", string(respBody)), zap.Error(err))
+		err := ErrResponseBody(respBody)
+		return nil, errors.WithStack(err)
+	}
+
+	return reply, nil
+}
+
+func (c *HTTPClient) priceOrders(method, path string, query url.Values) ([]*PriceOrder, error) {
+	respBody, err := c.Request(method, path, query, nil, true)
+	if err != nil {
+		c.logger.Error(method+" "+path, zap.Error(err))
+		return nil, errors.WithStack(err)
+	}
+
+	var reply []*PriceOrder
+	if err := json.Unmarshal(respBody, &reply); err != nil {
+		c.logger.Error(method+" "+path, zap.String("reply", string(respBody)), zap.Error(err))
+		err := ErrResponseBody(respBody)
+		return nil, errors.WithStack(err)
+	}
+
+	return reply, nil
+}
+
 func (c *HTTPClient) DepositAddress(currency string) (*Address, error) {
 	method := http.MethodGet
 	path := "/api/v4/wallet/deposit_address"
@@ -1,11 +1,15 @@
 package gate
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"testing"
 	"time"
 
+	"github.com/icwl/go-exchange-api/cassette"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
 	"go.uber.org/zap"
 )
 
@@ -15,12 +19,14 @@ var (
 	pSymbol  = flag.String("symbol", "btc_usdt", "symbol")
 	pHTTPURL = flag.String("http_url", HTTPURL, "http_url")
 	pWSURL   = flag.String("ws_url", WSURL, "ws_url")
+	pRecord  = flag.Bool("record", false, "请求真实服务器并重新录制 testdata/cassettes")
 
 	key     string
 	secret  string
 	symbol  string
 	httpURL string
 	wsURL   string
+	record  bool
 )
 
 func init() {
@@ -33,11 +39,42 @@ func init() {
 	symbol = *pSymbol
 	httpURL = *pHTTPURL
 	wsURL = *pWSURL
+	record = *pRecord
+}
+
+// 默认回放 testdata/cassettes, 不访问网络
+// testdata/cassettes 中的文件为按接口文档手工编写的数据, 不是真实交易所的响应
+// -record 时使用 -key/-secret 请求真实服务器并重新录制, 录制文件中的 key 和 secret 会被替换
+func newTestHTTPClient(t *testing.T) *HTTPClient {
+	mode := cassette.ModeReplay
+	if record {
+		mode = cassette.ModeRecord
+	}
+	c, err := cassette.New("testdata/cassettes/"+t.Name()+".json", mode, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Scrub(key, secret)
+	t.Cleanup(func() {
+		if err := c.Stop(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	client := NewHTTPClient(httpURL, key, secret, zap.NewExample())
+	client.SetHTTPClient(c.Client())
+	return client
+}
+
+// 提现等资金操作在 -record 时跳过, 不会使用真实账户执行
+func skipRecord(t *testing.T) {
+	if record {
+		t.Skip("-record 时跳过资金操作")
+	}
 }
 
 func TestHTTPClient_Currencies(t *testing.T) {
-	logger := zap.NewExample()
-	cli := NewHTTPClient(httpURL, key, secret, logger)
+	cli := newTestHTTPClient(t)
 	res, err := cli.Currencies()
 	if err != nil {
 		t.Fatal(err)
@@ -52,8 +89,7 @@ func TestHTTPClient_Currencies(t *testing.T) {
 }
 
 func TestHTTPClient_CurrencyPairs(t *testing.T) {
-	logger := zap.NewExample()
-	cli := NewHTTPClient(httpURL, key, secret, logger)
+	cli := newTestHTTPClient(t)
 	res, err := cli.CurrencyPairs()
 	if err != nil {
 		t.Fatal(err)
@@ -65,8 +101,7 @@ func TestHTTPClient_CurrencyPairs(t *testing.T) {
 }
 
 func TestHTTPClient_OrderBook(t *testing.T) {
-	logger := zap.NewExample()
-	cli := NewHTTPClient(httpURL, key, secret, logger)
+	cli := newTestHTTPClient(t)
 	dp, err := cli.OrderBook(symbol, "", 10)
 	if err != nil {
 		t.Fatal(err)
@@ -83,8 +118,7 @@ func TestHTTPClient_OrderBook(t *testing.T) {
 }
 
 func TestHTTPClient_Accounts(t *testing.T) {
-	logger := zap.NewExample()
-	cli := NewHTTPClient(httpURL, key, secret, logger)
+	cli := newTestHTTPClient(t)
 	lis, err := cli.Accounts("")
 	if err != nil {
 		t.Fatal(err)
@@ -99,8 +133,7 @@ func TestHTTPClient_Accounts(t *testing.T) {
 }
 
 func TestHTTPClient_OpenOrders(t *testing.T) {
-	logger := zap.NewExample()
-	cli := NewHTTPClient(httpURL, key, secret, logger)
+	cli := newTestHTTPClient(t)
 
 	res, err := cli.OpenOrders(0, 0, "")
 	if err != nil {
@@ -113,8 +146,7 @@ func TestHTTPClient_OpenOrders(t *testing.T) {
 }
 
 func TestHTTPClient_NewOrder(t *testing.T) {
-	logger := zap.NewExample()
-	cli := NewHTTPClient(httpURL, key, secret, logger)
+	cli := newTestHTTPClient(t)
 	var (
 		pair    = "BTC_USDT"
 		type_   = "limit"
@@ -122,7 +154,8 @@ func TestHTTPClient_NewOrder(t *testing.T) {
 		side    = "buy"
 		amount  = "0.1"
 		price   = "100"
-		text    = fmt.Sprintf("t-%s_%s_%d", symbol, side, time.Now().Unix())
+		// 固定 text, 回放时请求体与 cassette 一致
+		text = fmt.Sprintf("t-%s_%s_1700000000", symbol, side)
 	)
 	order, err := cli.NewOrder(text, pair, type_, account, side, amount, price)
 	if err != nil {
@@ -132,9 +165,120 @@ func TestHTTPClient_NewOrder(t *testing.T) {
 	t.Logf("Order : %v", order)
 }
 
+func TestHTTPClient_PlaceOrder(t *testing.T) {
+	cli := newTestHTTPClient(t)
+	order, err := cli.PlaceOrder(&OrderRequest{
+		Text:         "t-ice",
+		CurrencyPair: "BTC_USDT",
+		Type:         OrderTypeLimit,
+		Side:         SideBuy,
+		Amount:       decimal.RequireFromString("0.1"),
+		Price:        decimal.RequireFromString("100"),
+		TimeInForce:  TimeInForcePOC,
+		Iceberg:      decimal.RequireFromString("0.01"),
+		StpAct:       STPActCancelNewest,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.TimeInForce != TimeInForcePOC || order.StpAct != STPActCancelNewest || !order.Iceberg.Equal(decimal.RequireFromString("0.01")) {
+		t.Fatalf("unexpected order: %+v", order)
+	}
+}
+
+func TestHTTPClient_BatchOrders(t *testing.T) {
+	cli := newTestHTTPClient(t)
+
+	// 12 个订单分两次请求, 最后一个余额不足
+	reqs := make([]*OrderRequest, 0, 12)
+	for i := 0; i < 12; i++ {
+		reqs = append(reqs, &OrderRequest{
+			Text:         fmt.Sprintf("t-ladder-%d", i),
+			CurrencyPair: "BTC_USDT",
+			Type:         OrderTypeLimit,
+			Side:         SideBuy,
+			Amount:       decimal.RequireFromString("0.001"),
+			Price:        decimal.NewFromInt(int64(29990 - i)),
+		})
+	}
+	results, err := cli.BatchOrders(reqs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 12 || results[10].ID != "107266745010" {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+	failed := results[11]
+	var e *ErrResponse
+	if failed.Succeeded || failed.Text != "t-ladder-11" || !errors.As(failed.Err(), &e) || e.Label != "BALANCE_NOT_ENOUGH" {
+		t.Fatalf("unexpected result: %+v", failed)
+	}
+
+	cancelled, err := cli.CancelBatchOrders([]*CancelOrderItem{
+		{CurrencyPair: "BTC_USDT", ID: results[0].ID},
+		{CurrencyPair: "BTC_USDT", ID: failed.Text},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cancelled) != 2 || !cancelled[0].Succeeded || cancelled[0].Text != "t-ladder-0" || cancelled[1].Err() == nil {
+		t.Fatalf("unexpected results: %+v", cancelled)
+	}
+
+	// 批量下单必须设置 text
+	reqs[3].Text = ""
+	var ve *ValidationError
+	if _, err := cli.BatchOrders(reqs); !errors.As(err, &ve) || ve.Violations[0].Field != "orders[3].text" {
+		t.Fatalf("got %v, want validation error", err)
+	}
+}
+
+func TestHTTPClient_CancelOrders(t *testing.T) {
+	cli := newTestHTTPClient(t)
+
+	results, err := cli.CancelOrders("BTC_USDT", "buy", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || !results[0].Succeeded || results[0].Text != "t-a" || results[1].Err() == nil {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+
+	order, err := cli.CancelOrderByText("t-c", "BTC_USDT", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.Status != OrderStatusCancelled || order.Text != "t-c" {
+		t.Fatalf("unexpected order: %+v", order)
+	}
+}
+
+func TestHTTPClient_AmendOrder(t *testing.T) {
+	cli := newTestHTTPClient(t)
+
+	// 数量和价格都未设置时不发送请求
+	_, err := cli.AmendOrder(&AmendOrderRequest{ID: "107266745103", CurrencyPair: "BTC_USDT"})
+	var verr *ValidationError
+	if !errors.As(err, &verr) || !verr.Has(ReasonInvalidValue) {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+
+	order, err := cli.AmendOrder(&AmendOrderRequest{
+		ID:           "107266745103",
+		CurrencyPair: "BTC_USDT",
+		Price:        decimal.RequireFromString("29980"),
+		AmendText:    "reprice",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !order.Price.Equal(decimal.RequireFromString("29980")) || order.AmendText != "reprice" {
+		t.Fatalf("unexpected order: %+v", order)
+	}
+}
+
 func TestHTTPClient_CancelOrder(t *testing.T) {
-	logger := zap.NewExample()
-	cli := NewHTTPClient(httpURL, key, secret, logger)
+	cli := newTestHTTPClient(t)
 
 	//order, err := cli.CancelOrder("106358528112", "BTC_USDT", "")
 	order, err := cli.CancelOrder("107266744517", "BTC_USDT", "")
@@ -146,9 +290,132 @@ func TestHTTPClient_CancelOrder(t *testing.T) {
 	t.Logf("Order : %v", order)
 }
 
+func TestHTTPClient_History(t *testing.T) {
+	cli := newTestHTTPClient(t)
+
+	orders, err := cli.FinishedOrders(&OrderQuery{
+		CurrencyPair: "BTC_USDT",
+		From:         time.Unix(1700000000, 0),
+		To:           time.Unix(1700086400, 0),
+		Page:         1,
+		Limit:        2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 按结束时间倒序
+	if len(orders) != 2 || orders[0].Status != OrderStatusCancelled || orders[1].FinishAs != "filled" {
+		t.Fatalf("unexpected orders: %+v", orders)
+	}
+
+	// 按订单查询成交明细, 分别计算 taker 和 maker 手续费
+	trades, err := cli.MyTrades(&TradeQuery{CurrencyPair: "BTC_USDT", OrderID: orders[1].ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trades) != 2 {
+		t.Fatalf("unexpected trades: %+v", trades)
+	}
+	amount := decimal.Zero
+	for _, item := range trades {
+		amount = amount.Add(item.Amount)
+	}
+	if !amount.Equal(orders[1].Amount) || trades[0].Role != RoleTaker || !trades[0].Fee.Equal(decimal.RequireFromString("0.000003")) ||
+		trades[1].Role != RoleMaker || !trades[1].GtFee.Equal(decimal.RequireFromString("0.0012")) || trades[0].CreateTime != 1700000150 {
+		t.Fatalf("unexpected trades: %+v", trades)
+	}
+}
+
+func TestHTTPClient_Iterator(t *testing.T) {
+	cli := newTestHTTPClient(t)
+	ctx := context.Background()
+
+	// 不足一页时视为最后一页
+	trades, err := cli.MyTradeIterator(TradeQuery{CurrencyPair: "BTC_USDT", Limit: 2}).All(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trades) != 3 || trades[2].ID != "5736728" {
+		t.Fatalf("unexpected trades: %+v", trades)
+	}
+
+	// 挂单按交易对分页, 直到所有交易对都没有剩余挂单
+	orders, err := cli.OpenOrderIterator("", 1).All(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 3 || orders[2].ID != "107266745122" {
+		t.Fatalf("unexpected orders: %+v", orders)
+	}
+
+	// 已结束的订单按结束时间倒序, 遇到早于起始时间的订单后不再翻页
+	it := cli.FinishedOrderIterator(OrderQuery{CurrencyPair: "BTC_USDT", Limit: 2})
+	it.SetTimeWindow(time.Unix(1700000150, 0), time.Time{})
+	orders, err = it.All(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 3 || orders[0].ID != "107266745107" || orders[2].ID != "107266745105" {
+		t.Fatalf("unexpected finished orders: %+v", orders)
+	}
+}
+
+func TestHTTPClient_PriceOrders(t *testing.T) {
+	cli := newTestHTTPClient(t)
+
+	// 止损卖单, 价格跌破 28000 时以 27900 挂单
+	req := &PriceOrderRequest{
+		Market:       "BTC_USDT",
+		TriggerPrice: decimal.RequireFromString("28000"),
+		TriggerRule:  TriggerRuleLTE,
+		Expiration:   24 * time.Hour,
+		Side:         SideSell,
+		Price:        decimal.RequireFromString("27900"),
+		Amount:       decimal.RequireFromString("0.001"),
+	}
+	id, err := cli.PlacePriceOrder(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 1432329 {
+		t.Fatalf("unexpected id: %d", id)
+	}
+
+	orders, err := cli.PriceOrders("open", "BTC_USDT", "", 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 1 || orders[0].Trigger.Rule != TriggerRuleLTE || !orders[0].Put.Price.Equal(decimal.RequireFromString("27900")) {
+		t.Fatalf("unexpected orders: %+v", orders)
+	}
+
+	order, err := cli.GetPriceOrder(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.Status != PriceOrderStatusOpen {
+		t.Fatalf("unexpected order: %+v", order)
+	}
+
+	order, err = cli.CancelPriceOrder(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.Status != PriceOrderStatusCancelled {
+		t.Fatalf("unexpected order: %+v", order)
+	}
+
+	orders, err = cli.CancelPriceOrders("BTC_USDT", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 0 {
+		t.Fatalf("unexpected orders: %+v", orders)
+	}
+}
+
 func TestHTTPClient_GetOrder(t *testing.T) {
-	logger := zap.NewExample()
-	cli := NewHTTPClient(httpURL, key, secret, logger)
+	cli := newTestHTTPClient(t)
 
 	order, err := cli.GetOrder("106358528112", "BTC_USDT", "")
 	//order, err := cli.GetOrder("106366474094", "GOLD_USDT", "")
@@ -161,8 +428,7 @@ func TestHTTPClient_GetOrder(t *testing.T) {
 }
 
 func TestHTTPClient_DepositAddress(t *testing.T) {
-	logger := zap.NewExample()
-	cli := NewHTTPClient(httpURL, key, secret, logger)
+	cli := newTestHTTPClient(t)
 
 	address, err := cli.DepositAddress("XAVA")
 	if err != nil {
@@ -176,8 +442,8 @@ func TestHTTPClient_DepositAddress(t *testing.T) {
 }
 
 func TestHTTPClient_Withdrawal(t *testing.T) {
-	logger := zap.NewExample()
-	cli := NewHTTPClient(httpURL, key, secret, logger)
+	skipRecord(t)
+	cli := newTestHTTPClient(t)
 
 	var (
 		amount   = "3.60199268"
@@ -0,0 +1,79 @@
+package gate
+
+import (
+	"time"
+
+	"github.com/icwl/go-exchange-api/paging"
+)
+
+// 未设置每页数量时使用交易所的默认值, Gate 不返回是否有下一页, 不足一页时视为最后一页
+const defaultPageLimit = 100
+
+func orderTime(o *Order) time.Time {
+	return time.UnixMilli(o.CreateTimeMs)
+}
+
+// 已结束订单的结束时间, 与 OrderQuery 的 From 和 To 一致
+func orderFinishTime(o *Order) time.Time {
+	return time.UnixMilli(o.UpdateTimeMs)
+}
+
+func myTradeTime(t *MyTrade) time.Time {
+	return time.UnixMilli(t.CreateTimeMs.IntPart())
+}
+
+func priceOrderTime(o *PriceOrder) time.Time {
+	return time.Unix(o.Ctime, 0)
+}
+
+func pageLimit(limit int) int {
+	if limit <= 0 {
+		return defaultPageLimit
+	}
+	return limit
+}
+
+// 遍历所有挂单, limit 为每个交易对每页的数量
+func (c *HTTPClient) OpenOrderIterator(account string, limit int) *paging.Iterator[*Order] {
+	limit = pageLimit(limit)
+	it := paging.New(1, func(page int) ([]*Order, bool, error) {
+		return c.openOrders(page, limit, account)
+	})
+	it.SetTimeOf(orderTime, false)
+	return it
+}
+
+// 遍历已结束的订单, 按结束时间倒序, 时间范围优先使用查询条件的 From 和 To 由交易所过滤
+func (c *HTTPClient) FinishedOrderIterator(q OrderQuery) *paging.Iterator[*Order] {
+	q.Limit = pageLimit(q.Limit)
+	it := paging.New(q.Page, func(page int) ([]*Order, bool, error) {
+		q.Page = page
+		orders, err := c.FinishedOrders(&q)
+		return orders, len(orders) >= q.Limit, err
+	})
+	it.SetTimeOf(orderFinishTime, true)
+	return it
+}
+
+// 遍历个人成交记录, 按成交时间倒序
+func (c *HTTPClient) MyTradeIterator(q TradeQuery) *paging.Iterator[*MyTrade] {
+	q.Limit = pageLimit(q.Limit)
+	it := paging.New(q.Page, func(page int) ([]*MyTrade, bool, error) {
+		q.Page = page
+		trades, err := c.MyTrades(&q)
+		return trades, len(trades) >= q.Limit, err
+	})
+	it.SetTimeOf(myTradeTime, true)
+	return it
+}
+
+// 遍历价格触发订单, 参数见 PriceOrders
+func (c *HTTPClient) PriceOrderIterator(status, market, account string, limit int) *paging.Iterator[*PriceOrder] {
+	limit = pageLimit(limit)
+	it := paging.New(1, func(page int) ([]*PriceOrder, bool, error) {
+		orders, err := c.PriceOrders(status, market, account, limit, (page-1)*limit)
+		return orders, len(orders) >= limit, err
+	})
+	it.SetTimeOf(priceOrderTime, false)
+	return it
+}
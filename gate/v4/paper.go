@@ -0,0 +1,269 @@
+package gate
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/icwl/go-exchange-api/paper"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+// 现货下单相关接口, HTTPClient 和 PaperClient 均实现该接口, 策略可以在实盘和模拟盘之间切换
+type Trader interface {
+	Accounts(currency string) ([]*Account, error)
+	OpenOrders(page, limit int, account string) ([]*Order, error)
+	NewOrder(text, pair, type_, account, side, amount, price string) (*Order, error)
+	CancelOrder(orderId, pair, account string) (*Order, error)
+	GetOrder(orderId, pair, account string) (*Order, error)
+}
+
+var (
+	_ Trader = (*HTTPClient)(nil)
+	_ Trader = (*PaperClient)(nil)
+)
+
+// 模拟交易客户端, 订单不发送到交易所, 按实时深度撮合, 余额为虚拟余额
+// 交易对信息和深度通过 HTTPClient 获取, 也可以通过 OnOrderBook 使用 websocket 推送的深度
+type PaperClient struct {
+	cli    *HTTPClient
+	engine *paper.Engine
+	logger *zap.Logger
+
+	lock *sync.Mutex
+	// 超过该时间未更新的深度在下单前通过 OrderBook 重新获取
+	maxAge time.Duration
+}
+
+func NewPaperClient(cli *HTTPClient, logger *zap.Logger) *PaperClient {
+	return &PaperClient{
+		cli:    cli,
+		engine: paper.NewEngine(),
+		logger: logger,
+		lock:   new(sync.Mutex),
+		maxAge: time.Second,
+	}
+}
+
+// 模拟撮合引擎, 用于设置时钟或直接查询订单
+func (c *PaperClient) Engine() *paper.Engine {
+	return c.engine
+}
+
+// 设置深度最长有效时间, 默认 1 秒
+func (c *PaperClient) SetMaxBookAge(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.maxAge = d
+}
+
+// 设置虚拟可用余额
+func (c *PaperClient) SetBalance(currency string, available decimal.Decimal) {
+	c.engine.SetBalance(currency, available)
+}
+
+// 使用 websocket 推送的订单簿撮合挂单, 返回状态变化的订单
+func (c *PaperClient) OnOrderBook(ob *OrderBook) []*Order {
+	if ob == nil {
+		return nil
+	}
+	return c.orders(c.engine.UpdateBook(ob.Pair, ob.Asks, ob.Bids))
+}
+
+// 加载交易对信息, 手续费率同时作为 maker 和 taker 费率
+func (c *PaperClient) pair(pair string) (paper.Market, error) {
+	if m, ok := c.engine.Market(pair); ok {
+		return m, nil
+	}
+	pairs, err := c.cli.CurrencyPairs()
+	if err != nil {
+		return paper.Market{}, errors.WithStack(err)
+	}
+	for _, item := range pairs {
+		if item.ID != pair {
+			continue
+		}
+		rate := item.Fee.Div(decimal.NewFromInt(100))
+		m := paper.Market{
+			Name:            item.ID,
+			Base:            item.Base,
+			Quote:           item.Quote,
+			MakerFeeRate:    rate,
+			TakerFeeRate:    rate,
+			MinAmount:       item.MinBaseAmount,
+			AmountPrecision: item.AmountPrecision,
+		}
+		c.engine.AddMarket(m)
+		return m, nil
+	}
+	return paper.Market{}, errors.WithStack(paper.ErrMarketNotFound)
+}
+
+// 深度过期时重新获取
+func (c *PaperClient) refresh(pair string) error {
+	c.lock.Lock()
+	maxAge := c.maxAge
+	c.lock.Unlock()
+
+	if at, ok := c.engine.BookTime(pair); ok && time.Since(at) <= maxAge {
+		return nil
+	}
+	ob, err := c.cli.OrderBook(pair, "", 50)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	c.engine.UpdateBook(pair, ob.Asks, ob.Bids)
+	return nil
+}
+
+func (c *PaperClient) Accounts(currency string) ([]*Account, error) {
+	balances := c.engine.Balances()
+	reply := make([]*Account, 0, len(balances))
+	for _, b := range balances {
+		if currency != "" && b.Ccy != currency {
+			continue
+		}
+		reply = append(reply, &Account{Currency: b.Ccy, Available: b.Available, Locked: b.Frozen})
+	}
+	return reply, nil
+}
+
+// 查询所有挂单, 按创建时间倒序分页
+func (c *PaperClient) OpenOrders(page, limit int, account string) ([]*Order, error) {
+	orders := c.engine.Orders("", "", false)
+	if page <= 0 {
+		page = 1
+	}
+	if limit <= 0 {
+		limit = 100
+	}
+	start := (page - 1) * limit
+	if start > len(orders) {
+		start = len(orders)
+	}
+	end := start + limit
+	if end > len(orders) {
+		end = len(orders)
+	}
+	return c.orders(orders[start:end]), nil
+}
+
+// 参数同 HTTPClient.NewOrder, 只支持现货账户
+// 市价买单的 amount 为计价货币金额
+func (c *PaperClient) NewOrder(text, pair, type_, account, side, amount, price string) (*Order, error) {
+	if account != "" && account != AccountSpot {
+		return nil, errors.Wrap(paper.ErrInvalidOrder, "account")
+	}
+	if _, err := c.pair(pair); err != nil {
+		c.logger.Error("PaperClient.NewOrder", zap.String("pair", pair), zap.Error(err))
+		return nil, errors.WithStack(err)
+	}
+	if err := c.refresh(pair); err != nil {
+		c.logger.Error("PaperClient.NewOrder", zap.String("pair", pair), zap.Error(err))
+		return nil, errors.WithStack(err)
+	}
+
+	o := paper.Order{
+		ClientID: text,
+		Market:   pair,
+		Side:     side,
+		Type:     paper.TypeLimit,
+	}
+	var err error
+	switch type_ {
+	case "", OrderTypeLimit:
+		if o.Price, err = decimal.NewFromString(price); err != nil {
+			return nil, errors.Wrap(paper.ErrInvalidOrder, "price")
+		}
+	case OrderTypeMarket:
+		o.Type = paper.TypeMarket
+		o.QuoteAmount = side == "buy"
+	default:
+		return nil, errors.Wrap(paper.ErrInvalidOrder, "type")
+	}
+	if o.Amount, err = decimal.NewFromString(amount); err != nil {
+		return nil, errors.Wrap(paper.ErrInvalidOrder, "amount")
+	}
+
+	placed, err := c.engine.Place(o)
+	if err != nil {
+		c.logger.Error("PaperClient.NewOrder", zap.String("pair", pair), zap.Error(err))
+		return nil, errors.WithStack(err)
+	}
+	return c.order(placed), nil
+}
+
+// 按订单 ID 或 text 查找
+func (c *PaperClient) find(orderId, pair string) (*paper.Order, error) {
+	if id, err := strconv.ParseInt(orderId, 10, 64); err == nil {
+		return c.engine.Order(pair, id)
+	}
+	return c.engine.OrderByClientID(pair, orderId)
+}
+
+func (c *PaperClient) CancelOrder(orderId, pair, account string) (*Order, error) {
+	o, err := c.find(orderId, pair)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if o, err = c.engine.Cancel(pair, o.ID); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return c.order(o), nil
+}
+
+func (c *PaperClient) GetOrder(orderId, pair, account string) (*Order, error) {
+	o, err := c.find(orderId, pair)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return c.order(o), nil
+}
+
+func (c *PaperClient) orders(orders []*paper.Order) []*Order {
+	list := make([]*Order, 0, len(orders))
+	for _, o := range orders {
+		list = append(list, c.order(o))
+	}
+	return list
+}
+
+// 转换为 Order, 部分成交仍为 open, 部分成交后撤销为 cancelled
+func (c *PaperClient) order(o *paper.Order) *Order {
+	status := OrderStatusOpen
+	switch o.Status {
+	case paper.StatusFilled:
+		status = OrderStatusClosed
+	case paper.StatusCanceled, paper.StatusPartCanceled:
+		status = OrderStatusCancelled
+	}
+	type_ := OrderTypeLimit
+	tif := TimeInForceGTC
+	if o.Type == paper.TypeMarket {
+		type_ = OrderTypeMarket
+		tif = TimeInForceIOC
+	}
+	return &Order{
+		ID:           strconv.FormatInt(o.ID, 10),
+		Text:         o.ClientID,
+		CreateTime:   o.CreatedAt / 1000,
+		UpdateTime:   o.UpdatedAt / 1000,
+		CreateTimeMs: o.CreatedAt,
+		UpdateTimeMs: o.UpdatedAt,
+		Status:       status,
+		CurrencyPair: o.Market,
+		Type:         type_,
+		Account:      AccountSpot,
+		Side:         o.Side,
+		Amount:       o.Amount,
+		Price:        o.Price,
+		TimeInForce:  tif,
+		Left:         o.Left,
+		FillPrice:    o.FilledValue,
+		FilledTotal:  o.FilledValue,
+		Fee:          o.Fee,
+		FeeCurrency:  o.FeeCcy,
+	}
+}
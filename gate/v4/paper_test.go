@@ -0,0 +1,57 @@
+package gate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+func TestPaperClient_NewOrder(t *testing.T) {
+	d := decimal.RequireFromString
+
+	client := NewPaperClient(newTestHTTPClient(t), zap.NewExample())
+	client.SetMaxBookAge(time.Hour)
+	client.SetBalance("USDT", d("10000"))
+
+	// 市价买单 amount 为计价货币金额, 剩余金额不足以买入最小精度时结束
+	taker, err := client.NewOrder("", "BTC_USDT", OrderTypeMarket, AccountSpot, "buy", "1000", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if taker.Status != OrderStatusClosed || !taker.FilledTotal.Equal(d("999.00333")) || !taker.Fee.Equal(d("0.0000666")) {
+		t.Fatalf("unexpected order: %+v", taker)
+	}
+
+	maker, err := client.NewOrder("t-1", "BTC_USDT", OrderTypeLimit, AccountSpot, "buy", "0.01", "29000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := client.GetOrder("t-1", "BTC_USDT", ""); err != nil || got.ID != maker.ID {
+		t.Fatalf("got %+v %v", got, err)
+	}
+	open, err := client.OpenOrders(1, 10, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(open) != 1 || open[0].ID != maker.ID {
+		t.Fatalf("unexpected open orders: %+v", open)
+	}
+
+	cancelled, err := client.CancelOrder(maker.ID, "BTC_USDT", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cancelled.Status != OrderStatusCancelled {
+		t.Fatalf("unexpected order: %+v", cancelled)
+	}
+
+	accounts, err := client.Accounts("USDT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(accounts) != 1 || !accounts[0].Available.Equal(d("9000.99667")) || !accounts[0].Locked.IsZero() {
+		t.Fatalf("unexpected accounts: %+v", accounts)
+	}
+}
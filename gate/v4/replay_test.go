@@ -0,0 +1,79 @@
+package gate
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/icwl/go-exchange-api/wsrecord"
+	"go.uber.org/zap"
+)
+
+func TestReplay(t *testing.T) {
+	book, err := os.ReadFile("testdata/order_book.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := newTestWSServer(t, func(req *testWSRequest) []interface{} {
+		if req.Channel != ChannelOrderBook {
+			return nil
+		}
+		return []interface{}{json.RawMessage(book), json.RawMessage(book)}
+	})
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	rec, err := wsrecord.NewWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger := zap.NewExample()
+	cli := NewWSClient("ws"+strings.TrimPrefix(srv.URL, "http"), logger)
+	cli.SetRecorder(rec)
+	if err := cli.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+
+	if err := cli.SubOrderBook("BTC_USDT", "20", "100ms"); err != nil {
+		t.Fatal(err)
+	}
+	for n := 0; n < 2; {
+		msg, err := cli.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := msg.(*OrderBook); ok {
+			n++
+		}
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 回放使用定点数解析, 与录制时的解析方式无关
+	replay := NewReplay(wsrecord.NewPlayer(wsrecord.NewReader(&buf), 0), logger)
+	replay.SetFixedPoint(true)
+	n := 0
+	for {
+		msg, err := replay.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		ob, ok := msg.(*OrderBookFixed)
+		if !ok || ob.Pair != "BTC_USDT" || len(ob.Asks) != 20 {
+			t.Fatalf("unexpected %+v", msg)
+		}
+		n++
+	}
+	if n != 2 {
+		t.Fatalf("replayed %d, want 2", n)
+	}
+}
@@ -0,0 +1,446 @@
+package gate
+
+import (
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+func (s Side) Valid() bool {
+	return s == SideBuy || s == SideSell
+}
+
+func (t OrderType) Valid() bool {
+	return t == OrderTypeLimit || t == OrderTypeMarket
+}
+
+func (t TimeInForce) Valid() bool {
+	switch t {
+	case TimeInForceGTC, TimeInForceIOC, TimeInForcePOC, TimeInForceFOK:
+		return true
+	}
+	return false
+}
+
+func (a STPAct) Valid() bool {
+	switch a {
+	case STPActCancelNewest, STPActCancelOldest, STPActCancelBoth, STPActNone:
+		return true
+	}
+	return false
+}
+
+// 下单参数, 零值字段不发送, 使用交易所默认值
+type OrderRequest struct {
+	// 自定义 ID, 必须以 t- 开头, 见 Order.Text
+	Text         string
+	CurrencyPair string
+	// 默认 limit
+	Type OrderType
+	// 默认 spot
+	Account string
+	Side    Side
+	// 交易数量, 市价买单为计价货币金额
+	Amount decimal.Decimal
+	// 限价单价格, 市价单不发送
+	Price decimal.Decimal
+	// 限价单默认 gtc, 市价单只支持 ioc 和 fok, 默认 ioc
+	TimeInForce TimeInForce
+	// 冰山委托显示的数量, 0 为普通委托, 现货不支持全部隐藏
+	Iceberg decimal.Decimal
+	// 杠杆或全仓杠杆账户余额不足时是否自动借入
+	AutoBorrow bool
+	// 全仓杠杆账户订单结束后是否自动还款
+	AutoRepay bool
+	// 自成交保护策略, 需要账户加入 STP 用户组
+	StpAct STPAct
+	// 返回字段范围 [ACK / RESULT / FULL], 为空时由交易所决定
+	ActionMode string
+}
+
+// 检查参数格式, 不涉及交易对规则和余额, 规则校验见 Validator
+func (r *OrderRequest) Validate() error {
+	var list []Violation
+	add := func(field, reason, message string) {
+		list = append(list, Violation{Field: field, Reason: reason, Message: message})
+	}
+
+	if r.Text != "" {
+		if msg := checkText(r.Text); msg != "" {
+			add("text", ReasonInvalidText, msg)
+		}
+	}
+	if r.CurrencyPair == "" {
+		add("currency_pair", ReasonInvalidValue, "currency_pair is required")
+	}
+	if !r.Side.Valid() {
+		add("side", ReasonInvalidValue, "side must be buy or sell")
+	}
+	if r.Type != "" && !r.Type.Valid() {
+		add("type", ReasonInvalidValue, "unknown order type "+string(r.Type))
+	}
+	if !r.Amount.IsPositive() {
+		add("amount", ReasonInvalidValue, "amount must be positive")
+	}
+	if r.Type == OrderTypeMarket {
+		if r.TimeInForce != "" && r.TimeInForce != TimeInForceIOC && r.TimeInForce != TimeInForceFOK {
+			add("time_in_force", ReasonInvalidValue, "market order only supports ioc or fok")
+		}
+	} else {
+		if !r.Price.IsPositive() {
+			add("price", ReasonInvalidValue, "price must be positive")
+		}
+		if r.TimeInForce != "" && !r.TimeInForce.Valid() {
+			add("time_in_force", ReasonInvalidValue, "unknown time in force "+string(r.TimeInForce))
+		}
+	}
+	// 0 为不隐藏, 正数为可见数量, 现货不支持 -1 全部隐藏
+	if r.Iceberg.IsNegative() || r.Iceberg.GreaterThan(r.Amount) {
+		add("iceberg", ReasonInvalidValue, "iceberg must be 0 or a visible amount not above amount")
+	}
+	if r.StpAct != "" && !r.StpAct.Valid() {
+		add("stp_act", ReasonInvalidValue, "unknown stp_act "+string(r.StpAct))
+	}
+	switch r.ActionMode {
+	case "", ActionModeACK, ActionModeResult, ActionModeFull:
+	default:
+		add("action_mode", ReasonInvalidValue, "unknown action_mode "+r.ActionMode)
+	}
+
+	if len(list) > 0 {
+		return errors.WithStack(&ValidationError{Violations: list})
+	}
+	return nil
+}
+
+// 转换为订单, 用于 Validator 和 PairRules.Normalize
+func (r *OrderRequest) order() *Order {
+	return &Order{
+		Text:         r.Text,
+		CurrencyPair: r.CurrencyPair,
+		Type:         string(r.Type),
+		Account:      r.Account,
+		Side:         string(r.Side),
+		Amount:       r.Amount,
+		Price:        r.Price,
+	}
+}
+
+func (r *OrderRequest) body() map[string]interface{} {
+	body := make(map[string]interface{})
+	if r.Text != "" {
+		body["text"] = r.Text
+	}
+	body["currency_pair"] = r.CurrencyPair
+	if r.Type != "" {
+		body["type"] = r.Type
+	}
+	if r.Account != "" {
+		body["account"] = r.Account
+	}
+	body["side"] = r.Side
+	body["amount"] = r.Amount.String()
+	if r.Type != OrderTypeMarket {
+		body["price"] = r.Price.String()
+	}
+	if r.TimeInForce != "" {
+		body["time_in_force"] = r.TimeInForce
+	}
+	if !r.Iceberg.IsZero() {
+		body["iceberg"] = r.Iceberg.String()
+	}
+	if r.AutoBorrow {
+		body["auto_borrow"] = true
+	}
+	if r.AutoRepay {
+		body["auto_repay"] = true
+	}
+	if r.StpAct != "" {
+		body["stp_act"] = r.StpAct
+	}
+	if r.ActionMode != "" {
+		body["action_mode"] = r.ActionMode
+	}
+	return body
+}
+
+// 按请求所在交易对的规则校验, 见 Validate
+func (v *Validator) ValidateRequest(r *OrderRequest) error {
+	return v.Validate(r.order())
+}
+
+// 按请求所在交易对的精度规整价格和数量, 见 CurrencyPair.Normalize
+func (r *PairRules) NormalizeRequest(req *OrderRequest) error {
+	o := req.order()
+	if err := r.Normalize(o); err != nil {
+		return errors.WithStack(err)
+	}
+	req.Amount, req.Price = o.Amount, o.Price
+	return nil
+}
+
+// 改单参数, Amount 和 Price 至少设置一个, 零值表示不修改
+type AmendOrderRequest struct {
+	// 订单 ID 或者下单时的 text
+	ID           string
+	CurrencyPair string
+	// 默认 spot
+	Account string
+	// 新的交易数量, 不能小于已成交数量
+	Amount decimal.Decimal
+	// 新的价格
+	Price decimal.Decimal
+	// 改单的自定义信息, 不超过 31 字节, 在订单的 amend_text 中返回
+	AmendText string
+	// 返回字段范围 [ACK / RESULT / FULL], 为空时由交易所决定
+	ActionMode string
+}
+
+// 检查参数格式, 不涉及交易对规则
+func (r *AmendOrderRequest) Validate() error {
+	var list []Violation
+	add := func(field, reason, message string) {
+		list = append(list, Violation{Field: field, Reason: reason, Message: message})
+	}
+
+	if r.ID == "" {
+		add("id", ReasonInvalidValue, "id is required")
+	}
+	if r.CurrencyPair == "" {
+		add("currency_pair", ReasonInvalidValue, "currency_pair is required")
+	}
+	if r.Amount.IsZero() && r.Price.IsZero() {
+		add("amount", ReasonInvalidValue, "amount or price is required")
+	}
+	if r.Amount.IsNegative() {
+		add("amount", ReasonInvalidValue, "amount must be positive")
+	}
+	if r.Price.IsNegative() {
+		add("price", ReasonInvalidValue, "price must be positive")
+	}
+	if len(r.AmendText) > 31 {
+		add("amend_text", ReasonInvalidValue, "amend_text must be at most 31 bytes")
+	}
+	switch r.ActionMode {
+	case "", ActionModeACK, ActionModeResult, ActionModeFull:
+	default:
+		add("action_mode", ReasonInvalidValue, "unknown action_mode "+r.ActionMode)
+	}
+
+	if len(list) > 0 {
+		return errors.WithStack(&ValidationError{Violations: list})
+	}
+	return nil
+}
+
+func (r *AmendOrderRequest) body() map[string]interface{} {
+	body := make(map[string]interface{})
+	body["currency_pair"] = r.CurrencyPair
+	if r.Account != "" {
+		body["account"] = r.Account
+	}
+	if !r.Amount.IsZero() {
+		body["amount"] = r.Amount.String()
+	}
+	if !r.Price.IsZero() {
+		body["price"] = r.Price.String()
+	}
+	if r.AmendText != "" {
+		body["amend_text"] = r.AmendText
+	}
+	if r.ActionMode != "" {
+		body["action_mode"] = r.ActionMode
+	}
+	return body
+}
+
+// 价格触发订单参数, 市场价格满足 TriggerRule 后按 Put 参数下单
+type PriceOrderRequest struct {
+	Market string
+	// 触发价格
+	TriggerPrice decimal.Decimal
+	// 触发规则, TriggerRuleGTE 或 TriggerRuleLTE
+	TriggerRule string
+	// 最长等待触发时间, 按秒取整, 为 0 时不过期
+	Expiration time.Duration
+	// 默认 limit
+	Type OrderType
+	Side Side
+	// 限价单价格, 市价单不发送
+	Price decimal.Decimal
+	// 交易数量, 市价买单为计价货币金额
+	Amount decimal.Decimal
+	// 默认 normal, 即现货账户, 也可以使用 AccountSpot
+	Account string
+	// 只支持 gtc 和 ioc, 默认 gtc
+	TimeInForce TimeInForce
+	// 杠杆或全仓杠杆账户余额不足时是否自动借入
+	AutoBorrow bool
+	// 全仓杠杆账户订单结束后是否自动还款
+	AutoRepay bool
+}
+
+// 检查参数格式, 不涉及交易对规则和余额
+func (r *PriceOrderRequest) Validate() error {
+	var list []Violation
+	add := func(field, reason, message string) {
+		list = append(list, Violation{Field: field, Reason: reason, Message: message})
+	}
+
+	if r.Market == "" {
+		add("market", ReasonInvalidValue, "market is required")
+	}
+	if !r.TriggerPrice.IsPositive() {
+		add("trigger.price", ReasonInvalidValue, "trigger price must be positive")
+	}
+	if r.TriggerRule != TriggerRuleGTE && r.TriggerRule != TriggerRuleLTE {
+		add("trigger.rule", ReasonInvalidValue, "trigger rule must be >= or <=")
+	}
+	if r.Expiration < 0 {
+		add("trigger.expiration", ReasonInvalidValue, "expiration must not be negative")
+	}
+	if !r.Side.Valid() {
+		add("put.side", ReasonInvalidValue, "side must be buy or sell")
+	}
+	if r.Type != "" && !r.Type.Valid() {
+		add("put.type", ReasonInvalidValue, "unknown order type "+string(r.Type))
+	}
+	if !r.Amount.IsPositive() {
+		add("put.amount", ReasonInvalidValue, "amount must be positive")
+	}
+	if r.Type != OrderTypeMarket && !r.Price.IsPositive() {
+		add("put.price", ReasonInvalidValue, "price must be positive")
+	}
+	if r.TimeInForce != "" && r.TimeInForce != TimeInForceGTC && r.TimeInForce != TimeInForceIOC {
+		add("put.time_in_force", ReasonInvalidValue, "price order only supports gtc or ioc")
+	}
+	switch r.Account {
+	case "", PriceOrderAccountNormal, AccountSpot, AccountMargin, AccountCrossMargin, AccountUnified:
+	default:
+		add("put.account", ReasonInvalidValue, "unknown account "+r.Account)
+	}
+
+	if len(list) > 0 {
+		return errors.WithStack(&ValidationError{Violations: list})
+	}
+	return nil
+}
+
+func (r *PriceOrderRequest) body() map[string]interface{} {
+	trigger := make(map[string]interface{})
+	trigger["price"] = r.TriggerPrice.String()
+	trigger["rule"] = r.TriggerRule
+	trigger["expiration"] = int64(r.Expiration / time.Second)
+
+	put := make(map[string]interface{})
+	type_ := r.Type
+	if type_ == "" {
+		type_ = OrderTypeLimit
+	}
+	put["type"] = type_
+	put["side"] = r.Side
+	if type_ != OrderTypeMarket {
+		put["price"] = r.Price.String()
+	}
+	put["amount"] = r.Amount.String()
+	account := r.Account
+	if account == "" || account == AccountSpot {
+		account = PriceOrderAccountNormal
+	}
+	put["account"] = account
+	if r.TimeInForce != "" {
+		put["time_in_force"] = r.TimeInForce
+	}
+	if r.AutoBorrow {
+		put["auto_borrow"] = true
+	}
+	if r.AutoRepay {
+		put["auto_repay"] = true
+	}
+
+	body := make(map[string]interface{})
+	body["market"] = r.Market
+	body["trigger"] = trigger
+	body["put"] = put
+	return body
+}
+
+// 历史订单查询条件, 零值字段不发送
+type OrderQuery struct {
+	CurrencyPair string
+	// 默认 spot
+	Account string
+	Side    Side
+	// 按订单结束时间过滤, 精确到秒
+	From time.Time
+	To   time.Time
+	// 页码, 从 1 开始
+	Page int
+	// 每页数量, 最大 100
+	Limit int
+}
+
+func (q *OrderQuery) values() url.Values {
+	query := url.Values{}
+	if q.CurrencyPair != "" {
+		query.Add("currency_pair", q.CurrencyPair)
+	}
+	if q.Account != "" {
+		query.Add("account", q.Account)
+	}
+	if q.Side != "" {
+		query.Add("side", string(q.Side))
+	}
+	addPaging(query, q.From, q.To, q.Page, q.Limit)
+	return query
+}
+
+// 个人成交记录查询条件, 零值字段不发送
+type TradeQuery struct {
+	// 为空时查询全部交易对
+	CurrencyPair string
+	// 只查询该订单的成交, 需要同时设置 CurrencyPair
+	OrderID string
+	// 默认 spot
+	Account string
+	// 按成交时间过滤, 精确到秒
+	From time.Time
+	To   time.Time
+	// 页码, 从 1 开始
+	Page int
+	// 每页数量, 最大 1000
+	Limit int
+}
+
+func (q *TradeQuery) values() url.Values {
+	query := url.Values{}
+	if q.CurrencyPair != "" {
+		query.Add("currency_pair", q.CurrencyPair)
+	}
+	if q.OrderID != "" {
+		query.Add("order_id", q.OrderID)
+	}
+	if q.Account != "" {
+		query.Add("account", q.Account)
+	}
+	addPaging(query, q.From, q.To, q.Page, q.Limit)
+	return query
+}
+
+func addPaging(query url.Values, from, to time.Time, page, limit int) {
+	if !from.IsZero() {
+		query.Add("from", strconv.FormatInt(from.Unix(), 10))
+	}
+	if !to.IsZero() {
+		query.Add("to", strconv.FormatInt(to.Unix(), 10))
+	}
+	if page != 0 {
+		query.Add("page", strconv.Itoa(page))
+	}
+	if limit != 0 {
+		query.Add("limit", strconv.Itoa(limit))
+	}
+}
@@ -0,0 +1,101 @@
+package gate
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+func TestOrderRequest(t *testing.T) {
+	d := decimal.RequireFromString
+
+	req := &OrderRequest{
+		CurrencyPair: "BTC_USDT",
+		Type:         OrderTypeMarket,
+		Side:         SideBuy,
+		Amount:       d("100"),
+		TimeInForce:  TimeInForceFOK,
+		AutoBorrow:   true,
+		Account:      AccountMargin,
+	}
+	if err := req.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := json.Marshal(req.body())
+	want := `{"account":"margin","amount":"100","auto_borrow":true,"currency_pair":"BTC_USDT","side":"buy","time_in_force":"fok","type":"market"}`
+	if string(b) != want {
+		t.Fatalf("got %s, want %s", b, want)
+	}
+
+	bad := &OrderRequest{
+		Text:         "my-order",
+		CurrencyPair: "BTC_USDT",
+		Type:         OrderTypeMarket,
+		Side:         "long",
+		Amount:       d("1"),
+		TimeInForce:  TimeInForcePOC,
+		Iceberg:      d("2"),
+		StpAct:       "xx",
+	}
+	var e *ValidationError
+	if err := bad.Validate(); !errors.As(err, &e) || len(e.Violations) != 5 {
+		t.Fatalf("got %v, want 5 violations", err)
+	}
+
+	// 冰山数量只能为 0 或 (0, amount], 现货不支持 -1 全部隐藏
+	for _, c := range []struct {
+		iceberg string
+		ok      bool
+	}{{"-1", false}, {"0", true}, {"0.5", true}, {"1", true}, {"-0.5", false}, {"-2", false}, {"1.1", false}} {
+		req := &OrderRequest{CurrencyPair: "BTC_USDT", Side: SideBuy, Price: d("30000"), Amount: d("1"), Iceberg: d(c.iceberg)}
+		err := req.Validate()
+		if c.ok {
+			if err != nil {
+				t.Fatalf("iceberg %s: got %v", c.iceberg, err)
+			}
+			continue
+		}
+		if !errors.As(err, &e) || len(e.Violations) != 1 || e.Violations[0].Field != "iceberg" || e.Violations[0].Reason != ReasonInvalidValue {
+			t.Fatalf("iceberg %s: got %v, want invalid_value", c.iceberg, err)
+		}
+	}
+}
+
+func TestPriceOrderRequest(t *testing.T) {
+	d := decimal.RequireFromString
+
+	req := &PriceOrderRequest{
+		Market:       "BTC_USDT",
+		TriggerPrice: d("28000"),
+		TriggerRule:  TriggerRuleLTE,
+		Expiration:   time.Hour,
+		Side:         SideSell,
+		Price:        d("27900"),
+		Amount:       d("0.001"),
+		Account:      AccountSpot,
+	}
+	if err := req.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := json.Marshal(req.body())
+	want := `{"market":"BTC_USDT","put":{"account":"normal","amount":"0.001","price":"27900","side":"sell","type":"limit"},"trigger":{"expiration":3600,"price":"28000","rule":"\u003c="}}`
+	if string(b) != want {
+		t.Fatalf("got %s, want %s", b, want)
+	}
+
+	bad := &PriceOrderRequest{
+		Market:       "BTC_USDT",
+		TriggerPrice: d("28000"),
+		TriggerRule:  "<",
+		Side:         SideSell,
+		Amount:       d("0.001"),
+		TimeInForce:  TimeInForcePOC,
+	}
+	var e *ValidationError
+	if err := bad.Validate(); !errors.As(err, &e) || len(e.Violations) != 3 {
+		t.Fatalf("got %v, want 3 violations", err)
+	}
+}
@@ -0,0 +1,149 @@
+package gate
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+var ErrCurrencyPairNotFound = errors.New("gate: currency pair not found")
+
+// 价格最小变动单位
+func (p *CurrencyPair) Tick() decimal.Decimal {
+	return decimal.New(1, -p.Precision)
+}
+
+// 数量最小变动单位
+func (p *CurrencyPair) Lot() decimal.Decimal {
+	return decimal.New(1, -p.AmountPrecision)
+}
+
+func (p *CurrencyPair) RoundPrice(price decimal.Decimal) decimal.Decimal {
+	return price.Round(p.Precision)
+}
+
+func (p *CurrencyPair) FloorPrice(price decimal.Decimal) decimal.Decimal {
+	return price.RoundFloor(p.Precision)
+}
+
+func (p *CurrencyPair) CeilPrice(price decimal.Decimal) decimal.Decimal {
+	return price.RoundCeil(p.Precision)
+}
+
+func (p *CurrencyPair) RoundAmount(amount decimal.Decimal) decimal.Decimal {
+	return amount.Round(p.AmountPrecision)
+}
+
+func (p *CurrencyPair) FloorAmount(amount decimal.Decimal) decimal.Decimal {
+	return amount.RoundFloor(p.AmountPrecision)
+}
+
+func (p *CurrencyPair) CeilAmount(amount decimal.Decimal) decimal.Decimal {
+	return amount.RoundCeil(p.AmountPrecision)
+}
+
+// 按精度规整订单价格和数量, 结果不会比原订单更差:
+// 数量向下取整, 买单价格向下取整, 卖单价格向上取整
+// 市价买单的数量为计价货币金额, 按价格精度向下取整
+func (p *CurrencyPair) Normalize(o *Order) {
+	if o.Type == OrderTypeMarket {
+		if o.Side == "buy" {
+			o.Amount = o.Amount.RoundFloor(p.Precision)
+		} else {
+			o.Amount = p.FloorAmount(o.Amount)
+		}
+		return
+	}
+	o.Amount = p.FloorAmount(o.Amount)
+	if o.Side == "sell" {
+		o.Price = p.CeilPrice(o.Price)
+	} else {
+		o.Price = p.FloorPrice(o.Price)
+	}
+}
+
+// 交易对规则缓存, 首次使用时通过 CurrencyPairs 加载全部交易对, 超过 ttl 后重新加载
+// 加载失败时继续使用上一次的结果
+type PairRules struct {
+	cli    *HTTPClient
+	logger *zap.Logger
+
+	lock     *sync.Mutex
+	ttl      time.Duration
+	loadedAt time.Time
+	pairs    map[string]*CurrencyPair
+}
+
+// ttl 为 0 时只加载一次, 之后需要调用 Refresh 手动刷新
+func NewPairRules(cli *HTTPClient, ttl time.Duration, logger *zap.Logger) *PairRules {
+	return &PairRules{
+		cli:    cli,
+		logger: logger,
+		lock:   new(sync.Mutex),
+		ttl:    ttl,
+	}
+}
+
+// 重新加载全部交易对
+func (r *PairRules) Refresh() error {
+	pairs, err := r.cli.CurrencyPairs()
+	if err != nil {
+		r.logger.Error("PairRules.Refresh", zap.Error(err))
+		return errors.WithStack(err)
+	}
+	m := make(map[string]*CurrencyPair, len(pairs))
+	for _, item := range pairs {
+		m[item.ID] = item
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.pairs = m
+	r.loadedAt = time.Now()
+	return nil
+}
+
+func (r *PairRules) expired() bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.pairs == nil {
+		return true
+	}
+	return r.ttl > 0 && time.Since(r.loadedAt) > r.ttl
+}
+
+// 查询交易对规则, 返回值为缓存的副本
+func (r *PairRules) Pair(pair string) (*CurrencyPair, error) {
+	if r.expired() {
+		if err := r.Refresh(); err != nil {
+			r.lock.Lock()
+			loaded := r.pairs != nil
+			r.lock.Unlock()
+			if !loaded {
+				return nil, errors.WithStack(err)
+			}
+		}
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	p, ok := r.pairs[pair]
+	if !ok {
+		return nil, errors.Wrap(ErrCurrencyPairNotFound, pair)
+	}
+	cp := *p
+	return &cp, nil
+}
+
+// 按订单所在交易对的精度规整价格和数量, 见 CurrencyPair.Normalize
+func (r *PairRules) Normalize(o *Order) error {
+	p, err := r.Pair(o.CurrencyPair)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	p.Normalize(o)
+	return nil
+}
@@ -0,0 +1,54 @@
+package gate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+func TestPairRules(t *testing.T) {
+	d := decimal.RequireFromString
+	rules := NewPairRules(newTestHTTPClient(t), time.Hour, zap.NewExample())
+
+	p, err := rules.Pair("BTC_USDT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.Tick().Equal(d("0.1")) || !p.Lot().Equal(d("0.000001")) || !p.RoundPrice(d("30000.05")).Equal(d("30000.1")) {
+		t.Fatalf("unexpected pair: %+v", p)
+	}
+
+	// 只加载一次, 后续查询使用缓存
+	cases := []struct {
+		order  Order
+		amount string
+		price  string
+	}{
+		{Order{CurrencyPair: "ETH_USDT", Side: "buy", Type: OrderTypeLimit, Amount: d("1.23456"), Price: d("2000.019")}, "1.2345", "2000.01"},
+		{Order{CurrencyPair: "ETH_USDT", Side: "sell", Type: OrderTypeLimit, Amount: d("1.23456"), Price: d("2000.011")}, "1.2345", "2000.02"},
+		{Order{CurrencyPair: "BTC_USDT", Side: "buy", Type: OrderTypeMarket, Amount: d("100.99")}, "100.9", "0"},
+	}
+	for _, c := range cases {
+		o := c.order
+		if err := rules.Normalize(&o); err != nil {
+			t.Fatal(err)
+		}
+		if !o.Amount.Equal(d(c.amount)) || !o.Price.Equal(d(c.price)) {
+			t.Fatalf("got %s@%s, want %s@%s", o.Amount, o.Price, c.amount, c.price)
+		}
+	}
+
+	if _, err := rules.Pair("DOGE_USDT"); !errors.Is(err, ErrCurrencyPairNotFound) {
+		t.Fatalf("got %v, want pair not found", err)
+	}
+	// 刷新失败时继续使用缓存
+	if err := rules.Refresh(); err == nil {
+		t.Fatal("want refresh error")
+	}
+	if _, err := rules.Pair("ETH_USDT"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -18,3 +18,13 @@ func Sign(method, path, query string, body []byte, timestamp, secret string) str
 	mac.Write([]byte(s))
 	return hex.EncodeToString(mac.Sum(nil))
 }
+
+// websocket api 认证签名
+// - channel 请求频道, 如 spot.login
+// - reqParam 请求参数 json, login 时为空
+func WSSign(channel, reqParam, timestamp, secret string) string {
+	s := fmt.Sprintf("%s\n%s\n%s\n%s", "api", channel, reqParam, timestamp)
+	mac := hmac.New(sha512.New, []byte(secret))
+	mac.Write([]byte(s))
+	return hex.EncodeToString(mac.Sum(nil))
+}
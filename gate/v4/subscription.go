@@ -0,0 +1,213 @@
+package gate
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	ChannelOrderBook    = "spot.order_book"
+	ChannelTickers      = "spot.tickers"
+	ChannelTrades       = "spot.trades"
+	ChannelCandlesticks = "spot.candlesticks"
+	ChannelBookTicker   = "spot.book_ticker"
+)
+
+// payload 为交易对列表的频道, 多个订阅可以合并为一条消息
+var pairListChannels = map[string]bool{
+	ChannelTickers:    true,
+	ChannelTrades:     true,
+	ChannelBookTicker: true,
+}
+
+// 订阅
+type Subscription struct {
+	// 订阅频道, 如 spot.order_book
+	Channel string
+	// 订阅参数
+	// spot.order_book: [cp, level, interval]
+	// spot.candlesticks: [interval, cp]
+	// spot.tickers / spot.trades / spot.book_ticker: [cp]
+	Payload []string
+}
+
+func (s *Subscription) Key() string {
+	return s.Channel + ":" + strings.Join(s.Payload, ",")
+}
+
+// 对比当前订阅与目标订阅, 返回需要新增和取消的订阅
+func Diff(current, desired []*Subscription) (sub, unsub []*Subscription) {
+	cur := make(map[string]bool, len(current))
+	for _, s := range current {
+		cur[s.Key()] = true
+	}
+	want := make(map[string]bool, len(desired))
+	for _, s := range desired {
+		want[s.Key()] = true
+	}
+
+	for _, s := range desired {
+		if !cur[s.Key()] {
+			sub = append(sub, s)
+		}
+	}
+	for _, s := range current {
+		if !want[s.Key()] {
+			unsub = append(unsub, s)
+		}
+	}
+	return sub, unsub
+}
+
+// 合并订阅消息, 交易对列表频道每个频道一条消息, 其他频道每个订阅一条消息
+func groupPayloads(subs []*Subscription) ([]string, [][]interface{}) {
+	channels := make([]string, 0)
+	payloads := make([][]interface{}, 0)
+	merged := make(map[string]int)
+	for _, s := range subs {
+		if i, ok := merged[s.Channel]; ok {
+			for _, p := range s.Payload {
+				payloads[i] = append(payloads[i], p)
+			}
+			continue
+		}
+		if pairListChannels[s.Channel] {
+			merged[s.Channel] = len(payloads)
+		}
+		payload := make([]interface{}, 0, len(s.Payload))
+		for _, p := range s.Payload {
+			payload = append(payload, p)
+		}
+		channels = append(channels, s.Channel)
+		payloads = append(payloads, payload)
+	}
+	return channels, payloads
+}
+
+// 订阅并记录到当前订阅列表
+func (c *WSClient) Subscribe(subs []*Subscription) error {
+	channels, payloads := groupPayloads(subs)
+	for i, channel := range channels {
+		if err := c.Sub(channel, payloads[i]); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	c.slock.Lock()
+	for _, s := range subs {
+		c.subs[s.Key()] = s.clone()
+	}
+	c.slock.Unlock()
+	return nil
+}
+
+// 取消订阅并从当前订阅列表移除
+func (c *WSClient) Unsubscribe(subs []*Subscription) error {
+	channels, payloads := groupPayloads(subs)
+	for i, channel := range channels {
+		if err := c.Unsub(channel, payloads[i]); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	c.slock.Lock()
+	for _, s := range subs {
+		delete(c.subs, s.Key())
+	}
+	c.slock.Unlock()
+	return nil
+}
+
+// 当前订阅列表, 按 Key 排序
+func (c *WSClient) Subscriptions() []*Subscription {
+	c.slock.Lock()
+	defer c.slock.Unlock()
+
+	subs := make([]*Subscription, 0, len(c.subs))
+	for _, s := range c.subs {
+		subs = append(subs, s.clone())
+	}
+	sort.Slice(subs, func(i, j int) bool {
+		return subs[i].Key() < subs[j].Key()
+	})
+	return subs
+}
+
+func (c *WSClient) IsSubscribed(channel string, payload ...string) bool {
+	c.slock.Lock()
+	defer c.slock.Unlock()
+
+	_, ok := c.subs[(&Subscription{Channel: channel, Payload: payload}).Key()]
+	return ok
+}
+
+// 将当前订阅同步为目标订阅, 只发送差异部分
+func (c *WSClient) Sync(desired []*Subscription) error {
+	return c.apply(Diff(c.Subscriptions(), desired))
+}
+
+// 将交易对列表频道的订阅同步为 pairs, 其他频道不受影响
+// - channel spot.tickers / spot.trades / spot.book_ticker
+func (c *WSClient) SyncPairs(channel string, pairs []string) error {
+	if !pairListChannels[channel] {
+		return errors.Errorf("channel %s is not a pair list channel", channel)
+	}
+	return c.apply(Diff(c.channelSubscriptions(channel), pairSubscriptions(channel, pairs)))
+}
+
+// 将订单簿订阅同步为 pairs
+func (c *WSClient) SyncOrderBook(pairs []string, level, interval string) error {
+	desired := orderBookSubscriptions(pairs, level, interval)
+	return c.apply(Diff(c.channelSubscriptions(ChannelOrderBook), desired))
+}
+
+func (c *WSClient) channelSubscriptions(channel string) []*Subscription {
+	subs := make([]*Subscription, 0)
+	for _, s := range c.Subscriptions() {
+		if s.Channel == channel {
+			subs = append(subs, s)
+		}
+	}
+	return subs
+}
+
+func (c *WSClient) apply(sub, unsub []*Subscription) error {
+	if len(unsub) > 0 {
+		if err := c.Unsubscribe(unsub); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	if len(sub) > 0 {
+		if err := c.Subscribe(sub); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+func (s *Subscription) clone() *Subscription {
+	payload := make([]string, len(s.Payload))
+	copy(payload, s.Payload)
+	return &Subscription{Channel: s.Channel, Payload: payload}
+}
+
+func pairSubscriptions(channel string, pairs []string) []*Subscription {
+	subs := make([]*Subscription, 0, len(pairs))
+	for _, pair := range pairs {
+		subs = append(subs, &Subscription{Channel: channel, Payload: []string{pair}})
+	}
+	return subs
+}
+
+func orderBookSubscriptions(pairs []string, level, interval string) []*Subscription {
+	subs := make([]*Subscription, 0, len(pairs))
+	for _, pair := range pairs {
+		subs = append(subs, &Subscription{
+			Channel: ChannelOrderBook,
+			Payload: []string{pair, level, interval},
+		})
+	}
+	return subs
+}
@@ -0,0 +1,79 @@
+package gate
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestDiff(t *testing.T) {
+	current := []*Subscription{
+		{Channel: ChannelOrderBook, Payload: []string{"BTC_USDT", "10", "100ms"}},
+		{Channel: ChannelTickers, Payload: []string{"BTC_USDT"}},
+	}
+	desired := []*Subscription{
+		{Channel: ChannelOrderBook, Payload: []string{"BTC_USDT", "20", "100ms"}},
+		{Channel: ChannelTickers, Payload: []string{"BTC_USDT"}},
+		{Channel: ChannelTickers, Payload: []string{"ETH_USDT"}},
+	}
+
+	sub, unsub := Diff(current, desired)
+	if len(sub) != 2 || sub[0].Key() != "spot.order_book:BTC_USDT,20,100ms" || sub[1].Key() != "spot.tickers:ETH_USDT" {
+		t.Fatalf("unexpected sub: %+v", sub)
+	}
+	if len(unsub) != 1 || unsub[0].Key() != "spot.order_book:BTC_USDT,10,100ms" {
+		t.Fatalf("unexpected unsub: %+v", unsub)
+	}
+}
+
+func TestWSClient_SyncPairs(t *testing.T) {
+	sent := make(chan string, 16)
+	srv := newTestWSServer(t, func(req *testWSRequest) []interface{} {
+		sent <- req.Channel + " " + req.Event + " " + string(req.Payload)
+		return nil
+	})
+	defer srv.Close()
+
+	logger := zap.NewExample()
+	cli := NewWSClient("ws"+strings.TrimPrefix(srv.URL, "http"), logger)
+	if err := cli.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+
+	if err := cli.SubTickers([]string{"BTC_USDT", "ETH_USDT"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cli.SubOrderBook("BTC_USDT", "10", "100ms"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cli.SyncPairs(ChannelTickers, []string{"ETH_USDT", "SOL_USDT", "DOGE_USDT"}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		`spot.tickers subscribe ["BTC_USDT","ETH_USDT"]`,
+		`spot.order_book subscribe ["BTC_USDT","10","100ms"]`,
+		`spot.tickers unsubscribe ["BTC_USDT"]`,
+		`spot.tickers subscribe ["SOL_USDT","DOGE_USDT"]`,
+	}
+	for _, w := range want {
+		select {
+		case got := <-sent:
+			if got != w {
+				t.Fatalf("got %s, want %s", got, w)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timeout waiting for %s", w)
+		}
+	}
+
+	if !cli.IsSubscribed(ChannelOrderBook, "BTC_USDT", "10", "100ms") || cli.IsSubscribed(ChannelTickers, "BTC_USDT") {
+		t.Fatalf("unexpected subscriptions: %+v", cli.Subscriptions())
+	}
+	if n := len(cli.Subscriptions()); n != 4 {
+		t.Fatalf("got %d subscriptions, want 4", n)
+	}
+}
@@ -0,0 +1,213 @@
+package gate
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+// 下单前校验不通过的原因
+const (
+	ReasonInvalidValue        = "invalid_value"        // 参数缺失或无法解析
+	ReasonPrecision           = "precision"            // 价格或数量超过精度
+	ReasonAmountTooSmall      = "amount_too_small"     // 低于交易货币最低交易数量
+	ReasonNotionalTooSmall    = "notional_too_small"   // 低于计价货币最低交易数量
+	ReasonNotTradable         = "not_tradable"         // 交易对不允许该方向交易
+	ReasonNotStarted          = "not_started"          // 未到允许买入或卖出时间
+	ReasonInvalidText         = "invalid_text"         // text 不符合格式要求
+	ReasonInsufficientBalance = "insufficient_balance" // 可用余额不足
+)
+
+// 一条校验不通过的原因
+type Violation struct {
+	// 订单字段, 如 amount, price, text
+	Field   string
+	Reason  string
+	Message string
+}
+
+// 下单前校验不通过, 包含全部原因
+type ValidationError struct {
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		parts = append(parts, v.Field+": "+v.Reason+" ("+v.Message+")")
+	}
+	return "gate: order rejected by validator: " + strings.Join(parts, "; ")
+}
+
+// 是否包含指定原因
+func (e *ValidationError) Has(reason string) bool {
+	for _, v := range e.Violations {
+		if v.Reason == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// 按交易对规则和可用余额在本地校验订单, 可以通过 HTTPClient.SetValidator 在 NewOrder 前自动执行
+type Validator struct {
+	rules *PairRules
+	// 查询可用余额, 为 nil 时不检查余额
+	trader Trader
+	now    func() time.Time
+}
+
+// trader 为 nil 时不检查余额
+func NewValidator(rules *PairRules, trader Trader) *Validator {
+	return &Validator{
+		rules:  rules,
+		trader: trader,
+		now:    time.Now,
+	}
+}
+
+// 替换时钟, 用于判断 buy_start / sell_start
+func (v *Validator) SetClock(now func() time.Time) {
+	v.now = now
+}
+
+// 校验订单, 不通过时返回 *ValidationError, 查询交易对或余额失败时返回对应错误
+// 市价买单的 amount 为计价货币金额
+func (v *Validator) Validate(o *Order) error {
+	p, err := v.rules.Pair(o.CurrencyPair)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	var list []Violation
+	add := func(field, reason, message string) {
+		list = append(list, Violation{Field: field, Reason: reason, Message: message})
+	}
+
+	if o.Text != "" {
+		if msg := checkText(o.Text); msg != "" {
+			add("text", ReasonInvalidText, msg)
+		}
+	}
+
+	buy := o.Side == "buy"
+	market := o.Type == OrderTypeMarket
+	switch {
+	case o.Side != "buy" && o.Side != "sell":
+		add("side", ReasonInvalidValue, "side must be buy or sell")
+	case p.TradeStatus == "untradable",
+		p.TradeStatus == "buyable" && !buy,
+		p.TradeStatus == "sellable" && buy:
+		add("side", ReasonNotTradable, "trade status is "+p.TradeStatus)
+	}
+	now := v.now().Unix()
+	if buy && int64(p.BuyStart) > now {
+		add("side", ReasonNotStarted, "buy starts at "+time.Unix(int64(p.BuyStart), 0).UTC().Format(time.RFC3339))
+	}
+	if !buy && int64(p.SellStart) > now {
+		add("side", ReasonNotStarted, "sell starts at "+time.Unix(int64(p.SellStart), 0).UTC().Format(time.RFC3339))
+	}
+
+	// 市价买单按计价货币金额下单
+	amountPrecision := p.AmountPrecision
+	if market && buy {
+		amountPrecision = p.Precision
+	}
+	switch {
+	case !o.Amount.IsPositive():
+		add("amount", ReasonInvalidValue, "amount must be positive")
+	case !o.Amount.Equal(o.Amount.Truncate(amountPrecision)):
+		add("amount", ReasonPrecision, "amount precision is "+decimal.NewFromInt32(amountPrecision).String())
+	}
+	if !market {
+		switch {
+		case !o.Price.IsPositive():
+			add("price", ReasonInvalidValue, "price must be positive")
+		case !o.Price.Equal(o.Price.Truncate(p.Precision)):
+			add("price", ReasonPrecision, "price precision is "+decimal.NewFromInt32(p.Precision).String())
+		}
+	}
+
+	notional := o.Amount
+	if !market {
+		notional = o.Amount.Mul(o.Price)
+	}
+	if !(market && buy) && o.Amount.LessThan(p.MinBaseAmount) {
+		add("amount", ReasonAmountTooSmall, "min base amount is "+p.MinBaseAmount.String())
+	}
+	if (!market || buy) && o.Amount.IsPositive() && notional.LessThan(p.MinQuoteAmount) {
+		add("amount", ReasonNotionalTooSmall, "min quote amount is "+p.MinQuoteAmount.String())
+	}
+
+	// 其它校验通过后才查询余额, 避免多余的请求
+	if v.trader != nil && len(list) == 0 && (o.Account == "" || o.Account == AccountSpot) {
+		ccy, need := p.Base, o.Amount
+		if buy {
+			ccy, need = p.Quote, notional
+		}
+		accounts, err := v.trader.Accounts(ccy)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		available := decimal.Zero
+		for _, a := range accounts {
+			if a.Currency == ccy {
+				available = available.Add(a.Available)
+			}
+		}
+		if available.LessThan(need) {
+			add("amount", ReasonInsufficientBalance, ccy+" available "+available.String()+", need "+need.String())
+		}
+	}
+
+	if len(list) > 0 {
+		return errors.WithStack(&ValidationError{Violations: list})
+	}
+	return nil
+}
+
+// 按 NewOrder 的参数校验, 数量和价格无法解析时作为无效参数
+func (v *Validator) validateArgs(text, pair, type_, account, side, amount, price string) error {
+	o := &Order{
+		Text:         text,
+		CurrencyPair: pair,
+		Type:         type_,
+		Account:      account,
+		Side:         side,
+	}
+	var list []Violation
+	var err error
+	if o.Amount, err = decimal.NewFromString(amount); err != nil {
+		list = append(list, Violation{Field: "amount", Reason: ReasonInvalidValue, Message: err.Error()})
+	}
+	if type_ != OrderTypeMarket {
+		if o.Price, err = decimal.NewFromString(price); err != nil {
+			list = append(list, Violation{Field: "price", Reason: ReasonInvalidValue, Message: err.Error()})
+		}
+	}
+	if len(list) > 0 {
+		return errors.WithStack(&ValidationError{Violations: list})
+	}
+	return v.Validate(o)
+}
+
+// text 必须以 t- 开头, 不计算 t- 长度不超过 28 字节, 只能包含数字, 字母, 下划线, 中划线或者点
+func checkText(text string) string {
+	if !strings.HasPrefix(text, "t-") {
+		return "text must start with t-"
+	}
+	rest := text[2:]
+	if len(rest) == 0 || len(rest) > 28 {
+		return "text must be 1 to 28 bytes after t-"
+	}
+	for i := 0; i < len(rest); i++ {
+		ch := rest[i]
+		if ch >= '0' && ch <= '9' || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch == '_' || ch == '-' || ch == '.' {
+			continue
+		}
+		return "text may only contain letters, digits, _, - or ."
+	}
+	return ""
+}
@@ -0,0 +1,61 @@
+package gate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+func TestValidator(t *testing.T) {
+	cli := newTestHTTPClient(t)
+	v := NewValidator(NewPairRules(cli, 0, zap.NewExample()), cli)
+	v.SetClock(func() time.Time { return time.Unix(1700000000, 0) })
+	cli.SetValidator(v)
+
+	_, err := cli.NewOrder("bad text", "NEW_USDT", OrderTypeLimit, "", "sell", "0.00001", "1.001")
+	var e *ValidationError
+	if !errors.As(err, &e) {
+		t.Fatalf("got %v, want validation error", err)
+	}
+	for _, reason := range []string{ReasonInvalidText, ReasonNotTradable, ReasonNotStarted, ReasonPrecision, ReasonAmountTooSmall, ReasonNotionalTooSmall} {
+		if !e.Has(reason) {
+			t.Fatalf("missing %s in %v", reason, e)
+		}
+	}
+
+	// 文本超过 28 字节
+	_, err = cli.NewOrder("t-0123456789012345678901234567890", "BTC_USDT", OrderTypeLimit, "", "buy", "0.01", "30000")
+	if !errors.As(err, &e) || len(e.Violations) != 1 || !e.Has(ReasonInvalidText) {
+		t.Fatalf("got %v, want invalid text", err)
+	}
+
+	_, err = cli.NewOrder("t-ok", "BTC_USDT", OrderTypeLimit, "", "buy", "1", "30000")
+	if !errors.As(err, &e) || !e.Has(ReasonInsufficientBalance) {
+		t.Fatalf("got %v, want insufficient balance", err)
+	}
+
+	// 批量下单逐个校验, 字段带订单序号
+	_, err = cli.BatchOrders([]*OrderRequest{
+		{Text: "t-a", CurrencyPair: "BTC_USDT", Side: SideBuy, Price: decimal.RequireFromString("30000"), Amount: decimal.RequireFromString("0.01")},
+		{Text: "t-b", CurrencyPair: "BTC_USDT", Side: SideBuy, Price: decimal.RequireFromString("30000"), Amount: decimal.RequireFromString("0.00001")},
+	})
+	if !errors.As(err, &e) || len(e.Violations) == 0 || !e.Has(ReasonNotionalTooSmall) {
+		t.Fatalf("got %v, want notional too small", err)
+	}
+	for _, item := range e.Violations {
+		if item.Field != "orders[1].amount" {
+			t.Fatalf("unexpected violation: %+v", item)
+		}
+	}
+
+	o, err := cli.NewOrder("t-ok", "BTC_USDT", OrderTypeLimit, "", "buy", "0.01", "30000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Text != "t-ok" {
+		t.Fatalf("unexpected order: %+v", o)
+	}
+}
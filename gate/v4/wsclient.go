@@ -1,62 +1,118 @@
 package gate
 
 import (
+	"bytes"
 	"encoding/json"
+	"net"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/icwl/go-exchange-api/wsrecord"
 	"github.com/pkg/errors"
-	"github.com/shopspring/decimal"
 	"go.uber.org/zap"
 )
 
 type WSClient struct {
 	url    string
-	cli    *websocket.Conn
 	stop   chan interface{}
 	lock   *sync.Mutex
 	wait   *sync.WaitGroup
 	logger *zap.Logger
+
+	// 当前连接, Read 无锁读取, 修改和写入时持有 lock
+	cli atomic.Pointer[websocket.Conn]
+
+	// websocket api 请求
+	reqID   uint64
+	timeout time.Duration
+	pending map[string]chan *WSResponse
+	plock   *sync.Mutex
+
+	// 当前订阅
+	subs  map[string]*Subscription
+	slock *sync.Mutex
+
+	// 登录凭证, 重连后重新登录
+	key    string
+	secret string
+
+	hb *heartbeat
+
+	// Read 复用的帧缓冲区
+	frame *bytes.Buffer
+	// 订单簿推送解析为定点数
+	fixedPoint bool
+	// 帧录制, 为 nil 时不录制
+	recorder *wsrecord.Writer
 }
 
 func NewWSClient(url string, logger *zap.Logger) *WSClient {
 	ws := &WSClient{
-		url:    url,
-		cli:    nil,
-		stop:   nil,
-		lock:   new(sync.Mutex),
-		wait:   new(sync.WaitGroup),
-		logger: logger,
+		url:     url,
+		stop:    nil,
+		lock:    new(sync.Mutex),
+		wait:    new(sync.WaitGroup),
+		logger:  logger,
+		timeout: 10 * time.Second,
+		pending: make(map[string]chan *WSResponse),
+		plock:   new(sync.Mutex),
+		subs:    make(map[string]*Subscription),
+		slock:   new(sync.Mutex),
+		hb:      &heartbeat{timeout: int64(120 * time.Second)},
+		frame:   new(bytes.Buffer),
 	}
 
 	return ws
 }
 
+// 定时发送心跳, 超过 pong 超时时间未收到 pong 时标记连接失效, 由 Read 重连
 func (c *WSClient) Ping(interval time.Duration) error {
+	// 与 Close 互斥, 避免 Close 之后 Add
+	c.lock.Lock()
+	if c.closed() {
+		c.lock.Unlock()
+		return nil
+	}
 	c.wait.Add(1)
+	c.lock.Unlock()
 	defer c.wait.Done()
 
 	var (
-		channel = "spot.ping"
-		logger  = c.logger
+		logger = c.logger
 	)
 
 	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
 	for {
 		select {
 		case <-c.stop:
 			return nil
 		case <-ticker.C:
-			if err := c.SendChannel(channel, nil); err != nil {
+			if atomic.LoadInt32(&c.hb.stale) == 1 || c.checkStale() {
+				continue
+			}
+			if err := c.sendPing(); err != nil {
 				logger.Error("Send.Ping", zap.Error(err))
-				return errors.WithStack(err)
+				c.markStale("ping failed")
 			}
 		}
 	}
 }
 
 func (c *WSClient) Connect() error {
+	if err := c.dial(); err != nil {
+		return err
+	}
+
+	c.stop = make(chan interface{}, 1)
+
+	return nil
+}
+
+func (c *WSClient) dial() error {
 	var (
 		logger = c.logger
 	)
@@ -68,92 +124,123 @@ func (c *WSClient) Connect() error {
 
 	logger.Info("connect websocket", zap.String("url", c.url))
 
-	c.cli = cli
-	c.stop = make(chan interface{}, 1)
+	// websocket 协议层 pong, payload 为 ping 发送时间
+	cli.SetPongHandler(func(appData string) error {
+		sentAt, _ := strconv.ParseInt(appData, 10, 64)
+		c.pong(sentAt)
+		return cli.SetReadDeadline(time.Now().Add(c.pongTimeout()))
+	})
+	atomic.StoreInt64(&c.hb.lastPong, time.Now().UnixNano())
+
+	c.lock.Lock()
+	c.cli.Store(cli)
+	c.lock.Unlock()
 
 	return nil
 }
 
+// 可以重复调用
 func (c *WSClient) Close() error {
 	c.lock.Lock()
-	defer c.lock.Unlock()
 
-	if c.cli == nil {
+	if c.closed() {
+		c.lock.Unlock()
 		return nil
 	}
 
 	close(c.stop)
-	if err := c.cli.Close(); err != nil {
-		if err.Error() != "tls: use of closed connection" {
-			return errors.WithStack(err)
-		}
+	var err error
+	if cli := c.cli.Swap(nil); cli != nil {
+		err = cli.Close()
 	}
+	c.lock.Unlock()
 
-	c.logger.Info("关闭WS成功")
-
-	c.cli = nil
 	c.wait.Wait()
 
+	if err != nil && !errors.Is(err, net.ErrClosed) && err.Error() != "tls: use of closed connection" {
+		return errors.WithStack(err)
+	}
+	c.logger.Info("关闭WS成功")
+
 	return nil
 }
 
-func (c *WSClient) Read() (interface{}, error) {
-	if err := c.cli.SetReadDeadline(time.Now().Add(120 * time.Second)); err != nil {
-		return nil, errors.WithStack(err)
+// 未连接或已 Close, 调用方需持有 c.lock
+func (c *WSClient) closed() bool {
+	if c.stop == nil {
+		return true
 	}
-
-	_, msg, err := c.cli.ReadMessage()
-	if err != nil {
-		return nil, errors.WithStack(err)
+	select {
+	case <-c.stop:
+		return true
+	default:
+		return false
 	}
+}
 
-	var raw struct {
-		Time    int             `json:"time"`
-		Channel string          `json:"channel"`
-		Event   string          `json:"event"`
-		Result  json.RawMessage `json:"result"`
+// 读取推送消息, 连接失效时自动重连并恢复订阅, 返回 *StaleEvent
+// 内部复用读缓冲区, 不能并发调用
+func (c *WSClient) Read() (interface{}, error) {
+	cli := c.cli.Load()
+	if cli == nil {
+		// 连接已被标记失效并关闭, 直接重连
+		if atomic.LoadInt32(&c.hb.stale) == 1 {
+			event, err := c.reconnect()
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			return event, nil
+		}
+		return nil, errors.New("websocket not connected")
 	}
 
-	if err := json.Unmarshal(msg, &raw); err != nil {
+	if err := cli.SetReadDeadline(time.Now().Add(c.pongTimeout())); err != nil {
 		return nil, errors.WithStack(err)
 	}
 
-	switch raw.Event {
-	case "update":
-		switch raw.Channel {
-		case "spot.order_book":
-			var dp struct {
-				T            int64                `json:"t"`
-				LastUpdateId int64                `json:"lastUpdateId"`
-				S            string               `json:"s"`
-				Bids         [][2]decimal.Decimal `json:"bids"`
-				Asks         [][2]decimal.Decimal `json:"asks"`
-			}
-			if err := json.Unmarshal(raw.Result, &dp); err != nil {
-				err = errors.Wrap(err, string(raw.Result))
-				return nil, errors.WithStack(err)
-			}
-
-			return &OrderBook{
-				Pair: dp.S,
-				Asks: dp.Asks,
-				Bids: dp.Bids,
-			}, nil
-		case "spot.pong":
+	msg, err := c.readFrame(cli)
+	if err != nil {
+		// 读超时同样视为连接失效
+		if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			c.markStale("read timeout")
+		}
+		if atomic.LoadInt32(&c.hb.stale) == 0 {
+			return nil, errors.WithStack(err)
+		}
+		event, err := c.reconnect()
+		if err != nil {
+			return nil, errors.WithStack(err)
 		}
+		return event, nil
 	}
-	return nil, nil
+	c.record(time.Now(), msg, nil)
+
+	return c.decode(msg)
+}
+
+// 读取一帧到复用缓冲区, 返回的切片在下一次调用前有效
+func (c *WSClient) readFrame(cli *websocket.Conn) ([]byte, error) {
+	_, r, err := cli.NextReader()
+	if err != nil {
+		return nil, err
+	}
+	c.frame.Reset()
+	if _, err := c.frame.ReadFrom(r); err != nil {
+		return nil, err
+	}
+	return c.frame.Bytes(), nil
 }
 
 func (c *WSClient) Send(msg []byte) error {
 	c.lock.Lock()
 	defer c.lock.Unlock()
 
-	if c.cli == nil {
+	cli := c.cli.Load()
+	if cli == nil {
 		return nil
 	}
 
-	if err := c.cli.WriteMessage(websocket.TextMessage, msg); err != nil {
+	if err := cli.WriteMessage(websocket.TextMessage, msg); err != nil {
 		c.logger.Error("WriteMessage", zap.Error(err), zap.String("msg", string(msg)))
 		return errors.WithStack(err)
 	}
@@ -175,6 +262,7 @@ func (c *WSClient) SendChannel(channel string, fields map[string]interface{}) er
 	return c.Send(msg)
 }
 
+// 发送订阅消息, 不记录到当前订阅列表, 需要记录时使用 Subscribe
 func (c *WSClient) Sub(channel string, payload []interface{}) error {
 	return c.SendChannel(channel, map[string]interface{}{
 		"event":   "subscribe",
@@ -182,7 +270,274 @@ func (c *WSClient) Sub(channel string, payload []interface{}) error {
 	})
 }
 
+func (c *WSClient) Unsub(channel string, payload []interface{}) error {
+	return c.SendChannel(channel, map[string]interface{}{
+		"event":   "unsubscribe",
+		"payload": payload,
+	})
+}
+
 func (c *WSClient) SubOrderBook(cp, level, interval string) error {
-	channel := "spot.order_book"
-	return c.Sub(channel, []interface{}{cp, level, interval})
+	return c.Subscribe([]*Subscription{{Channel: ChannelOrderBook, Payload: []string{cp, level, interval}}})
+}
+
+func (c *WSClient) UnsubOrderBook(cp, level, interval string) error {
+	return c.Unsubscribe([]*Subscription{{Channel: ChannelOrderBook, Payload: []string{cp, level, interval}}})
+}
+
+// 行情订阅
+func (c *WSClient) SubTickers(pairs []string) error {
+	return c.Subscribe(pairSubscriptions(ChannelTickers, pairs))
+}
+
+func (c *WSClient) UnsubTickers(pairs []string) error {
+	return c.Unsubscribe(pairSubscriptions(ChannelTickers, pairs))
+}
+
+// 逐笔成交订阅
+func (c *WSClient) SubTrades(pairs []string) error {
+	return c.Subscribe(pairSubscriptions(ChannelTrades, pairs))
+}
+
+func (c *WSClient) UnsubTrades(pairs []string) error {
+	return c.Unsubscribe(pairSubscriptions(ChannelTrades, pairs))
+}
+
+// K 线订阅
+// - groupSec K 线周期, CandleGroupSec 常量之一
+// - cp 交易对
+func (c *WSClient) SubCandlesticks(groupSec int, cp string) error {
+	interval, err := CandleInterval(groupSec)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return c.Subscribe([]*Subscription{{Channel: ChannelCandlesticks, Payload: []string{interval, cp}}})
+}
+
+func (c *WSClient) UnsubCandlesticks(groupSec int, cp string) error {
+	interval, err := CandleInterval(groupSec)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return c.Unsubscribe([]*Subscription{{Channel: ChannelCandlesticks, Payload: []string{interval, cp}}})
+}
+
+// 最优挂单订阅
+func (c *WSClient) SubBookTicker(pairs []string) error {
+	return c.Subscribe(pairSubscriptions(ChannelBookTicker, pairs))
+}
+
+func (c *WSClient) UnsubBookTicker(pairs []string) error {
+	return c.Unsubscribe(pairSubscriptions(ChannelBookTicker, pairs))
+}
+
+// websocket K 线周期
+var candleIntervals = map[int]string{
+	CandleGroupSecOneMinute:      "1m",
+	CandleGroupSecFiveMinutes:    "5m",
+	CandleGroupSecFifteenMinutes: "15m",
+	CandleGroupSecThirtyMinutes:  "30m",
+	CandleGroupSecOneHour:        "1h",
+	CandleGroupSecFourHours:      "4h",
+	CandleGroupSecEightHours:     "8h",
+	CandleGroupSecOneDay:         "1d",
+	CandleGroupSecOneWeek:        "7d",
+}
+
+// 将 CandleGroupSec 转换为 websocket K 线周期, websocket 不支持的周期返回错误
+func CandleInterval(groupSec int) (string, error) {
+	interval, ok := candleIntervals[groupSec]
+	if !ok {
+		return "", errors.Errorf("unsupported candlestick interval: %d", groupSec)
+	}
+	return interval, nil
+}
+
+// 设置 websocket api 请求超时时间
+func (c *WSClient) SetRequestTimeout(timeout time.Duration) {
+	c.timeout = timeout
+}
+
+// 发送 websocket api 请求并等待响应, 响应由 Read 分发, 调用期间需要保持 Read 循环
+// - channel 请求频道, 如 spot.order_place
+// - payload 请求内容, 会自动填充 req_id
+func (c *WSClient) Request(channel string, payload map[string]interface{}) (*WSResponse, error) {
+	reqID := strconv.FormatUint(atomic.AddUint64(&c.reqID, 1), 10)
+	payload["req_id"] = reqID
+
+	ch := make(chan *WSResponse, 1)
+	c.plock.Lock()
+	c.pending[reqID] = ch
+	c.plock.Unlock()
+
+	defer func() {
+		c.plock.Lock()
+		delete(c.pending, reqID)
+		c.plock.Unlock()
+	}()
+
+	if err := c.SendChannel(channel, map[string]interface{}{
+		"event":   "api",
+		"payload": payload,
+	}); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	timer := time.NewTimer(c.timeout)
+	defer timer.Stop()
+
+	select {
+	case resp := <-ch:
+		if resp.Data.Errs != nil {
+			c.logger.Error(channel, zap.String("req_id", reqID), zap.Error(resp.Data.Errs))
+			return nil, errors.WithStack(resp.Data.Errs)
+		}
+		return resp, nil
+	case <-timer.C:
+		err := errors.Errorf("%s req_id %s: request timeout", channel, reqID)
+		return nil, err
+	case <-c.stop:
+		err := errors.Errorf("%s req_id %s: connection closed", channel, reqID)
+		return nil, err
+	}
+}
+
+func (c *WSClient) reply(resp *WSResponse) {
+	// 下单请求会先返回确认回执, 等待最终结果
+	if resp.Ack {
+		c.logger.Info("Ack", zap.String("req_id", resp.RequestID), zap.String("channel", resp.Header.Channel))
+		return
+	}
+
+	c.plock.Lock()
+	ch, ok := c.pending[resp.RequestID]
+	c.plock.Unlock()
+	if !ok {
+		c.logger.Warn("Response", zap.String("req_id", resp.RequestID), zap.String("channel", resp.Header.Channel))
+		return
+	}
+
+	select {
+	case ch <- resp:
+	default:
+	}
+}
+
+func (c *WSClient) replyOrder(channel string, param map[string]interface{}) (*Order, error) {
+	resp, err := c.Request(channel, map[string]interface{}{
+		"req_param": param,
+	})
+	if err != nil {
+		c.logger.Error(channel, zap.Error(err))
+		return nil, errors.WithStack(err)
+	}
+
+	var reply *Order
+	if err := json.Unmarshal(resp.Data.Result, &reply); err != nil {
+		c.logger.Error(channel, zap.String("reply", string(resp.Data.Result)), zap.Error(err))
+		err := ErrResponseBody(resp.Data.Result)
+		return nil, errors.WithStack(err)
+	}
+
+	return reply, nil
+}
+
+// 登录, 下单相关的 websocket api 需要先登录
+func (c *WSClient) Login(key, secret string) error {
+	channel := "spot.login"
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	_, err := c.Request(channel, map[string]interface{}{
+		"api_key":   key,
+		"signature": WSSign(channel, "", timestamp, secret),
+		"timestamp": timestamp,
+	})
+	if err != nil {
+		c.logger.Error(channel, zap.Error(err))
+		return errors.WithStack(err)
+	}
+
+	c.lock.Lock()
+	c.key, c.secret = key, secret
+	c.lock.Unlock()
+
+	return nil
+}
+
+func (c *WSClient) credentials() (string, string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.key, c.secret
+}
+
+// 下单, 参数同 HTTPClient.NewOrder
+func (c *WSClient) NewOrder(text, pair, type_, account, side, amount, price string) (*Order, error) {
+	channel := "spot.order_place"
+	param := make(map[string]interface{})
+	if text != "" {
+		param["text"] = text
+	}
+	param["currency_pair"] = pair
+	if type_ != "" {
+		param["type"] = type_
+	}
+	if account != "" {
+		param["account"] = account
+	}
+	param["side"] = side
+	param["amount"] = amount
+	param["price"] = price
+
+	return c.replyOrder(channel, param)
+}
+
+// 撤单
+func (c *WSClient) CancelOrder(orderId, pair, account string) (*Order, error) {
+	channel := "spot.order_cancel"
+	param := make(map[string]interface{})
+	param["order_id"] = orderId
+	param["currency_pair"] = pair
+	if account != "" {
+		param["account"] = account
+	}
+
+	return c.replyOrder(channel, param)
+}
+
+// 修改订单价格或数量
+// - amount 新的交易数量, 与 price 至少指定一个
+// - price 新的交易价格
+// - amendText 用户备注修改原因
+func (c *WSClient) AmendOrder(orderId, pair, account, amount, price, amendText string) (*Order, error) {
+	channel := "spot.order_amend"
+	param := make(map[string]interface{})
+	param["order_id"] = orderId
+	param["currency_pair"] = pair
+	if account != "" {
+		param["account"] = account
+	}
+	if amount != "" {
+		param["amount"] = amount
+	}
+	if price != "" {
+		param["price"] = price
+	}
+	if amendText != "" {
+		param["amend_text"] = amendText
+	}
+
+	return c.replyOrder(channel, param)
+}
+
+// 查询订单
+func (c *WSClient) GetOrder(orderId, pair, account string) (*Order, error) {
+	channel := "spot.order_status"
+	param := make(map[string]interface{})
+	param["order_id"] = orderId
+	param["currency_pair"] = pair
+	if account != "" {
+		param["account"] = account
+	}
+
+	return c.replyOrder(channel, param)
 }
@@ -1,9 +1,14 @@
 package gate
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/gorilla/websocket"
 	"go.uber.org/zap"
 )
 
@@ -47,3 +52,160 @@ func TestWSClient_SubOrderBook(t *testing.T) {
 		}
 	}
 }
+
+func TestWSClient_NewOrder(t *testing.T) {
+	// 本地模拟 websocket api, 先返回确认回执再返回订单
+	srv := newTestWSServer(t, func(req *testWSRequest) []interface{} {
+		var payload struct {
+			ReqID    string            `json:"req_id"`
+			ReqParam map[string]string `json:"req_param"`
+		}
+		_ = json.Unmarshal(req.Payload, &payload)
+		reply := func(ack bool, result interface{}) interface{} {
+			return map[string]interface{}{
+				"request_id": payload.ReqID,
+				"ack":        ack,
+				"header":     map[string]interface{}{"status": "200", "channel": req.Channel},
+				"data":       map[string]interface{}{"result": result},
+			}
+		}
+		switch req.Channel {
+		case "spot.login":
+			return []interface{}{reply(false, map[string]interface{}{"uid": "1"})}
+		case "spot.order_place":
+			return []interface{}{
+				reply(true, map[string]interface{}{"req_id": payload.ReqID}),
+				reply(false, map[string]interface{}{
+					"id":            "1700000001",
+					"text":          payload.ReqParam["text"],
+					"currency_pair": payload.ReqParam["currency_pair"],
+					"side":          payload.ReqParam["side"],
+					"amount":        payload.ReqParam["amount"],
+					"price":         payload.ReqParam["price"],
+					"status":        OrderStatusOpen,
+					"create_time":   "1700000000",
+					"update_time":   "1700000000",
+				}),
+			}
+		}
+		return nil
+	})
+	defer srv.Close()
+
+	logger := zap.NewExample()
+	cli := NewWSClient("ws"+strings.TrimPrefix(srv.URL, "http"), logger)
+	if err := cli.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+
+	go func() {
+		for {
+			if _, err := cli.Read(); err != nil {
+				return
+			}
+		}
+	}()
+
+	if err := cli.Login("key", "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	order, err := cli.NewOrder("t-test", "BTC_USDT", OrderTypeLimit, AccountSpot, "buy", "0.1", "100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.ID != "1700000001" || order.Text != "t-test" || order.Status != OrderStatusOpen {
+		t.Fatalf("unexpected order: %+v", order)
+	}
+
+	t.Logf("Order : %+v", order)
+}
+
+type testWSRequest struct {
+	Channel string          `json:"channel"`
+	Event   string          `json:"event"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// 本地模拟 websocket 服务, 每条请求回复 handle 返回的推送
+func newTestWSServer(t *testing.T, handle func(req *testWSRequest) []interface{}) *httptest.Server {
+	upgrader := websocket.Upgrader{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		for {
+			var req *testWSRequest
+			if err := conn.ReadJSON(&req); err != nil {
+				return
+			}
+			for _, push := range handle(req) {
+				if err := conn.WriteJSON(push); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}
+	}))
+}
+
+func TestWSClient_SubCandlesticks(t *testing.T) {
+	srv := newTestWSServer(t, func(req *testWSRequest) []interface{} {
+		if req.Channel != "spot.candlesticks" || req.Event != "subscribe" {
+			return nil
+		}
+		var payload []string
+		_ = json.Unmarshal(req.Payload, &payload)
+		return []interface{}{map[string]interface{}{
+			"time":    1700000000,
+			"channel": "spot.candlesticks",
+			"event":   "update",
+			"result": map[string]interface{}{
+				"t": "1700000000",
+				"v": "2362.3",
+				"c": "30000.1",
+				"h": "30001",
+				"l": "29999",
+				"o": "30000",
+				"n": strings.Join(payload, "_"),
+				"a": "0.0787",
+				"w": false,
+			},
+		}}
+	})
+	defer srv.Close()
+
+	logger := zap.NewExample()
+	cli := NewWSClient("ws"+strings.TrimPrefix(srv.URL, "http"), logger)
+	if err := cli.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+
+	if err := cli.SubCandlesticks(CandleGroupSecTwoHours, "BTC_USDT"); err == nil {
+		t.Fatal("expected unsupported interval error")
+	}
+	if err := cli.SubCandlesticks(CandleGroupSecOneMinute, "BTC_USDT"); err != nil {
+		t.Fatal(err)
+	}
+
+	for {
+		msg, err := cli.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		candle, ok := msg.(*Candlestick)
+		if !ok {
+			continue
+		}
+		if candle.Pair != "BTC_USDT" || candle.Interval != "1m" || candle.Time != 1700000000 {
+			t.Fatalf("unexpected candlestick: %+v", candle)
+		}
+		t.Logf("K 线 : %+v", candle)
+		return
+	}
+}
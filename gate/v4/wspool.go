@@ -0,0 +1,403 @@
+package gate
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/icwl/go-exchange-api/wsrecord"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+var ErrPoolFull = errors.New("websocket pool is full")
+
+// websocket 连接池, 将订阅分散到多个连接, 所有连接的推送合并到一个 channel
+// 同一个订阅 (channel + payload) 只会分配到一个连接, 其推送保持顺序
+// 同一交易对的不同 channel 可能分配到不同连接, 如 order_book_update 和 trades 之间的先后顺序不保证
+type WSPool struct {
+	url      string
+	size     int
+	limit    int
+	interval time.Duration
+	timeout  time.Duration
+	logger   *zap.Logger
+	recorder *wsrecord.Writer
+
+	lock  *sync.Mutex
+	conns []*WSClient
+	// 订阅 Key -> 连接序号
+	owner map[string]int
+	// 全部订阅, 包括连接断开后暂未分配的订阅
+	subs map[string]*Subscription
+
+	events chan interface{}
+	stop   chan interface{}
+	wait   *sync.WaitGroup
+}
+
+// - size 连接数量
+// - limit 单个连接最大订阅数量
+func NewWSPool(url string, size, limit int, logger *zap.Logger) (*WSPool, error) {
+	if size <= 0 {
+		return nil, errors.New("websocket pool size must be positive")
+	}
+	if limit <= 0 {
+		return nil, errors.New("websocket pool limit must be positive")
+	}
+	return &WSPool{
+		url:      url,
+		size:     size,
+		limit:    limit,
+		interval: 10 * time.Second,
+		logger:   logger,
+		lock:     new(sync.Mutex),
+		conns:    make([]*WSClient, size),
+		owner:    make(map[string]int),
+		subs:     make(map[string]*Subscription),
+		events:   make(chan interface{}, 1024),
+		wait:     new(sync.WaitGroup),
+	}, nil
+}
+
+// 设置心跳间隔和 pong 超时时间, 需要在 Connect 之前调用
+func (p *WSPool) SetHeartbeat(interval, timeout time.Duration) {
+	p.interval = interval
+	p.timeout = timeout
+}
+
+// 所有连接共用一个帧录制, 需要在 Connect 之前调用
+func (p *WSPool) SetRecorder(w *wsrecord.Writer) {
+	p.recorder = w
+}
+
+func (p *WSPool) Connect() error {
+	p.stop = make(chan interface{})
+	for i := 0; i < p.size; i++ {
+		cli, err := p.dial()
+		if err != nil {
+			_ = p.Close()
+			return errors.WithStack(err)
+		}
+		p.lock.Lock()
+		p.conns[i] = cli
+		p.lock.Unlock()
+		p.start(i, cli)
+	}
+	return nil
+}
+
+func (p *WSPool) Close() error {
+	p.lock.Lock()
+	if p.stop == nil {
+		p.lock.Unlock()
+		return nil
+	}
+	select {
+	case <-p.stop:
+		p.lock.Unlock()
+		return nil
+	default:
+	}
+	close(p.stop)
+	conns := make([]*WSClient, 0, len(p.conns))
+	for i, cli := range p.conns {
+		if cli != nil {
+			conns = append(conns, cli)
+			p.conns[i] = nil
+		}
+	}
+	p.lock.Unlock()
+
+	for _, cli := range conns {
+		if err := cli.Close(); err != nil {
+			p.logger.Error("pool close", zap.Error(err))
+		}
+	}
+	p.wait.Wait()
+	close(p.events)
+	return nil
+}
+
+// 所有连接合并后的推送, Close 后关闭
+func (p *WSPool) Events() <-chan interface{} {
+	return p.events
+}
+
+// 订阅并分配到负载最小的连接, 容量不足时返回 ErrPoolFull
+func (p *WSPool) Subscribe(subs []*Subscription) error {
+	p.lock.Lock()
+	for _, s := range subs {
+		p.subs[s.Key()] = s.clone()
+	}
+	p.lock.Unlock()
+
+	unassigned, err := p.assign(subs)
+	if len(unassigned) > 0 {
+		p.lock.Lock()
+		for _, s := range unassigned {
+			delete(p.subs, s.Key())
+		}
+		p.lock.Unlock()
+		return errors.Wrapf(ErrPoolFull, "%d subscriptions unassigned", len(unassigned))
+	}
+	return err
+}
+
+func (p *WSPool) Unsubscribe(subs []*Subscription) error {
+	group := make(map[int][]*Subscription)
+	p.lock.Lock()
+	for _, s := range subs {
+		if i, ok := p.owner[s.Key()]; ok {
+			group[i] = append(group[i], s)
+			delete(p.owner, s.Key())
+		}
+		delete(p.subs, s.Key())
+	}
+	conns := append([]*WSClient(nil), p.conns...)
+	p.lock.Unlock()
+
+	for i, list := range group {
+		if conns[i] == nil {
+			continue
+		}
+		if err := conns[i].Unsubscribe(list); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// 将当前订阅同步为目标订阅, 只发送差异部分
+func (p *WSPool) Sync(desired []*Subscription) error {
+	sub, unsub := Diff(p.Subscriptions(), desired)
+	if len(unsub) > 0 {
+		if err := p.Unsubscribe(unsub); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	if len(sub) > 0 {
+		if err := p.Subscribe(sub); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// 订单簿订阅
+func (p *WSPool) SubOrderBook(pairs []string, level, interval string) error {
+	return p.Subscribe(orderBookSubscriptions(pairs, level, interval))
+}
+
+func (p *WSPool) UnsubOrderBook(pairs []string, level, interval string) error {
+	return p.Unsubscribe(orderBookSubscriptions(pairs, level, interval))
+}
+
+// 全部订阅, 按 Key 排序
+func (p *WSPool) Subscriptions() []*Subscription {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	subs := make([]*Subscription, 0, len(p.subs))
+	for _, s := range p.subs {
+		subs = append(subs, s.clone())
+	}
+	sort.Slice(subs, func(i, j int) bool {
+		return subs[i].Key() < subs[j].Key()
+	})
+	return subs
+}
+
+// 各连接的订阅数量, 断开的连接为 -1
+func (p *WSPool) Load() []int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.load()
+}
+
+func (p *WSPool) load() []int {
+	load := make([]int, len(p.conns))
+	for i, cli := range p.conns {
+		if cli == nil {
+			load[i] = -1
+		}
+	}
+	for _, i := range p.owner {
+		if load[i] >= 0 {
+			load[i]++
+		}
+	}
+	return load
+}
+
+// 将订阅分配到负载最小的连接, 返回未能分配的订阅
+func (p *WSPool) assign(subs []*Subscription) ([]*Subscription, error) {
+	var (
+		group      = make(map[int][]*Subscription)
+		unassigned = make([]*Subscription, 0)
+	)
+
+	p.lock.Lock()
+	load := p.load()
+	for _, s := range subs {
+		if i, ok := p.owner[s.Key()]; ok && p.conns[i] != nil {
+			// 已分配的订阅, 在原连接重新订阅
+			group[i] = append(group[i], s)
+			continue
+		}
+		// 未分配或原连接已断开, 分配到负载最小的连接
+		delete(p.owner, s.Key())
+		best := -1
+		for i, n := range load {
+			if n < 0 || n >= p.limit {
+				continue
+			}
+			if best < 0 || n < load[best] {
+				best = i
+			}
+		}
+		if best < 0 {
+			unassigned = append(unassigned, s)
+			continue
+		}
+		load[best]++
+		p.owner[s.Key()] = best
+		group[best] = append(group[best], s)
+	}
+	conns := append([]*WSClient(nil), p.conns...)
+	p.lock.Unlock()
+
+	for i, list := range group {
+		if err := conns[i].Subscribe(list); err != nil {
+			return unassigned, errors.WithStack(err)
+		}
+	}
+	return unassigned, nil
+}
+
+// 未分配连接的订阅
+func (p *WSPool) unassigned() []*Subscription {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	subs := make([]*Subscription, 0)
+	for key, s := range p.subs {
+		if _, ok := p.owner[key]; !ok {
+			subs = append(subs, s)
+		}
+	}
+	return subs
+}
+
+func (p *WSPool) dial() (*WSClient, error) {
+	cli := NewWSClient(p.url, p.logger)
+	if p.timeout > 0 {
+		cli.SetPongTimeout(p.timeout)
+	}
+	cli.SetRecorder(p.recorder)
+	if err := cli.Connect(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	go cli.Ping(p.interval)
+	return cli, nil
+}
+
+func (p *WSPool) start(i int, cli *WSClient) {
+	p.wait.Add(1)
+	go p.read(i, cli)
+}
+
+func (p *WSPool) read(i int, cli *WSClient) {
+	defer p.wait.Done()
+
+	for {
+		msg, err := cli.Read()
+		if err != nil {
+			select {
+			case <-p.stop:
+				return
+			default:
+			}
+			p.logger.Error("pool read", zap.Int("conn", i), zap.Error(err))
+			p.down(i, cli)
+			return
+		}
+		if msg == nil {
+			continue
+		}
+		select {
+		case p.events <- msg:
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// 连接不可恢复时, 将其订阅转移到其他连接, 并在后台重建连接
+// 转移在该连接的读循环退出后进行, 不会与新连接的推送交错
+func (p *WSPool) down(i int, cli *WSClient) {
+	_ = cli.Close()
+
+	p.lock.Lock()
+	if p.conns[i] == cli {
+		p.conns[i] = nil
+	}
+	orphans := make([]*Subscription, 0)
+	for key, owner := range p.owner {
+		if owner == i {
+			delete(p.owner, key)
+			orphans = append(orphans, p.subs[key])
+		}
+	}
+	p.lock.Unlock()
+
+	unassigned, err := p.assign(orphans)
+	if err != nil {
+		p.logger.Error("pool rebalance", zap.Int("conn", i), zap.Error(err))
+	}
+	if len(unassigned) > 0 {
+		p.logger.Warn("pool rebalance", zap.Int("conn", i), zap.Int("unassigned", len(unassigned)))
+	}
+
+	p.wait.Add(1)
+	go p.revive(i)
+}
+
+func (p *WSPool) revive(i int) {
+	defer p.wait.Done()
+
+	backoff := time.Second
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-time.After(backoff):
+		}
+
+		cli, err := p.dial()
+		if err != nil {
+			p.logger.Error("pool redial", zap.Int("conn", i), zap.Error(err))
+			if backoff < 30*time.Second {
+				backoff *= 2
+			}
+			continue
+		}
+
+		p.lock.Lock()
+		select {
+		case <-p.stop:
+			p.lock.Unlock()
+			_ = cli.Close()
+			return
+		default:
+		}
+		p.conns[i] = cli
+		p.lock.Unlock()
+		p.start(i, cli)
+
+		if _, err := p.assign(p.unassigned()); err != nil {
+			p.logger.Error("pool rebalance", zap.Int("conn", i), zap.Error(err))
+		}
+		return
+	}
+}
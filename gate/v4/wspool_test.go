@@ -0,0 +1,143 @@
+package gate
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+func TestWSPool(t *testing.T) {
+	var (
+		lock sync.Mutex
+		// 交易对 -> 服务端连接
+		conns = make(map[string]*websocket.Conn)
+	)
+
+	// 每个订单簿订阅推送一条深度
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		for {
+			var req struct {
+				Channel string        `json:"channel"`
+				Event   string        `json:"event"`
+				Payload []interface{} `json:"payload"`
+			}
+			if err := conn.ReadJSON(&req); err != nil {
+				return
+			}
+			if req.Channel != ChannelOrderBook || req.Event != "subscribe" {
+				continue
+			}
+			pair := req.Payload[0].(string)
+			lock.Lock()
+			conns[pair] = conn
+			err := conn.WriteJSON(map[string]interface{}{
+				"time":    time.Now().Unix(),
+				"channel": ChannelOrderBook,
+				"event":   "update",
+				"result": map[string]interface{}{
+					"s":    pair,
+					"asks": [][]string{{"30001", "1"}},
+					"bids": [][]string{{"30000", "1"}},
+				},
+			})
+			lock.Unlock()
+			if err != nil {
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+
+	logger := zap.NewExample()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+	if _, err := NewWSPool(url, 3, 0, logger); err == nil {
+		t.Fatal("expected error for zero limit")
+	}
+	pool, err := NewWSPool(url, 3, 3, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.SetHeartbeat(time.Second, 5*time.Second)
+	if err := pool.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	pairs := []string{"BTC_USDT", "ETH_USDT", "SOL_USDT", "DOGE_USDT"}
+	if err := pool.SubOrderBook(pairs, "10", "100ms"); err != nil {
+		t.Fatal(err)
+	}
+	if got := pool.Load(); got[0] != 2 || got[1] != 1 || got[2] != 1 {
+		t.Fatalf("unexpected load: %v", got)
+	}
+
+	// 等待 want 中每个交易对至少一条推送, 忽略其他交易对
+	expect := func(want ...string) {
+		pending := make(map[string]bool)
+		for _, pair := range want {
+			pending[pair] = true
+		}
+		timeout := time.After(2 * time.Second)
+		for len(pending) > 0 {
+			select {
+			case msg := <-pool.Events():
+				if ob, ok := msg.(*OrderBook); ok {
+					delete(pending, ob.Pair)
+				}
+			case <-timeout:
+				t.Fatalf("missing %v", pending)
+			}
+		}
+	}
+	expect(pairs...)
+
+	// 容量不足
+	more := []string{"A_USDT", "B_USDT", "C_USDT", "D_USDT", "E_USDT", "F_USDT"}
+	if err := pool.SubOrderBook(more, "10", "100ms"); errors.Cause(err) != ErrPoolFull {
+		t.Fatalf("got %v, want ErrPoolFull", err)
+	}
+	if err := pool.UnsubOrderBook(more, "10", "100ms"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 断开 BTC_USDT 所在连接, 其订阅转移到其他连接
+	lock.Lock()
+	_ = conns["BTC_USDT"].Close()
+	lock.Unlock()
+
+	expect("BTC_USDT")
+	total := 0
+	for _, n := range pool.Load() {
+		if n > 0 {
+			total += n
+		}
+		if n > 3 {
+			t.Fatalf("unexpected load after rebalance: %v", pool.Load())
+		}
+	}
+	if total != 4 {
+		t.Fatalf("unexpected load after rebalance: %v", pool.Load())
+	}
+
+	// Close 后重新订阅已分配的订阅不再使用已关闭的连接
+	if err := pool.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.SubOrderBook(pairs, "10", "100ms"); errors.Cause(err) != ErrPoolFull {
+		t.Fatalf("got %v, want ErrPoolFull", err)
+	}
+}
@@ -0,0 +1,143 @@
+// 快速扫描 json 对象的顶层字段, 不解析字段值
+package jsonscan
+
+import (
+	"github.com/pkg/errors"
+)
+
+var ErrSyntax = errors.New("jsonscan: invalid json")
+
+// 依次回调 msg 顶层对象的字段, key 不含引号且不做转义处理, value 为原始 json
+// fn 返回 false 时停止扫描
+func Object(msg []byte, fn func(key, value []byte) bool) error {
+	i := skipSpace(msg, 0)
+	if i >= len(msg) || msg[i] != '{' {
+		return ErrSyntax
+	}
+	i = skipSpace(msg, i+1)
+	if i < len(msg) && msg[i] == '}' {
+		return nil
+	}
+
+	for {
+		// key
+		if i >= len(msg) || msg[i] != '"' {
+			return ErrSyntax
+		}
+		end, err := skipString(msg, i)
+		if err != nil {
+			return err
+		}
+		key := msg[i+1 : end-1]
+
+		i = skipSpace(msg, end)
+		if i >= len(msg) || msg[i] != ':' {
+			return ErrSyntax
+		}
+		i = skipSpace(msg, i+1)
+
+		// value
+		end, err = skipValue(msg, i)
+		if err != nil {
+			return err
+		}
+		if !fn(key, msg[i:end]) {
+			return nil
+		}
+
+		i = skipSpace(msg, end)
+		if i >= len(msg) {
+			return ErrSyntax
+		}
+		switch msg[i] {
+		case ',':
+			i = skipSpace(msg, i+1)
+		case '}':
+			return nil
+		default:
+			return ErrSyntax
+		}
+	}
+}
+
+// 去掉字符串值的引号, 非字符串原样返回
+func Unquote(value []byte) []byte {
+	if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+func IsNull(value []byte) bool {
+	return len(value) == 4 && string(value) == "null"
+}
+
+func skipSpace(msg []byte, i int) int {
+	for i < len(msg) {
+		switch msg[i] {
+		case ' ', '\t', '\n', '\r':
+			i++
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+// 返回字符串结束引号之后的位置
+func skipString(msg []byte, i int) (int, error) {
+	for j := i + 1; j < len(msg); j++ {
+		switch msg[j] {
+		case '\\':
+			j++
+		case '"':
+			return j + 1, nil
+		}
+	}
+	return 0, ErrSyntax
+}
+
+// 返回值结束之后的位置, 对象和数组按括号深度跳过
+func skipValue(msg []byte, i int) (int, error) {
+	if i >= len(msg) {
+		return 0, ErrSyntax
+	}
+	switch msg[i] {
+	case '"':
+		return skipString(msg, i)
+	case '{', '[':
+		depth := 0
+		for j := i; j < len(msg); j++ {
+			switch msg[j] {
+			case '"':
+				end, err := skipString(msg, j)
+				if err != nil {
+					return 0, err
+				}
+				j = end - 1
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+				if depth == 0 {
+					return j + 1, nil
+				}
+			}
+		}
+		return 0, ErrSyntax
+	default:
+		// 数字, true, false, null
+		j := i
+		for j < len(msg) {
+			switch msg[j] {
+			case ',', '}', ']', ' ', '\t', '\n', '\r':
+				if j == i {
+					return 0, ErrSyntax
+				}
+				return j, nil
+			}
+			j++
+		}
+		return 0, ErrSyntax
+	}
+}
@@ -0,0 +1,37 @@
+package jsonscan
+
+import (
+	"testing"
+)
+
+func TestObject(t *testing.T) {
+	msg := []byte(` {"method":"depth.update", "data":{"a":[1,"]}"],"b":{"c":null}},"id":null,"s":"x\"y"} `)
+	got := make(map[string]string)
+	if err := Object(msg, func(key, value []byte) bool {
+		got[string(key)] = string(value)
+		return true
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"method": `"depth.update"`,
+		"data":   `{"a":[1,"]}"],"b":{"c":null}}`,
+		"id":     `null`,
+		"s":      `"x\"y"`,
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Fatalf("%s: got %s, want %s", k, got[k], v)
+		}
+	}
+	if !IsNull([]byte(got["id"])) || string(Unquote([]byte(got["method"]))) != "depth.update" {
+		t.Fatal("unexpected unquote")
+	}
+
+	for _, in := range []string{``, `[]`, `{"a"}`, `{"a":1`, `{"a":"1}`, `{"a":1 "b":2}`} {
+		if err := Object([]byte(in), func(key, value []byte) bool { return true }); err == nil {
+			t.Fatalf("%s: expected error", in)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+// 分页列表接口的统一遍历, 逐条返回记录并按需翻页
+//
+// 停止条件: 交易所没有下一页, 达到 SetMaxItems 的数量,
+// 或列表按时间倒序时遇到早于 SetTimeWindow 起始时间的记录
+package paging
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// 遍历结束
+var ErrDone = errors.New("paging: no more items")
+
+// 查询一页, 第二个返回值表示是否还有下一页
+type Fetch[T any] func(page int) ([]T, bool, error)
+
+type Iterator[T any] struct {
+	fetch Fetch[T]
+	// 下一次查询的页码
+	page int
+	// 已查询到最后一页或已满足停止条件
+	done bool
+	buf  []T
+	// 已返回的记录数量
+	count int
+	max   int
+
+	// 记录时间, 为 nil 时不支持时间范围
+	timeOf func(T) time.Time
+	// 列表是否按时间倒序
+	newestFirst bool
+	from, to    time.Time
+}
+
+// page 为起始页码, 小于 1 时从第 1 页开始
+func New[T any](page int, fetch Fetch[T]) *Iterator[T] {
+	if page < 1 {
+		page = 1
+	}
+	return &Iterator[T]{
+		fetch: fetch,
+		page:  page,
+	}
+}
+
+// 设置记录时间, 由接口实现调用, newestFirst 表示列表按时间倒序
+func (it *Iterator[T]) SetTimeOf(timeOf func(T) time.Time, newestFirst bool) {
+	it.timeOf = timeOf
+	it.newestFirst = newestFirst
+}
+
+// 最多返回 n 条记录, 0 为不限制
+func (it *Iterator[T]) SetMaxItems(n int) {
+	it.max = n
+}
+
+// 只返回时间在 [from, to] 内的记录, 零值为不限制
+// 列表按时间倒序时, 遇到早于 from 的记录后停止翻页, 否则只过滤
+func (it *Iterator[T]) SetTimeWindow(from, to time.Time) {
+	it.from, it.to = from, to
+}
+
+// 返回下一条记录, 遍历结束时返回 ErrDone
+// 每次翻页前检查 ctx, 不会中断进行中的请求; 请求失败时不翻页, 再次调用会重试当前页
+func (it *Iterator[T]) Next(ctx context.Context) (T, error) {
+	var zero T
+	for {
+		if it.max > 0 && it.count >= it.max {
+			return zero, ErrDone
+		}
+
+		if len(it.buf) > 0 {
+			item := it.buf[0]
+			it.buf = it.buf[1:]
+			if it.timeOf != nil {
+				at := it.timeOf(item)
+				if !it.to.IsZero() && at.After(it.to) {
+					continue
+				}
+				if !it.from.IsZero() && at.Before(it.from) {
+					if it.newestFirst {
+						it.done, it.buf = true, nil
+						return zero, ErrDone
+					}
+					continue
+				}
+			}
+			it.count++
+			return item, nil
+		}
+
+		if it.done {
+			return zero, ErrDone
+		}
+		if err := ctx.Err(); err != nil {
+			return zero, errors.WithStack(err)
+		}
+		items, hasNext, err := it.fetch(it.page)
+		if err != nil {
+			return zero, errors.WithStack(err)
+		}
+		it.page++
+		it.done = !hasNext
+		it.buf = items
+	}
+}
+
+// 遍历全部剩余记录, 出错时返回已取得的记录和错误
+func (it *Iterator[T]) All(ctx context.Context) ([]T, error) {
+	var items []T
+	for {
+		item, err := it.Next(ctx)
+		if errors.Is(err, ErrDone) {
+			return items, nil
+		}
+		if err != nil {
+			return items, errors.WithStack(err)
+		}
+		items = append(items, item)
+	}
+}
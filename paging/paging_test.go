@@ -0,0 +1,81 @@
+package paging
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type record struct {
+	ID int
+	At time.Time
+}
+
+// 共 7 条记录, 每页 3 条, 按时间倒序
+func newTestIterator(fetched *[]int, fail map[int]bool) *Iterator[*record] {
+	start := time.Unix(1700000000, 0)
+	it := New(0, func(page int) ([]*record, bool, error) {
+		if fail[page] {
+			delete(fail, page)
+			return nil, false, errors.New("temporary error")
+		}
+		*fetched = append(*fetched, page)
+		var items []*record
+		for i := (page - 1) * 3; i < page*3 && i < 7; i++ {
+			items = append(items, &record{ID: i, At: start.Add(-time.Duration(i) * time.Minute)})
+		}
+		return items, page*3 < 7, nil
+	})
+	it.SetTimeOf(func(r *record) time.Time { return r.At }, true)
+	return it
+}
+
+func TestIterator(t *testing.T) {
+	ctx := context.Background()
+
+	var fetched []int
+	items, err := newTestIterator(&fetched, nil).All(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 7 || items[6].ID != 6 || len(fetched) != 3 {
+		t.Fatalf("unexpected items: %d, pages %v", len(items), fetched)
+	}
+
+	// 达到数量后不再翻页
+	fetched = nil
+	it := newTestIterator(&fetched, nil)
+	it.SetMaxItems(4)
+	if items, _ := it.All(ctx); len(items) != 4 || len(fetched) != 2 {
+		t.Fatalf("unexpected items: %d, pages %v", len(items), fetched)
+	}
+
+	// 跳过晚于 to 的记录, 遇到早于 from 的记录后停止
+	fetched = nil
+	it = newTestIterator(&fetched, nil)
+	start := time.Unix(1700000000, 0)
+	it.SetTimeWindow(start.Add(-4*time.Minute), start.Add(-time.Minute))
+	items, _ = it.All(ctx)
+	if len(items) != 4 || items[0].ID != 1 || items[3].ID != 4 || len(fetched) != 2 {
+		t.Fatalf("unexpected items: %d, pages %v", len(items), fetched)
+	}
+
+	// 请求失败后重试同一页
+	fetched = nil
+	it = newTestIterator(&fetched, map[int]bool{2: true})
+	if items, err = it.All(ctx); err == nil || len(items) != 3 {
+		t.Fatalf("expected error after first page, got %d items, %v", len(items), err)
+	}
+	if items, err = it.All(ctx); err != nil || len(items) != 4 {
+		t.Fatalf("unexpected retry: %d items, %v", len(items), err)
+	}
+
+	// 翻页前检查 ctx
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := newTestIterator(&fetched, nil).Next(cancelled); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context canceled, got %v", err)
+	}
+}
@@ -0,0 +1,655 @@
+// 模拟交易撮合, 用于用实时行情试运行策略而不向交易所下单
+//
+// 订单按最近一次更新的深度撮合: 下单时作为 taker 吃掉对手盘, 未成交部分挂单
+// 之后每次深度更新时, 对手盘价格穿过挂单价格即按挂单价格作为 maker 成交
+// 深度只是外部订单簿的快照, 被模拟订单吃掉的数量在之后的快照中继续扣除,
+// 直到该价格的外部数量减少 (视为被吃掉的部分已从外部订单簿成交) 或该价格消失, 因此不会重复成交
+// 余额为虚拟余额, 买单手续费扣交易币种, 卖单手续费扣报价币种
+package paper
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	SideBuy  = "buy"
+	SideSell = "sell"
+
+	TypeLimit  = "limit"
+	TypeMarket = "market"
+
+	TimeInForceGTC      = "gtc"       // 一直有效直至取消
+	TimeInForceIOC      = "ioc"       // 立即成交或者取消
+	TimeInForceFOK      = "fok"       // 全部成交或者全部取消
+	TimeInForcePostOnly = "post_only" // 只做 maker, 会立即成交时拒绝
+
+	StatusOpen         = "open"
+	StatusPartFilled   = "part_filled"
+	StatusFilled       = "filled"
+	StatusCanceled     = "canceled"
+	StatusPartCanceled = "part_canceled"
+)
+
+var (
+	ErrMarketNotFound      = errors.New("paper: market not found")
+	ErrNoBook              = errors.New("paper: order book not available")
+	ErrInvalidOrder        = errors.New("paper: invalid order")
+	ErrInsufficientBalance = errors.New("paper: insufficient balance")
+	ErrPostOnly            = errors.New("paper: post only order would be filled")
+	ErrOrderNotFound       = errors.New("paper: order not found")
+	ErrOrderFinished       = errors.New("paper: order finished")
+)
+
+// 交易市场
+type Market struct {
+	// 市场名称, 如 BTCUSDT 或 BTC_USDT
+	Name string
+	// 交易币种
+	Base string
+	// 报价币种
+	Quote string
+	// 手续费率
+	MakerFeeRate decimal.Decimal
+	TakerFeeRate decimal.Decimal
+	// 最小交易数量, 为 0 时不限制
+	MinAmount decimal.Decimal
+	// 数量精度, 按报价币种金额下的市价买单按该精度截断成交数量
+	AmountPrecision int32
+}
+
+type Balance struct {
+	Ccy       string
+	Available decimal.Decimal
+	Frozen    decimal.Decimal
+}
+
+type Order struct {
+	ID       int64
+	ClientID string
+	Market   string
+	Side     string
+	Type     string
+	// 为空时为 gtc
+	TimeInForce string
+	// 委托价格, 市价单为 0
+	Price decimal.Decimal
+	// 委托数量, QuoteAmount 为 true 时为报价币种金额
+	Amount      decimal.Decimal
+	QuoteAmount bool
+	// 未成交数量, 单位同 Amount
+	Left decimal.Decimal
+	// 已成交的交易币种数量
+	Filled decimal.Decimal
+	// 已成交的报价币种金额
+	FilledValue decimal.Decimal
+	// 手续费, 买单为交易币种, 卖单为报价币种
+	Fee    decimal.Decimal
+	FeeCcy string
+	Status string
+	// 毫秒时间戳
+	CreatedAt int64
+	UpdatedAt int64
+
+	// 限价买单冻结的报价币种
+	frozen decimal.Decimal
+}
+
+func (o *Order) Finished() bool {
+	switch o.Status {
+	case StatusFilled, StatusCanceled, StatusPartCanceled:
+		return true
+	}
+	return false
+}
+
+func (o *Order) clone() *Order {
+	cp := *o
+	return &cp
+}
+
+type book struct {
+	// 扣除被模拟订单吃掉的数量后的深度
+	asks, bids [][2]decimal.Decimal
+	// 外部深度快照
+	rawAsks, rawBids [][2]decimal.Decimal
+	updatedAt        time.Time
+}
+
+type Engine struct {
+	lock     *sync.Mutex
+	clock    func() time.Time
+	markets  map[string]*Market
+	books    map[string]*book
+	balances map[string]*Balance
+	// 全部订单, 按创建顺序
+	orders  []*Order
+	orderID int64
+}
+
+func NewEngine() *Engine {
+	return &Engine{
+		lock:     new(sync.Mutex),
+		clock:    time.Now,
+		markets:  make(map[string]*Market),
+		books:    make(map[string]*book),
+		balances: make(map[string]*Balance),
+	}
+}
+
+// 设置订单时间的时钟, 默认 time.Now
+func (e *Engine) SetClock(clock func() time.Time) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.clock = clock
+}
+
+// 添加或替换市场
+func (e *Engine) AddMarket(m Market) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.markets[m.Name] = &m
+}
+
+func (e *Engine) Market(name string) (Market, bool) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	m, ok := e.markets[name]
+	if !ok {
+		return Market{}, false
+	}
+	return *m, true
+}
+
+// 设置可用余额
+func (e *Engine) SetBalance(ccy string, available decimal.Decimal) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.balance(ccy).Available = available
+}
+
+// 全部余额, 按币种排序
+func (e *Engine) Balances() []Balance {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	list := make([]Balance, 0, len(e.balances))
+	for _, b := range e.balances {
+		list = append(list, *b)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Ccy < list[j].Ccy })
+	return list
+}
+
+func (e *Engine) balance(ccy string) *Balance {
+	b, ok := e.balances[ccy]
+	if !ok {
+		b = &Balance{Ccy: ccy}
+		e.balances[ccy] = b
+	}
+	return b
+}
+
+// 深度最后更新时间, 没有深度时返回 false
+func (e *Engine) BookTime(market string) (time.Time, bool) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	b, ok := e.books[market]
+	if !ok {
+		return time.Time{}, false
+	}
+	return b.updatedAt, true
+}
+
+// 更新深度并撮合挂单, 返回状态变化的订单
+// 上一次快照中被吃掉的数量继续从同一价格扣除, 外部数量减少时扣除相应数量
+// - asks 卖方深度, 价格升序
+// - bids 买方深度, 价格降序
+func (e *Engine) UpdateBook(market string, asks, bids [][2]decimal.Decimal) []*Order {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	b := &book{
+		rawAsks:   append([][2]decimal.Decimal(nil), asks...),
+		rawBids:   append([][2]decimal.Decimal(nil), bids...),
+		updatedAt: e.clock(),
+	}
+	if prev, ok := e.books[market]; ok {
+		b.asks = consume(prev.rawAsks, prev.asks, asks)
+		b.bids = consume(prev.rawBids, prev.bids, bids)
+	} else {
+		b.asks = append([][2]decimal.Decimal(nil), asks...)
+		b.bids = append([][2]decimal.Decimal(nil), bids...)
+	}
+	e.books[market] = b
+
+	m, ok := e.markets[market]
+	if !ok {
+		return nil
+	}
+
+	// 挂单按价格优先时间优先成交
+	resting := make([]*Order, 0)
+	for _, o := range e.orders {
+		if o.Market == market && !o.Finished() {
+			resting = append(resting, o)
+		}
+	}
+	sort.SliceStable(resting, func(i, j int) bool {
+		if resting[i].Side != resting[j].Side {
+			return resting[i].Side == SideBuy
+		}
+		if resting[i].Side == SideBuy {
+			return resting[i].Price.GreaterThan(resting[j].Price)
+		}
+		return resting[i].Price.LessThan(resting[j].Price)
+	})
+
+	changed := make([]*Order, 0)
+	for _, o := range resting {
+		if e.match(m, b, o, true) {
+			changed = append(changed, o.clone())
+		}
+	}
+	return changed
+}
+
+// 从新快照中扣除上一次快照中已被吃掉且仍在外部深度中的数量
+// - prevRaw 上一次的外部深度快照
+// - prevLevels 上一次扣除后的剩余深度
+func consume(prevRaw, prevLevels, levels [][2]decimal.Decimal) [][2]decimal.Decimal {
+	left := make(map[string]decimal.Decimal, len(prevLevels))
+	for _, level := range prevLevels {
+		left[level[0].String()] = level[1]
+	}
+	// 价格 -> [外部数量, 已吃掉的数量]
+	taken := make(map[string][2]decimal.Decimal)
+	for _, level := range prevRaw {
+		key := level[0].String()
+		if used := level[1].Sub(left[key]); used.IsPositive() {
+			taken[key] = [2]decimal.Decimal{level[1], used}
+		}
+	}
+
+	list := make([][2]decimal.Decimal, 0, len(levels))
+	for _, level := range levels {
+		qty := level[1]
+		if t, ok := taken[level[0].String()]; ok {
+			used := t[1]
+			if qty.LessThan(t[0]) {
+				used = used.Sub(t[0].Sub(qty))
+			}
+			if used.IsPositive() {
+				qty = qty.Sub(decimal.Min(used, qty))
+			}
+		}
+		if qty.IsPositive() {
+			list = append(list, [2]decimal.Decimal{level[0], qty})
+		}
+	}
+	return list
+}
+
+// 下单, 立即按当前深度撮合
+// 需要先通过 AddMarket 添加市场并通过 UpdateBook 更新深度
+func (e *Engine) Place(o Order) (*Order, error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	m, ok := e.markets[o.Market]
+	if !ok {
+		return nil, errors.WithStack(ErrMarketNotFound)
+	}
+	b, ok := e.books[o.Market]
+	if !ok {
+		return nil, errors.WithStack(ErrNoBook)
+	}
+	if err := validate(m, &o); err != nil {
+		return nil, err
+	}
+
+	now := e.clock().UnixMilli()
+	e.orderID++
+	o.ID = e.orderID
+	o.Left = o.Amount
+	o.Filled, o.FilledValue, o.Fee = decimal.Zero, decimal.Zero, decimal.Zero
+	o.FeeCcy = m.Quote
+	if o.Side == SideBuy {
+		o.FeeCcy = m.Base
+	}
+	o.Status = StatusOpen
+	o.CreatedAt, o.UpdatedAt = now, now
+	o.frozen = decimal.Zero
+
+	if err := e.freeze(m, &o); err != nil {
+		return nil, err
+	}
+
+	ord := &o
+	switch {
+	case o.TimeInForce == TimeInForcePostOnly && crossable(b, ord).IsPositive():
+		e.release(m, ord)
+		return nil, errors.WithStack(ErrPostOnly)
+	case o.TimeInForce == TimeInForceFOK && !o.QuoteAmount && crossable(b, ord).LessThan(o.Left):
+		// 无法全部成交, 直接撤销
+	default:
+		e.match(m, b, ord, false)
+	}
+
+	switch {
+	case ord.Status == StatusFilled:
+		// 已在 match 中结束
+	case o.Type == TypeLimit && (o.TimeInForce == TimeInForceGTC || o.TimeInForce == TimeInForcePostOnly):
+		// 剩余部分挂单
+	default:
+		e.cancel(m, ord, now)
+	}
+
+	e.orders = append(e.orders, ord)
+	return ord.clone(), nil
+}
+
+func validate(m *Market, o *Order) error {
+	if o.Side != SideBuy && o.Side != SideSell {
+		return errors.Wrap(ErrInvalidOrder, "side")
+	}
+	switch o.Type {
+	case TypeLimit:
+		if !o.Price.IsPositive() {
+			return errors.Wrap(ErrInvalidOrder, "price")
+		}
+		if o.QuoteAmount {
+			return errors.Wrap(ErrInvalidOrder, "limit order amount must be base amount")
+		}
+	case TypeMarket:
+		if o.QuoteAmount && o.Side != SideBuy {
+			return errors.Wrap(ErrInvalidOrder, "quote amount only for market buy")
+		}
+		if o.TimeInForce == "" || o.TimeInForce == TimeInForceGTC {
+			o.TimeInForce = TimeInForceIOC
+		}
+		if o.TimeInForce == TimeInForcePostOnly {
+			return errors.Wrap(ErrInvalidOrder, "post only market order")
+		}
+	default:
+		return errors.Wrap(ErrInvalidOrder, "type")
+	}
+	switch o.TimeInForce {
+	case "":
+		o.TimeInForce = TimeInForceGTC
+	case TimeInForceGTC, TimeInForceIOC, TimeInForceFOK, TimeInForcePostOnly:
+	default:
+		return errors.Wrap(ErrInvalidOrder, "time in force")
+	}
+	if !o.Amount.IsPositive() || !o.QuoteAmount && o.Amount.LessThan(m.MinAmount) {
+		return errors.Wrap(ErrInvalidOrder, "amount")
+	}
+	return nil
+}
+
+// 冻结限价单余额, 市价单只检查余额, 按成交逐笔扣除
+func (e *Engine) freeze(m *Market, o *Order) error {
+	switch {
+	case o.Type == TypeMarket:
+		ccy, need := m.Base, o.Left
+		if o.Side == SideBuy {
+			ccy = m.Quote
+		}
+		if o.Side == SideBuy && !o.QuoteAmount {
+			need = decimal.Zero
+		}
+		if e.balance(ccy).Available.LessThan(need) {
+			return errors.WithStack(ErrInsufficientBalance)
+		}
+	case o.Side == SideBuy:
+		q := e.balance(m.Quote)
+		cost := o.Left.Mul(o.Price)
+		if q.Available.LessThan(cost) {
+			return errors.WithStack(ErrInsufficientBalance)
+		}
+		q.Available = q.Available.Sub(cost)
+		q.Frozen = q.Frozen.Add(cost)
+		o.frozen = cost
+	default:
+		base := e.balance(m.Base)
+		if base.Available.LessThan(o.Left) {
+			return errors.WithStack(ErrInsufficientBalance)
+		}
+		base.Available = base.Available.Sub(o.Left)
+		base.Frozen = base.Frozen.Add(o.Left)
+	}
+	return nil
+}
+
+// 退回剩余冻结余额
+func (e *Engine) release(m *Market, o *Order) {
+	if o.Type == TypeMarket {
+		return
+	}
+	if o.Side == SideBuy {
+		q := e.balance(m.Quote)
+		q.Frozen = q.Frozen.Sub(o.frozen)
+		q.Available = q.Available.Add(o.frozen)
+		o.frozen = decimal.Zero
+		return
+	}
+	base := e.balance(m.Base)
+	base.Frozen = base.Frozen.Sub(o.Left)
+	base.Available = base.Available.Add(o.Left)
+}
+
+func crosses(o *Order, price decimal.Decimal) bool {
+	if o.Type == TypeMarket {
+		return true
+	}
+	if o.Side == SideBuy {
+		return o.Price.GreaterThanOrEqual(price)
+	}
+	return o.Price.LessThanOrEqual(price)
+}
+
+func opposite(b *book, side string) *[][2]decimal.Decimal {
+	if side == SideBuy {
+		return &b.asks
+	}
+	return &b.bids
+}
+
+// 可立即成交的交易币种数量
+func crossable(b *book, o *Order) decimal.Decimal {
+	total := decimal.Zero
+	for _, level := range *opposite(b, o.Side) {
+		if !crosses(o, level[0]) {
+			break
+		}
+		total = total.Add(level[1])
+	}
+	return total
+}
+
+// 按深度撮合, 成交数量从深度中扣除, 返回是否有成交
+// - maker 为 true 时按委托价格成交, 否则按深度价格成交
+func (e *Engine) match(m *Market, b *book, o *Order, maker bool) bool {
+	levels := opposite(b, o.Side)
+	now := e.clock().UnixMilli()
+	filled, dust := false, false
+	for len(*levels) > 0 && o.Left.IsPositive() {
+		level := &(*levels)[0]
+		if !crosses(o, level[0]) {
+			break
+		}
+		price := level[0]
+		if maker {
+			price = o.Price
+		}
+		qty := level[1]
+		if o.QuoteAmount {
+			qty = decimal.Min(qty, o.Left.Div(price).Truncate(m.AmountPrecision))
+			if !qty.IsPositive() {
+				// 剩余金额不足以买入最小精度
+				dust = true
+				break
+			}
+		} else {
+			qty = decimal.Min(qty, o.Left)
+		}
+		if o.Type == TypeMarket && o.Side == SideBuy {
+			// 市价买单按可用余额限制成交数量
+			affordable := e.balance(m.Quote).Available.Div(price).Truncate(m.AmountPrecision)
+			qty = decimal.Min(qty, affordable)
+			if !qty.IsPositive() {
+				break
+			}
+		}
+
+		e.fill(m, o, qty, price, maker, now)
+		filled = true
+
+		level[1] = level[1].Sub(qty)
+		if level[1].IsZero() {
+			*levels = (*levels)[1:]
+		}
+	}
+
+	if !filled {
+		return false
+	}
+	if o.Left.IsZero() || dust {
+		o.Status = StatusFilled
+		e.release(m, o)
+	} else {
+		o.Status = StatusPartFilled
+	}
+	return true
+}
+
+// 结算一笔成交
+func (e *Engine) fill(m *Market, o *Order, qty, price decimal.Decimal, maker bool, now int64) {
+	value := qty.Mul(price)
+	rate := m.TakerFeeRate
+	if maker {
+		rate = m.MakerFeeRate
+	}
+
+	if o.Side == SideBuy {
+		q := e.balance(m.Quote)
+		if o.Type == TypeMarket {
+			q.Available = q.Available.Sub(value)
+		} else {
+			reserved := qty.Mul(o.Price)
+			q.Frozen = q.Frozen.Sub(reserved)
+			o.frozen = o.frozen.Sub(reserved)
+			q.Available = q.Available.Add(reserved.Sub(value))
+		}
+		fee := qty.Mul(rate)
+		base := e.balance(m.Base)
+		base.Available = base.Available.Add(qty.Sub(fee))
+		o.Fee = o.Fee.Add(fee)
+	} else {
+		base := e.balance(m.Base)
+		if o.Type == TypeMarket {
+			base.Available = base.Available.Sub(qty)
+		} else {
+			base.Frozen = base.Frozen.Sub(qty)
+		}
+		fee := value.Mul(rate)
+		q := e.balance(m.Quote)
+		q.Available = q.Available.Add(value.Sub(fee))
+		o.Fee = o.Fee.Add(fee)
+	}
+
+	if o.QuoteAmount {
+		o.Left = o.Left.Sub(value)
+	} else {
+		o.Left = o.Left.Sub(qty)
+	}
+	o.Filled = o.Filled.Add(qty)
+	o.FilledValue = o.FilledValue.Add(value)
+	o.UpdatedAt = now
+}
+
+func (e *Engine) cancel(m *Market, o *Order, now int64) {
+	e.release(m, o)
+	if o.Filled.IsPositive() {
+		o.Status = StatusPartCanceled
+	} else {
+		o.Status = StatusCanceled
+	}
+	o.UpdatedAt = now
+}
+
+// 撤单并退回冻结余额
+func (e *Engine) Cancel(market string, id int64) (*Order, error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	o := e.find(market, id)
+	if o == nil {
+		return nil, errors.WithStack(ErrOrderNotFound)
+	}
+	if o.Finished() {
+		return nil, errors.WithStack(ErrOrderFinished)
+	}
+	e.cancel(e.markets[market], o, e.clock().UnixMilli())
+	return o.clone(), nil
+}
+
+func (e *Engine) find(market string, id int64) *Order {
+	for _, o := range e.orders {
+		if o.ID == id && o.Market == market {
+			return o
+		}
+	}
+	return nil
+}
+
+// 查询订单
+func (e *Engine) Order(market string, id int64) (*Order, error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	o := e.find(market, id)
+	if o == nil {
+		return nil, errors.WithStack(ErrOrderNotFound)
+	}
+	return o.clone(), nil
+}
+
+// 按客户端 ID 查询订单, 多个订单使用同一 ID 时返回最新的订单
+func (e *Engine) OrderByClientID(market, clientID string) (*Order, error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	for i := len(e.orders) - 1; i >= 0; i-- {
+		if o := e.orders[i]; o.Market == market && clientID != "" && o.ClientID == clientID {
+			return o.clone(), nil
+		}
+	}
+	return nil, errors.WithStack(ErrOrderNotFound)
+}
+
+// 按条件筛选订单, 按创建时间倒序
+// - market 为空时不限市场
+// - side 为空时不限方向
+// - finished 为 true 时返回已完成订单, 否则返回挂单
+func (e *Engine) Orders(market, side string, finished bool) []*Order {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	list := make([]*Order, 0)
+	for i := len(e.orders) - 1; i >= 0; i-- {
+		o := e.orders[i]
+		if o.Finished() != finished {
+			continue
+		}
+		if market != "" && o.Market != market || side != "" && o.Side != side {
+			continue
+		}
+		list = append(list, o.clone())
+	}
+	return list
+}
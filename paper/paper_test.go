@@ -0,0 +1,197 @@
+package paper
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+func d(s string) decimal.Decimal {
+	return decimal.RequireFromString(s)
+}
+
+func levels(pairs ...string) [][2]decimal.Decimal {
+	list := make([][2]decimal.Decimal, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		list = append(list, [2]decimal.Decimal{d(pairs[i]), d(pairs[i+1])})
+	}
+	return list
+}
+
+func newTestEngine() *Engine {
+	e := NewEngine()
+	e.AddMarket(Market{
+		Name:            "BTCUSDT",
+		Base:            "BTC",
+		Quote:           "USDT",
+		MakerFeeRate:    d("0.001"),
+		TakerFeeRate:    d("0.002"),
+		AmountPrecision: 4,
+	})
+	e.UpdateBook("BTCUSDT", levels("30000", "0.5", "30010", "1"), levels("29990", "1"))
+	return e
+}
+
+func balance(e *Engine, ccy string) Balance {
+	for _, b := range e.Balances() {
+		if b.Ccy == ccy {
+			return b
+		}
+	}
+	return Balance{Ccy: ccy}
+}
+
+func TestEngine_Taker(t *testing.T) {
+	e := newTestEngine()
+	e.SetBalance("USDT", d("100000"))
+
+	// 吃掉两档, 剩余部分挂单
+	o, err := e.Place(Order{Market: "BTCUSDT", Side: SideBuy, Type: TypeLimit, Price: d("30010"), Amount: d("2")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Status != StatusPartFilled || !o.Filled.Equal(d("1.5")) || !o.FilledValue.Equal(d("45010")) || !o.Fee.Equal(d("0.003")) {
+		t.Fatalf("unexpected order: %+v", o)
+	}
+	usdt := balance(e, "USDT")
+	if !usdt.Frozen.Equal(d("15005")) || !usdt.Available.Equal(d("39985")) {
+		t.Fatalf("unexpected balance: %+v", usdt)
+	}
+
+	// 同一深度快照中已被吃掉的数量不会重复成交
+	if _, err := e.Place(Order{Market: "BTCUSDT", Side: SideBuy, Type: TypeLimit, Price: d("30000"), Amount: d("0.1"), TimeInForce: TimeInForcePostOnly}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 市价买单按报价币种金额成交, 余额不足以买入最小精度时结束
+	if _, err := e.Cancel("BTCUSDT", o.ID); err != nil {
+		t.Fatal(err)
+	}
+	e.UpdateBook("BTCUSDT", levels("30000", "1"), nil)
+	m, err := e.Place(Order{Market: "BTCUSDT", Side: SideBuy, Type: TypeMarket, Amount: d("1000"), QuoteAmount: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Status != StatusFilled || !m.Filled.Equal(d("0.0333")) || !m.Left.Equal(d("1")) {
+		t.Fatalf("unexpected market order: %+v", m)
+	}
+}
+
+func TestEngine_Maker(t *testing.T) {
+	e := newTestEngine()
+	e.SetBalance("BTC", d("1"))
+
+	o, err := e.Place(Order{Market: "BTCUSDT", ClientID: "c-1", Side: SideSell, Type: TypeLimit, Price: d("30005"), Amount: d("1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Status != StatusOpen {
+		t.Fatalf("unexpected order: %+v", o)
+	}
+
+	// 买一价穿过挂单价格, 按挂单价格成交
+	changed := e.UpdateBook("BTCUSDT", levels("30010", "1"), levels("30008", "0.4", "29990", "1"))
+	if len(changed) != 1 || changed[0].Status != StatusPartFilled || !changed[0].Filled.Equal(d("0.4")) {
+		t.Fatalf("unexpected changed orders: %+v", changed)
+	}
+	usdt := balance(e, "USDT")
+	if !usdt.Available.Equal(d("11989.998")) {
+		t.Fatalf("unexpected balance: %+v", usdt)
+	}
+
+	cancelled, err := e.Cancel("BTCUSDT", o.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cancelled.Status != StatusPartCanceled {
+		t.Fatalf("unexpected order: %+v", cancelled)
+	}
+	if btc := balance(e, "BTC"); !btc.Available.Equal(d("0.6")) || !btc.Frozen.IsZero() {
+		t.Fatalf("unexpected balance: %+v", btc)
+	}
+	if got, err := e.OrderByClientID("BTCUSDT", "c-1"); err != nil || got.ID != o.ID {
+		t.Fatalf("got %+v %v", got, err)
+	}
+	if _, err := e.Cancel("BTCUSDT", o.ID); !errors.Is(err, ErrOrderFinished) {
+		t.Fatalf("got %v, want finished", err)
+	}
+}
+
+func TestEngine_UpdateBook(t *testing.T) {
+	e := newTestEngine()
+	e.SetBalance("BTC", d("1"))
+	o, err := e.Place(Order{Market: "BTCUSDT", Side: SideSell, Type: TypeLimit, Price: d("30005"), Amount: d("1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 外部数量不变或减少时, 已吃掉的数量不会重复成交, 只有新增的数量可以成交
+	steps := []struct {
+		bid    string
+		filled string
+	}{
+		{"0.4", "0.4"},
+		{"0.4", "0.4"},
+		{"0.6", "0.6"},
+		{"0.5", "0.6"},
+		{"0.9", "1"},
+	}
+	for i, step := range steps {
+		e.UpdateBook("BTCUSDT", levels("30010", "1"), levels("30008", step.bid))
+		got, err := e.Order("BTCUSDT", o.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !got.Filled.Equal(d(step.filled)) {
+			t.Fatalf("step %d: filled %s, want %s", i, got.Filled, step.filled)
+		}
+	}
+
+	// 价格消失后不再扣除
+	if len(e.books["BTCUSDT"].bids) != 0 {
+		t.Fatalf("unexpected book: %v", e.books["BTCUSDT"].bids)
+	}
+	e.UpdateBook("BTCUSDT", levels("30010", "1"), levels("29990", "1"))
+	e.UpdateBook("BTCUSDT", levels("30010", "1"), levels("30008", "0.9"))
+	if !e.books["BTCUSDT"].bids[0][1].Equal(d("0.9")) {
+		t.Fatalf("unexpected book: %v", e.books["BTCUSDT"].bids)
+	}
+}
+
+func TestEngine_Reject(t *testing.T) {
+	e := newTestEngine()
+	e.SetBalance("USDT", d("100"))
+	e.SetBalance("BTC", d("1"))
+
+	cases := []struct {
+		order Order
+		err   error
+	}{
+		{Order{Market: "ETHUSDT", Side: SideBuy, Type: TypeLimit, Price: d("1"), Amount: d("1")}, ErrMarketNotFound},
+		{Order{Market: "BTCUSDT", Side: SideBuy, Type: TypeLimit, Price: d("30000"), Amount: d("1")}, ErrInsufficientBalance},
+		{Order{Market: "BTCUSDT", Side: SideSell, Type: TypeLimit, Price: d("29990"), Amount: d("0.1"), TimeInForce: TimeInForcePostOnly}, ErrPostOnly},
+		{Order{Market: "BTCUSDT", Side: SideSell, Type: TypeLimit, Price: d("0"), Amount: d("0.1")}, ErrInvalidOrder},
+	}
+	for _, c := range cases {
+		if _, err := e.Place(c.order); !errors.Is(err, c.err) {
+			t.Fatalf("got %v, want %v", err, c.err)
+		}
+	}
+	if btc := balance(e, "BTC"); !btc.Available.Equal(d("1")) {
+		t.Fatalf("balance not released: %+v", btc)
+	}
+
+	// 无法全部成交的 fok 订单直接撤销
+	o, err := e.Place(Order{Market: "BTCUSDT", Side: SideSell, Type: TypeLimit, Price: d("29990"), Amount: d("1.5"), TimeInForce: TimeInForceFOK})
+	if err == nil {
+		t.Fatalf("got %+v, want insufficient balance", o)
+	}
+	o, err = e.Place(Order{Market: "BTCUSDT", Side: SideSell, Type: TypeLimit, Price: d("29995"), Amount: d("1"), TimeInForce: TimeInForceFOK})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Status != StatusCanceled || !o.Filled.IsZero() {
+		t.Fatalf("unexpected order: %+v", o)
+	}
+}
@@ -0,0 +1,316 @@
+// websocket 帧录制和读取, 用于离线复现推送序列
+//
+// 文件格式: 魔数 WSR1, 之后每帧依次为
+// 时间戳 (varint, 第一帧为 unix 纳秒, 之后为与上一帧的差值)
+// 原始帧长度 (uvarint) 和内容
+// 解压后长度 (uvarint) 和内容, 长度为 0 表示与原始帧相同
+package wsrecord
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// 单帧的最大长度, 读取时超过该长度视为文件损坏
+const maxFrameSize = 64 << 20
+
+var (
+	magic = []byte("WSR1")
+
+	ErrFormat = errors.New("wsrecord: invalid format")
+)
+
+// 一帧记录
+type Frame struct {
+	// 收到时间
+	At time.Time
+	// 原始帧, 如 coinex 为 gzip 压缩数据
+	Raw []byte
+	// 解压后的消息, 未压缩时与 Raw 相同
+	Decoded []byte
+}
+
+// 帧写入, 可被多个连接共享
+// 写入的帧先缓冲, 最长 SetFlushInterval 后写入底层 io.Writer
+type Writer struct {
+	lock   *sync.Mutex
+	w      *bufio.Writer
+	closer io.Closer
+	last   int64
+	buf    [binary.MaxVarintLen64]byte
+
+	interval time.Duration
+	// 等待中的定时写入, 为 nil 时没有缓冲数据
+	timer *time.Timer
+	// 定时写入失败的错误, 由下一次 Write 或 Flush 返回
+	err    error
+	closed bool
+}
+
+func NewWriter(w io.Writer) (*Writer, error) {
+	bw := bufio.NewWriter(w)
+	if _, err := bw.Write(magic); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &Writer{
+		lock:     new(sync.Mutex),
+		w:        bw,
+		interval: time.Second,
+	}, nil
+}
+
+// 设置缓冲的最长时间, 默认 1 秒, 0 为每帧立即写入
+func (w *Writer) SetFlushInterval(d time.Duration) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.interval = d
+}
+
+// 创建录制文件, Close 时关闭文件
+func Create(name string) (*Writer, error) {
+	f, err := os.Create(name)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	w, err := NewWriter(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	w.closer = f
+	return w, nil
+}
+
+// 写入一帧, decoded 为 nil 或与 raw 相同时不重复保存
+func (w *Writer) Write(at time.Time, raw, decoded []byte) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.closed {
+		return errors.New("wsrecord: writer closed")
+	}
+	if err := w.err; err != nil {
+		w.err = nil
+		return err
+	}
+	if len(raw) > maxFrameSize || len(decoded) > maxFrameSize {
+		return errors.New("wsrecord: frame too large")
+	}
+
+	ts := at.UnixNano()
+	if err := w.varint(ts - w.last); err != nil {
+		return err
+	}
+	w.last = ts
+
+	if err := w.bytes(raw); err != nil {
+		return err
+	}
+	if decoded != nil && string(decoded) == string(raw) {
+		decoded = nil
+	}
+	if err := w.bytes(decoded); err != nil {
+		return err
+	}
+
+	if w.interval <= 0 {
+		return errors.WithStack(w.w.Flush())
+	}
+	if w.timer == nil {
+		w.timer = time.AfterFunc(w.interval, w.flushLater)
+	}
+	return nil
+}
+
+func (w *Writer) flushLater() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.timer = nil
+	if w.closed {
+		return
+	}
+	if err := w.w.Flush(); err != nil {
+		w.err = errors.WithStack(err)
+	}
+}
+
+func (w *Writer) Flush() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if err := w.err; err != nil {
+		w.err = nil
+		return err
+	}
+	return errors.WithStack(w.w.Flush())
+}
+
+// 可以重复调用
+func (w *Writer) Close() error {
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+	if w.closer != nil {
+		return errors.WithStack(w.closer.Close())
+	}
+	return nil
+}
+
+func (w *Writer) varint(v int64) error {
+	n := binary.PutVarint(w.buf[:], v)
+	_, err := w.w.Write(w.buf[:n])
+	return errors.WithStack(err)
+}
+
+func (w *Writer) bytes(b []byte) error {
+	n := binary.PutUvarint(w.buf[:], uint64(len(b)))
+	if _, err := w.w.Write(w.buf[:n]); err != nil {
+		return errors.WithStack(err)
+	}
+	_, err := w.w.Write(b)
+	return errors.WithStack(err)
+}
+
+// 帧读取
+type Reader struct {
+	r      *bufio.Reader
+	closer io.Closer
+	last   int64
+	header bool
+}
+
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+// 打开录制文件, Close 时关闭文件
+func Open(name string) (*Reader, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	r := NewReader(f)
+	r.closer = f
+	return r, nil
+}
+
+// 读取下一帧, 结束时返回 io.EOF
+func (r *Reader) Next() (*Frame, error) {
+	if !r.header {
+		head := make([]byte, len(magic))
+		if _, err := io.ReadFull(r.r, head); err != nil || string(head) != string(magic) {
+			return nil, ErrFormat
+		}
+		r.header = true
+	}
+
+	delta, err := binary.ReadVarint(r.r)
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, errors.Wrap(ErrFormat, err.Error())
+	}
+	r.last += delta
+
+	raw, err := r.bytes()
+	if err != nil {
+		return nil, err
+	}
+	decoded, err := r.bytes()
+	if err != nil {
+		return nil, err
+	}
+	if len(decoded) == 0 {
+		decoded = raw
+	}
+
+	return &Frame{
+		At:      time.Unix(0, r.last),
+		Raw:     raw,
+		Decoded: decoded,
+	}, nil
+}
+
+func (r *Reader) Close() error {
+	if r.closer != nil {
+		return errors.WithStack(r.closer.Close())
+	}
+	return nil
+}
+
+func (r *Reader) bytes() ([]byte, error) {
+	n, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return nil, errors.Wrap(ErrFormat, err.Error())
+	}
+	if n > maxFrameSize {
+		return nil, errors.Wrapf(ErrFormat, "frame length %d", n)
+	}
+	// 按实际读到的数据分配, 截断的文件不会按声明的长度分配内存
+	var b bytes.Buffer
+	if _, err := io.CopyN(&b, r.r, int64(n)); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, errors.Wrap(ErrFormat, err.Error())
+	}
+	return b.Bytes(), nil
+}
+
+// 按录制时的时间间隔回放帧
+// - speed 回放倍速, 1 为原速, 小于等于 0 表示不等待
+type Player struct {
+	r     *Reader
+	speed float64
+	// 第一帧的录制时间和回放开始时间
+	first time.Time
+	start time.Time
+}
+
+func NewPlayer(r *Reader, speed float64) *Player {
+	return &Player{r: r, speed: speed}
+}
+
+// 读取下一帧, 等待到该帧按倍速换算后的回放时间
+func (p *Player) Next() (*Frame, error) {
+	frame, err := p.r.Next()
+	if err != nil {
+		return nil, err
+	}
+	if p.speed <= 0 {
+		return frame, nil
+	}
+
+	if p.first.IsZero() {
+		p.first = frame.At
+		p.start = time.Now()
+		return frame, nil
+	}
+	due := p.start.Add(time.Duration(float64(frame.At.Sub(p.first)) / p.speed))
+	if d := time.Until(due); d > 0 {
+		time.Sleep(d)
+	}
+	return frame, nil
+}
+
+func (p *Player) Close() error {
+	return p.r.Close()
+}
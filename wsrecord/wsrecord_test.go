@@ -0,0 +1,153 @@
+package wsrecord
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Unix(1700000000, 123)
+	frames := []*Frame{
+		{At: start, Raw: []byte{0x1f, 0x8b, 1}, Decoded: []byte(`{"a":1}`)},
+		{At: start.Add(40 * time.Millisecond), Raw: []byte(`{"b":2}`)},
+		{At: start.Add(80 * time.Millisecond), Raw: []byte(`{"c":3}`), Decoded: []byte(`{"c":3}`)},
+	}
+	for _, f := range frames {
+		if err := w.Write(f.At, f.Raw, f.Decoded); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewReader(bytes.NewReader(buf.Bytes()))
+	for i, want := range frames {
+		got, err := r.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded := want.Decoded
+		if decoded == nil {
+			decoded = want.Raw
+		}
+		if !got.At.Equal(want.At) || !bytes.Equal(got.Raw, want.Raw) || !bytes.Equal(got.Decoded, decoded) {
+			t.Fatalf("frame %d: got %+v", i, got)
+		}
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Fatalf("got %v, want EOF", err)
+	}
+
+	if _, err := NewReader(bytes.NewReader([]byte("nope"))).Next(); err != ErrFormat {
+		t.Fatalf("got %v, want ErrFormat", err)
+	}
+}
+
+type lockedBuffer struct {
+	lock sync.Mutex
+	buf  bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) Len() int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.Len()
+}
+
+func TestWriter_FlushInterval(t *testing.T) {
+	// 没有后续帧时, 缓冲的帧也在间隔后写入
+	var buf lockedBuffer
+	w, err := NewWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetFlushInterval(20 * time.Millisecond)
+	if err := w.Write(time.Now(), []byte(`{"a":1}`), nil); err != nil {
+		t.Fatal(err)
+	}
+	if n := buf.Len(); n != 0 {
+		t.Fatalf("flushed too early: %d bytes", n)
+	}
+	deadline := time.Now().Add(time.Second)
+	for buf.Len() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("frame not flushed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 间隔为 0 时每帧立即写入
+	var direct bytes.Buffer
+	if w, err = NewWriter(&direct); err != nil {
+		t.Fatal(err)
+	}
+	w.SetFlushInterval(0)
+	if err := w.Write(time.Now(), []byte(`{"a":1}`), nil); err != nil {
+		t.Fatal(err)
+	}
+	if direct.Len() == 0 {
+		t.Fatal("frame not flushed")
+	}
+}
+
+func TestPlayer(t *testing.T) {
+	var buf bytes.Buffer
+	w, _ := NewWriter(&buf)
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		_ = w.Write(start.Add(time.Duration(i)*100*time.Millisecond), []byte("x"), nil)
+	}
+	_ = w.Close()
+
+	// 4 倍速回放, 共 200ms 的录制约需 50ms
+	p := NewPlayer(NewReader(&buf), 4)
+	begin := time.Now()
+	for {
+		if _, err := p.Next(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d := time.Since(begin); d < 45*time.Millisecond || d > time.Second {
+		t.Fatalf("replay took %s", d)
+	}
+}
+
+func TestReader_Corrupted(t *testing.T) {
+	cases := map[string][]byte{
+		"magic":     []byte("WSR0"),
+		"overflow":  append([]byte("WSR1\x02"), 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01),
+		"too large": append([]byte("WSR1\x02"), 0x80, 0x80, 0x80, 0x80, 0x01),
+		"truncated": []byte("WSR1\x02\x10abc"),
+	}
+	for name, data := range cases {
+		if _, err := NewReader(bytes.NewReader(data)).Next(); !errors.Is(err, ErrFormat) {
+			t.Fatalf("%s: expected ErrFormat, got %v", name, err)
+		}
+	}
+}